@@ -1,104 +1,537 @@
 package main
 
 import (
+	"errors"
 	"find/internal/config"
+	"find/internal/diff"
 	"find/internal/logs"
 	"find/internal/note"
 	"find/internal/order"
+	"find/internal/otp"
+	"find/internal/password"
 	"find/internal/reminder"
 	"find/internal/stdin"
-	"find/internal/weather"
 	"fmt"
+	"io"
+	"math"
 	"os"
+	"strconv"
+	"strings"
+	"time"
 )
 
-func init() {
+// Exit codes of one-shot mode, which tell scripts whether the order found something.
+const (
+	exitOK       = 0
+	exitNotFound = 1
+	exitError    = 2
+)
+
+// failures is where failures like 'Need key.' are shown, which is stderr in one-shot mode.
+var failures io.Writer = os.Stdout
+
+// scripted tells whether orders are given as arguments of the process rather than typed at the prompt, see oneShot.
+var scripted bool
+
+func main() {
+	logs.Info("FIND started with configs: ")
+	logs.Config()
+
+	if len(os.Args) > 1 {
+		os.Exit(oneShot(os.Args[1:]))
+	}
+
+	unlock()
 	err := note.Check()
 	if err != nil {
 		logs.Error("check note error: %s\n", err.Error())
 	}
-	if config.Conf.Reminder.Enabled {
-		err := reminder.Start()
-		if err != nil {
-			logs.Error("start reminder error: %s\n", err.Error())
-		}
+	err = reminder.Start()
+	if err != nil {
+		logs.Error("start reminder error: %s\n", err.Error())
 	}
-}
-
-func main() {
-	logs.Info("FIND started with configs: ")
-	logs.Config()
 
 	fmt.Println("=================")
 	fmt.Println("Welcome to FIND!")
 	fmt.Println("=================")
+	err = stdin.Interact(config.InputHistoryPath(), complete)
+	if err != nil {
+		logs.Error("load input history error: %s\n", err.Error())
+	}
 	for true {
-		fmt.Print("[FIND]# ")
-		input, err := stdin.ReadString()
+		book := note.Active()
+		prompt := "[FIND]# "
+		if book.Name() != config.DefaultNotebook {
+			prompt = fmt.Sprintf("[FIND %s]# ", book.Name())
+		}
+		input, err := stdin.Prompt(prompt)
+		if errors.Is(err, io.EOF) {
+			fmt.Println()
+			execute(book, order.Command{Order: order.Exit})
+		}
 		if err != nil {
 			logs.Error("read input error: %s\n", err.Error())
 			continue
 		}
 
-		var fast bool
-		var all bool
+		cmds, ok := parse(input)
+		if ok {
+			executeAll(cmds)
+		}
+		remember(book, input)
+	}
+}
 
-		param := order.Param(input)
+// remember is used to keep a line of user's input in the history, unless it has a secret like 'add k #secret:v',
+// or it's typed in an encrypted notebook, since the history is kept in plain text.
+func remember(book *note.Notebook, input string) {
+	if logs.Secret(input) || book.Encrypted() {
+		return
+	}
+	err := stdin.Remember(input)
+	if err != nil {
+		logs.Warn("main: remember %s error: %s", input, err.Error())
+	}
+}
 
-		switch order.Order(input) {
-		case order.Find:
-			_, err = note.Find(param, true, false, true)
-			if err != nil {
-				logs.Error("find %s error: %s\n", param, err.Error())
-				continue
-			}
-		case order.Add:
-			same, err := note.Find(note.GetKey(param), true, true, false)
-			if err != nil {
-				logs.Error("find %s before add error: %s\n", note.GetKey(param), err.Error())
-				continue
-			}
-			if len(same) > 0 {
-				fmt.Println("Duplicate key.")
-				continue
-			}
-			err = note.Write(&[]string{param}, os.O_APPEND)
-			if err != nil {
-				logs.Error("add %s error: %s\n", param, err.Error())
-				continue
-			}
-			succeed()
-		case order.Delete:
-			fast, param = order.Fast(param)
-			all, param = order.All(param)
-			err = note.Delete(param, !fast, !all)
-			if err != nil {
-				logs.Error("delete %s error: %s\n", param, err.Error())
-				continue
-			}
-			succeed()
-		case order.Modify:
-			err = note.Modify(param)
+// complete is used to complete user's input before the cursor, see order.Complete.
+func complete(line string) (int, []string) {
+	return order.Complete(line, completeArg)
+}
+
+// completeArg is used to complete the argument of an order, which is a key of the active notebook
+// for orders on a note, an order for help, or an alias for alias.
+func completeArg(o order.Order, prefix string) []string {
+	switch o.Name() {
+	case order.Help:
+		return withPrefix(order.Names(), prefix)
+	case order.Alias:
+		return withPrefix(order.Aliases(), prefix)
+	case order.Delete, order.Modify, order.History, order.Revert, order.Get, order.Show, order.OTP:
+		if strings.Contains(prefix, ":") {
+			return nil
+		}
+		keys, err := note.Active().Keys(prefix)
+		if err != nil {
+			logs.Warn("main: complete keys of %s error: %s", prefix, err.Error())
+			return nil
+		}
+		return keys
+	}
+	return nil
+}
+
+// oneShot is used to run an order given as arguments of the process like 'find add k:v', which is for scripts,
+// so errors go to stderr, and neither the reminder nor the backup runs in background. It returns the exit code.
+func oneShot(args []string) int {
+	scripted = true
+	failures = os.Stderr
+	logs.SetOutput(os.Stderr)
+	note.SetBackground(false)
+
+	cmds, ok := parseArgs(args)
+	if !ok {
+		return exitError
+	}
+	unlock()
+	err := note.Check()
+	if err != nil {
+		logs.Error("check note error: %s\n", err.Error())
+		return exitError
+	}
+	return executeAll(cmds)
+}
+
+// parse is used to expand aliases of user's input and parse the orders, showing why if any can't be,
+// returning the commands and whether all are parsed.
+func parse(input string) ([]order.Command, bool) {
+	lines, err := order.Expand(input)
+	if err != nil {
+		logs.Error("expand %s error: %s\n", input, err.Error())
+		return nil, false
+	}
+	return parseLines(lines)
+}
+
+// parseArgs is used to parse the order given as arguments of the process like parse,
+// whose words are taken as the shell splits them rather than joined and lexed again,
+// returning the commands and whether all are parsed.
+func parseArgs(args []string) ([]order.Command, bool) {
+	if _, ok := order.LookupAlias(args[0]); ok {
+		lines, err := order.ExpandArgs(args)
+		if err != nil {
+			logs.Error("expand %s error: %s\n", args[0], err.Error())
+			return nil, false
+		}
+		return parseLines(lines)
+	}
+	cmd, err := order.ParseArgs(args)
+	if err != nil {
+		parseFailed(strings.Join(args, " "), err)
+		return nil, false
+	}
+	return []order.Command{cmd}, true
+}
+
+// parseLines is used to parse order lines, showing why if any can't be, returning the commands and whether all are parsed.
+func parseLines(lines []string) ([]order.Command, bool) {
+	cmds := make([]order.Command, 0, len(lines))
+	for _, line := range lines {
+		cmd, err := order.Parse(line)
+		if err != nil {
+			parseFailed(line, err)
+			return nil, false
+		}
+		cmds = append(cmds, cmd)
+	}
+	return cmds, true
+}
+
+// executeAll is used to run commands in sequence on the active notebook, stopping at the first one which fails,
+// returning the exit code of the last one run.
+func executeAll(cmds []order.Command) int {
+	code := exitOK
+	for _, cmd := range cmds {
+		code = execute(note.Active(), cmd)
+		if code == exitError {
+			break
+		}
+	}
+	return code
+}
+
+// parseFailed is used to show why user's input can't be parsed, along with orders user may mean if it's unknown.
+func parseFailed(input string, err error) {
+	var e *order.UnknownError
+	if errors.As(err, &e) {
+		unknown(e)
+		return
+	}
+	logs.Error("parse %s error: %s\n", input, err.Error())
+}
+
+// unknown is used to show that an order is unknown, along with orders user may mean.
+func unknown(e *order.UnknownError) {
+	fail(fmt.Sprintf("Unknown order %s, try '%s' for all orders.", e.Name, order.Help))
+	note.PrintSuggestions(e.Suggestions)
+}
+
+// fail is used to show a failure to the user.
+func fail(message string) {
+	_, _ = fmt.Fprintln(failures, message)
+}
+
+// execute is used to run an order parsed from user's input on the notebook, returning the exit code of one-shot mode.
+func execute(book *note.Notebook, cmd order.Command) int {
+	o, ok := order.Lookup(cmd.Order)
+	if !ok {
+		return exitOK
+	}
+	return o.Run(book, cmd)
+}
+
+// parseNote is used to parse note from param of 'add' or 'mod',
+// reading the content from following lines if it's a heredoc like 'key:<<EOF'.
+func parseNote(param string) (note.Note, error) {
+	newNote, err := note.Parse(param)
+	if err != nil {
+		return newNote, err
+	}
+	if delim, ok := order.Heredoc(newNote.Val); ok {
+		newNote.Val, err = stdin.ReadUntil(delim)
+		if err != nil {
+			return newNote, fmt.Errorf("read content error: %v", err)
+		}
+	}
+	if newNote.Secret() {
+		logs.Redact(newNote.Val)
+	}
+	return newNote, nil
+}
+
+// showHistory is used to list revisions of the note whose key equals to key,
+// or show the value of a revision if one is specified,
+// or show the difference between two revisions if two are specified.
+func showHistory(book *note.Notebook, key string, revs []int) error {
+	history, err := book.History(key)
+	if err != nil {
+		return err
+	}
+	if len(revs) == 0 {
+		note.PrintHistory(history)
+		return nil
+	}
+
+	if note.SecretRevisions(history) {
+		return fmt.Errorf("%s is a secret, try '%s %s' for the current value", key, order.Show, key)
+	}
+	vals := make([]string, 0, len(revs))
+	for _, rev := range revs {
+		found := note.RevisionOf(history, rev)
+		if found == nil {
+			return fmt.Errorf("revision %d not found", rev)
+		}
+		vals = append(vals, found.Val)
+	}
+	if len(vals) == 1 {
+		fmt.Println(vals[0])
+		return nil
+	}
+	for _, line := range diff.Lines(vals[0], vals[1]) {
+		fmt.Println(line)
+	}
+	return nil
+}
+
+// trash is used to execute sub orders of trash, which lists deleted notes by default.
+func trash(book *note.Notebook, sub, key string, confirm bool) error {
+	switch sub {
+	case "", order.TrashList:
+		trashed, err := book.Trash()
+		if err != nil {
+			return err
+		}
+		note.PrintTrash(trashed)
+	case order.TrashRestore:
+		if key == "" {
+			fail("Need key.")
+			return nil
+		}
+		err := book.Restore(key)
+		if err != nil {
+			return err
+		}
+		succeed()
+	case order.TrashPurge:
+		count, err := book.Purge(confirm)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Purged %d notes.\n", count)
+	default:
+		fmt.Printf("Unknown sub order %s, try %s, %s or %s.\n", sub, order.TrashList, order.TrashRestore, order.TrashPurge)
+	}
+	return nil
+}
+
+// maxUnlockTries is how many times the passphrase of a notebook can be tried when FIND starts.
+const maxUnlockTries = 3
+
+// unlock is used to ask for passphrases of encrypted notebooks, which stay locked if all tries are wrong.
+func unlock() {
+	for _, book := range note.Notebooks() {
+		if !book.Locked() {
+			continue
+		}
+		for i := 0; i < maxUnlockTries; i++ {
+			passphrase, err := stdin.ReadSecret(fmt.Sprintf("Passphrase of notebook %s: ", book.Name()))
 			if err != nil {
-				logs.Error("modify %s error: %s\n", param, err.Error())
-				continue
-			}
-			succeed()
-		case order.Weather:
-			all, param = order.All(param)
-			if param == "" {
-				fmt.Println("Need address.")
-				continue
+				logs.Error("read passphrase error: %s\n", err.Error())
+				break
 			}
-			err = weather.Search(param, all)
-			if err != nil {
-				logs.Error("search weather of %s error: %s\n", param, err.Error())
-				continue
+			err = book.Unlock(passphrase)
+			if err == nil {
+				break
 			}
-		case order.Exit:
-			os.Exit(1)
+			logs.Error("unlock notebook %s error: %s\n", book.Name(), err.Error())
+		}
+	}
+}
+
+// encrypt is used to execute sub orders of encrypt, which shows whether the notebook is encrypted by default.
+func encrypt(book *note.Notebook, sub string) error {
+	switch sub {
+	case "":
+		if book.Encrypted() {
+			fmt.Printf("Notebook %s is encrypted.\n", book.Name())
+		} else {
+			fmt.Printf("Notebook %s isn't encrypted.\n", book.Name())
+		}
+		return nil
+	case order.EncryptOn:
+		passphrase, err := newPassphrase()
+		if err != nil {
+			return err
+		}
+		err = book.Encrypt(passphrase)
+		if err != nil {
+			return err
+		}
+	case order.EncryptOff:
+		passphrase, err := stdin.ReadSecret("Passphrase: ")
+		if err != nil {
+			return err
+		}
+		err = book.Decrypt(passphrase)
+		if err != nil {
+			return err
+		}
+	case order.EncryptPassphrase:
+		old, err := stdin.ReadSecret("Old passphrase: ")
+		if err != nil {
+			return err
+		}
+		passphrase, err := newPassphrase()
+		if err != nil {
+			return err
+		}
+		err = book.ChangePassphrase(old, passphrase)
+		if err != nil {
+			return err
+		}
+	default:
+		fmt.Printf("Unknown sub order %s, try %s, %s or %s.\n", sub, order.EncryptOn, order.EncryptOff, order.EncryptPassphrase)
+		return nil
+	}
+	succeed()
+	return nil
+}
+
+// newPassphrase is used to ask for a new passphrase twice, returning the passphrase and error if they differ.
+func newPassphrase() (string, error) {
+	passphrase, err := stdin.ReadSecret("New passphrase: ")
+	if err != nil {
+		return "", err
+	}
+	repeated, err := stdin.ReadSecret("Repeat new passphrase: ")
+	if err != nil {
+		return "", err
+	}
+	if passphrase != repeated {
+		return "", fmt.Errorf("passphrases don't match")
+	}
+	return passphrase, nil
+}
+
+// generate is used to generate a password or passphrase by flags of the command as the value of a secret note,
+// which isn't shown unless '-p' is given.
+func generate(book *note.Notebook, cmd order.Command) error {
+	param := cmd.Arg()
+	options := password.Options{
+		Length:      password.DefaultLength,
+		Separator:   password.DefaultSeparator,
+		NoAmbiguous: cmd.Bool(order.FlagUnambiguous),
+	}
+	options.Classes, _ = cmd.Value(order.FlagClasses)
+	if length, ok := cmd.Value(order.FlagLength); ok {
+		n, err := strconv.Atoi(length)
+		if err != nil {
+			return fmt.Errorf("invalid length %s", length)
+		}
+		options.Length = n
+	}
+	if words, ok := cmd.Value(order.FlagWords); ok {
+		n, err := strconv.Atoi(words)
+		if err != nil {
+			return fmt.Errorf("invalid number of words %s", words)
 		}
+		options.Words = n
+	}
+	if param == "" {
+		fail("Need key.")
+		return nil
+	}
+
+	secret, err := password.Generate(options)
+	if err != nil {
+		return err
+	}
+	newNote, err := note.Parse(param + ":" + secret)
+	if err != nil {
+		return err
+	}
+	written, err := book.WriteSecret(newNote, !cmd.Bool(order.FlagForce))
+	if err != nil || !written {
+		return err
+	}
+	succeed()
+	if cmd.Bool(order.FlagPrint) {
+		fmt.Println(secret)
+	} else {
+		fmt.Printf("Try '%s %s' to see it.\n", order.Show, newNote.Key)
+	}
+	return nil
+}
+
+// otpTag groups 2FA seeds imported by otp.
+const otpTag = "otp"
+
+// importOTP is used to add an otpauth URI as a secret note named by its issuer and account like 'GitHub alice',
+// and show the current code.
+func importOTP(book *note.Notebook, uri string) error {
+	key, err := otp.Parse(uri)
+	if err != nil {
+		return err
+	}
+	name := key.Name()
+	if name == "" {
+		return fmt.Errorf("missing issuer and account in the uri")
+	}
+	same, err := book.Find(name, true)
+	if err != nil {
+		return fmt.Errorf("find %s before add error: %v", name, err)
+	}
+	if len(same) > 0 {
+		fmt.Println("Duplicate key.")
+		return nil
+	}
+	newNote, err := note.New(name, uri)
+	if err != nil {
+		return err
+	}
+	newNote.Tags = []string{otpTag, note.SecretTag}
+	err = book.Write([]note.Note{newNote})
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Added %s.\n", name)
+	return printCode(uri)
+}
+
+// otpBarWidth is how many characters the countdown bar of a code has.
+const otpBarWidth = 10
+
+// otpSoon is how long before a code expires that the next code is shown too.
+const otpSoon = 5 * time.Second
+
+// printCode is used to show the current code of a 2FA seed along with a countdown,
+// and the next code if the current one is about to expire.
+func printCode(seed string) error {
+	key, err := otp.Parse(seed)
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	remaining := key.Remaining(now)
+	left := int(math.Ceil(remaining.Seconds()))
+	filled := left * otpBarWidth / key.Period
+	fmt.Printf("%s expires in %ds [%s%s]\n", key.Code(now), left,
+		strings.Repeat("#", filled), strings.Repeat("-", otpBarWidth-filled))
+	if remaining <= otpSoon {
+		fmt.Printf("%s is the next\n", key.Code(now.Add(remaining)))
+	}
+	return nil
+}
+
+// withPrefix is used to pick names starting with prefix.
+func withPrefix(names []string, prefix string) []string {
+	var picked []string
+	for _, name := range names {
+		if strings.HasPrefix(name, prefix) {
+			picked = append(picked, name)
+		}
+	}
+	return picked
+}
+
+// suggest is used to show keys which user may mean by keyword when nothing is found by it.
+func suggest(book *note.Notebook, keyword string) {
+	keys, err := book.Suggest(keyword)
+	if err != nil {
+		logs.Warn("main: suggest for %s error: %s", keyword, err.Error())
+		return
 	}
+	note.PrintSuggestions(keys)
 }
 
 func succeed() {
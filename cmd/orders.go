@@ -0,0 +1,555 @@
+package main
+
+import (
+	"find/internal/logs"
+	"find/internal/note"
+	"find/internal/order"
+	"find/internal/otp"
+	"find/internal/stdin"
+	"find/internal/weather"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// force skips the confirmation of an order.
+var force = order.Flag{Name: order.FlagForce, Short: "f", Usage: "skip the confirmation"}
+
+func init() {
+	order.Register(order.NewText(order.Find, "find [flags] keyword...", "find notes by keywords, tags, fields or a query", []order.Flag{
+		{Name: order.FlagLong, Short: "l", Usage: "show metadata of notes"},
+		{Name: order.FlagNotebooks, Short: "n", Usage: "find in all notebooks"},
+		{Name: order.FlagValue, Short: "v", Usage: "find in values rather than keys"},
+		{Name: order.FlagEverywhere, Short: "e", Usage: "find in both keys and values"},
+		{Name: order.FlagRegex, Short: "r", Usage: "find by a regular expression"},
+		{Name: order.FlagFuzzy, Short: "z", Usage: "find approximately, tolerating typos"},
+	}, runFind))
+	order.Register(order.NewText(order.Add, "add key [#tag...]:content", "add a note", nil, runAdd))
+	order.Register(order.New(order.Delete, "del [flags] key", "delete the note of the key, or all notes found by keywords", []order.Flag{
+		force,
+		{Name: order.FlagAll, Short: "a", Usage: "delete all notes found like find rather than the key"},
+	}, runDelete, "rm"))
+	order.Register(order.NewText(order.Modify, "mod [flags] key [#tag...]:content", "modify a note, or add it if it doesn't exist",
+		[]order.Flag{{Name: order.FlagForce, Short: "f", Usage: "modify the note found by pinyin without the confirmation"}}, runModify))
+	order.Register(order.New(order.History, "history key [rev [rev]]", "show revisions of a note, or the difference between two", nil, runHistory))
+	order.Register(order.New(order.Revert, "revert key rev", "bring a revision of a note back", nil, runRevert))
+	order.Register(order.New(order.Undo, "undo", "undo the last change", nil, runUndo))
+	order.Register(order.New(order.Trash, "trash [flags] [list | restore key | purge]", "list, restore or purge deleted notes",
+		[]order.Flag{force}, runTrash))
+	order.Register(order.New(order.Tags, "tags", "count notes of every tag", nil, runTags))
+	order.Register(order.NewText(order.Tag, "tag [flags] keyword: +tag -tag", "add or remove tags of notes found by keywords",
+		[]order.Flag{force}, runTag))
+	order.Register(order.New(order.Ls, "ls [prefix]", "print the tree of keys", nil, runLs, "tree"))
+	order.Register(order.New(order.Get, "get key", "print only the value of a note, even if it's a secret", nil, runGet))
+	order.Register(order.New(order.Show, "show [flags] key", "print a note even if it's a secret", []order.Flag{
+		{Name: order.FlagCopy, Short: "c", Usage: "copy the value into the clipboard rather than print it"},
+	}, runShow))
+	order.Register(order.New(order.OTP, "otp key | otpauth://...", "print the code of a 2FA seed, or import an otpauth URI", nil, runOTP))
+	order.Register(order.New(order.Gen, "gen [flags] key [#tag...]", "generate a password or passphrase as a secret note", []order.Flag{
+		force,
+		{Name: order.FlagPrint, Short: "p", Usage: "print the generated value"},
+		{Name: order.FlagLength, Short: "n", Value: "n", Usage: "length of the password, default 20"},
+		{Name: order.FlagClasses, Short: "c", Value: "luds", Usage: "classes of characters, default luds"},
+		{Name: order.FlagUnambiguous, Short: "x", Usage: "exclude characters easily mistaken like 0 and O"},
+		{Name: order.FlagWords, Short: "w", Value: "n", Usage: "generate a passphrase of n words instead"},
+	}, runGen))
+	order.Register(order.New(order.Encrypt, "encrypt [on | off | passphrase]", "encrypt or decrypt the notebook, or change its passphrase", nil, runEncrypt))
+	order.Register(order.New(order.Use, "use [notebook]", "list notebooks, or switch to one", nil, runUse))
+	order.Register(order.New(order.Weather, "weather [flags] address", "print the weather of a china address", []order.Flag{
+		{Name: order.FlagAll, Short: "a", Usage: "show the forecast too"},
+	}, runWeather))
+	order.Register(order.NewText(order.Alias, "alias [flags] [name [= order line[; order line...]]]",
+		"list aliases, show one, or define one of order lines", []order.Flag{
+			{Name: order.FlagDelete, Short: "d", Usage: "remove the alias"},
+		}, runAlias))
+	order.Register(order.New(order.Help, "help [order]", "list all orders, or show how to use one", nil, runHelp, "?"))
+	order.Register(order.New(order.Exit, "exit", "exit the program", nil, runExit, "quit"))
+}
+
+// runFind is used to find notes by keywords, tags, fields or a query.
+func runFind(book *note.Notebook, cmd order.Command) int {
+	param := cmd.Text
+	long := cmd.Bool(order.FlagLong)
+	scope := note.ScopeKey
+	if cmd.Bool(order.FlagEverywhere) {
+		scope = note.ScopeEverywhere
+	} else if cmd.Bool(order.FlagValue) {
+		scope = note.ScopeVal
+	}
+	mode := note.ModeSubstring
+	if cmd.Bool(order.FlagRegex) {
+		mode = note.ModeRegex
+	} else if cmd.Bool(order.FlagFuzzy) {
+		mode = note.ModeFuzzy
+	}
+	if cmd.Bool(order.FlagNotebooks) {
+		found, err := note.FindAll(param, scope, mode)
+		if err != nil {
+			logs.Error("find %s in all notebooks error: %s\n", param, err.Error())
+			return exitError
+		}
+		note.PrintFound(found, long, scope != note.ScopeKey)
+		if len(found) == 0 {
+			return exitNotFound
+		}
+		return exitOK
+	}
+	matches, err := book.Search(param, scope, mode)
+	if err != nil {
+		logs.Error("find %s error: %s\n", param, err.Error())
+		return exitError
+	}
+	note.PrintMatches(matches, long, scope != note.ScopeKey)
+	if len(matches) > 0 {
+		return exitOK
+	}
+	if scope != note.ScopeVal && mode != note.ModeRegex {
+		suggest(book, param)
+	}
+	return exitNotFound
+}
+
+// runAdd is used to add a note.
+func runAdd(book *note.Notebook, cmd order.Command) int {
+	param := cmd.Text
+	newNote, err := parseNote(param)
+	if err != nil {
+		logs.Error("parse %s error: %s\n", param, err.Error())
+		return exitError
+	}
+	same, err := book.Find(newNote.Key, true)
+	if err != nil {
+		logs.Error("find %s before add error: %s\n", newNote.Key, err.Error())
+		return exitError
+	}
+	if len(same) > 0 {
+		fail("Duplicate key.")
+		return exitError
+	}
+	err = book.Write([]note.Note{newNote})
+	if err != nil {
+		logs.Error("add %s error: %s\n", param, err.Error())
+		return exitError
+	}
+	succeed()
+	return exitOK
+}
+
+// runDelete is used to delete notes.
+func runDelete(book *note.Notebook, cmd order.Command) int {
+	param := deleteKeyword(cmd)
+	fast := cmd.Bool(order.FlagForce)
+	all := cmd.Bool(order.FlagAll)
+	targets, err := book.Find(param, !all)
+	if err != nil {
+		logs.Error("find %s before delete error: %s\n", param, err.Error())
+		return exitError
+	}
+	if len(targets) == 0 {
+		fail("Nothing to delete.")
+		suggest(book, param)
+		return exitNotFound
+	}
+	err = book.Delete(param, !fast, !all)
+	if err != nil {
+		logs.Error("delete %s error: %s\n", param, err.Error())
+		return exitError
+	}
+	succeed()
+	return exitOK
+}
+
+// deleteKeyword is used to get what del looks for, which is keywords as typed for find if all notes found are
+// deleted, so that quotes of a phrase are kept, or the key otherwise.
+func deleteKeyword(cmd order.Command) string {
+	if cmd.Bool(order.FlagAll) {
+		return cmd.Text
+	}
+	return cmd.Arg()
+}
+
+// runModify is used to modify a note, or add it if it does not exist.
+func runModify(book *note.Notebook, cmd order.Command) int {
+	param := cmd.Text
+	newNote, err := parseNote(param)
+	if err != nil {
+		logs.Error("parse %s error: %s\n", param, err.Error())
+		return exitError
+	}
+	key, err := book.Resolve(newNote.Key)
+	if err != nil {
+		logs.Error("resolve %s error: %s\n", newNote.Key, err.Error())
+		return exitError
+	}
+	// a key found by pinyin may not be what user means, since mod adds the key if it doesn't exist.
+	if key != newNote.Key && !cmd.Bool(order.FlagForce) {
+		sure, err := note.Confirmed(fmt.Sprintf("Sure modify %s? Or %s will be added.", key, newNote.Key))
+		if err != nil {
+			logs.Error("confirm modify %s error: %s\n", key, err.Error())
+			return exitError
+		}
+		if sure {
+			newNote.Key = key
+		}
+	} else {
+		newNote.Key = key
+	}
+	olds, err := book.Find(newNote.Key, true)
+	if err != nil {
+		logs.Error("find %s before modify error: %s\n", newNote.Key, err.Error())
+		return exitError
+	}
+	if len(olds) > 0 && olds[0].Secret() {
+		logs.Redact(newNote.Val)
+	}
+	var similar []string
+	if len(olds) == 0 {
+		similar, err = book.Suggest(newNote.Key)
+		if err != nil {
+			logs.Warn("main: suggest for %s error: %s", newNote.Key, err.Error())
+		}
+	}
+	err = book.Modify(newNote, note.SourceUser)
+	if err != nil {
+		logs.Error("modify %s error: %s\n", param, err.Error())
+		return exitError
+	}
+	succeed()
+	if len(olds) == 0 {
+		fmt.Printf("%s didn't exist, so it's added.\n", newNote.Key)
+		note.PrintSuggestions(similar)
+	}
+	return exitOK
+}
+
+// runHistory is used to show revisions of a note.
+func runHistory(book *note.Notebook, cmd order.Command) int {
+	revs, key := order.Revisions(cmd.Args, 2)
+	if key == "" {
+		fail("Need key.")
+		return exitError
+	}
+	err := showHistory(book, key, revs)
+	if err != nil {
+		logs.Error("show history of %s error: %s\n", key, err.Error())
+		return exitError
+	}
+	return exitOK
+}
+
+// runRevert is used to bring a revision of a note back.
+func runRevert(book *note.Notebook, cmd order.Command) int {
+	revs, key := order.Revisions(cmd.Args, 1)
+	if key == "" || len(revs) != 1 {
+		fail("Need key and revision.")
+		return exitError
+	}
+	err := book.Revert(key, revs[0])
+	if err != nil {
+		logs.Error("revert %s to %d error: %s\n", key, revs[0], err.Error())
+		return exitError
+	}
+	succeed()
+	return exitOK
+}
+
+// runUndo is used to undo the last change.
+func runUndo(book *note.Notebook, cmd order.Command) int {
+	action, err := book.Undo()
+	if err != nil {
+		logs.Error("undo error: %s\n", err.Error())
+		return exitError
+	}
+	fmt.Printf("Undid '%s'.\n", action)
+	return exitOK
+}
+
+// runTrash is used to list, restore or purge deleted notes.
+func runTrash(book *note.Notebook, cmd order.Command) int {
+	param := cmd.Arg()
+	fast := cmd.Bool(order.FlagForce)
+	sub, key := order.Sub(cmd.Args)
+	err := trash(book, sub, key, !fast)
+	if err != nil {
+		logs.Error("trash %s error: %s\n", param, err.Error())
+		return exitError
+	}
+	return exitOK
+}
+
+// runTags is used to count notes of every tag.
+func runTags(book *note.Notebook, cmd order.Command) int {
+	tags, err := book.Tags()
+	if err != nil {
+		logs.Error("count tags error: %s\n", err.Error())
+		return exitError
+	}
+	note.PrintTags(tags)
+	return exitOK
+}
+
+// runTag is used to add or remove tags of notes.
+func runTag(book *note.Notebook, cmd order.Command) int {
+	param := cmd.Text
+	fast := cmd.Bool(order.FlagForce)
+	keyword, added, removed, err := order.Retag(param)
+	if err != nil {
+		logs.Error("parse %s error: %s\n", param, err.Error())
+		return exitError
+	}
+	count, err := book.Retag(keyword, added, removed, !fast)
+	if err != nil {
+		logs.Error("tag %s error: %s\n", keyword, err.Error())
+		return exitError
+	}
+	fmt.Printf("Tagged %d notes.\n", count)
+	return exitOK
+}
+
+// runLs is used to print the tree of keys.
+func runLs(book *note.Notebook, cmd order.Command) int {
+	param := cmd.Arg()
+	root, err := book.Tree(param)
+	if err != nil {
+		logs.Error("list %s error: %s\n", param, err.Error())
+		return exitError
+	}
+	note.PrintTree(root)
+	return exitOK
+}
+
+// runEncrypt is used to encrypt or decrypt the notebook.
+func runEncrypt(book *note.Notebook, cmd order.Command) int {
+	param := cmd.Arg()
+	sub, _ := order.Sub(cmd.Args)
+	err := encrypt(book, sub)
+	if err != nil {
+		logs.Error("encrypt %s error: %s\n", param, err.Error())
+		return exitError
+	}
+	return exitOK
+}
+
+// runGet is used to print only the value of a note.
+func runGet(book *note.Notebook, cmd order.Command) int {
+	param := cmd.Arg()
+	if param == "" {
+		fail("Need key.")
+		return exitError
+	}
+	key, err := book.Resolve(param)
+	if err != nil {
+		logs.Error("resolve %s error: %s\n", param, err.Error())
+		return exitError
+	}
+	found, err := book.Reveal(key)
+	if err != nil {
+		logs.Error("get %s error: %s\n", key, err.Error())
+		return exitError
+	}
+	if found == nil {
+		fail("Nothing found.")
+		return exitNotFound
+	}
+	fmt.Println(found.Val)
+	return exitOK
+}
+
+// runShow is used to print or copy a note even if it is a secret.
+func runShow(book *note.Notebook, cmd order.Command) int {
+	param := cmd.Arg()
+	if param == "" {
+		fail("Need key.")
+		return exitError
+	}
+	key, err := book.Resolve(param)
+	if err != nil {
+		logs.Error("resolve %s error: %s\n", param, err.Error())
+		return exitError
+	}
+	found, err := book.Reveal(key)
+	if err != nil {
+		logs.Error("show %s error: %s\n", key, err.Error())
+		return exitError
+	}
+	if found == nil {
+		fail("Nothing to show.")
+		suggest(book, key)
+		return exitNotFound
+	}
+	if !cmd.Bool(order.FlagCopy) {
+		note.PrintRevealed(*found)
+		return exitOK
+	}
+	err = note.Copy(*found)
+	if err != nil {
+		logs.Error("copy %s error: %s\n", key, err.Error())
+		return exitError
+	}
+	fmt.Println("Copied, the clipboard will be cleared in 30 seconds.")
+	return exitOK
+}
+
+// runOTP is used to print the code of a 2FA seed, or import an otpauth URI.
+func runOTP(book *note.Notebook, cmd order.Command) int {
+	param := cmd.Arg()
+	if param == "" {
+		fail("Need key.")
+		return exitError
+	}
+	if otp.IsURI(param) {
+		logs.Redact(param)
+		err := importOTP(book, param)
+		if err != nil {
+			logs.Error("import %s error: %s\n", param, err.Error())
+			return exitError
+		}
+		return exitOK
+	}
+	key, err := book.Resolve(param)
+	if err != nil {
+		logs.Error("resolve %s error: %s\n", param, err.Error())
+		return exitError
+	}
+	found, err := book.Reveal(key)
+	if err != nil {
+		logs.Error("otp %s error: %s\n", key, err.Error())
+		return exitError
+	}
+	if found == nil {
+		fail("Nothing found.")
+		suggest(book, key)
+		return exitNotFound
+	}
+	err = printCode(found.Val)
+	if err != nil {
+		logs.Error("otp %s error: %s\n", key, err.Error())
+		return exitError
+	}
+	// a seed kept without the tag would be shown by find, so it's marked once it's used as one.
+	if !found.Secret() {
+		err = book.MarkSecret(*found, otpTag)
+		if err != nil {
+			logs.Error("mark %s as a secret error: %s\n", key, err.Error())
+			return exitError
+		}
+		fmt.Printf("%s is a secret now, try '%s %s' to see it.\n", key, order.Show, key)
+	}
+	return exitOK
+}
+
+// runGen is used to generate a password or passphrase as a secret note.
+func runGen(book *note.Notebook, cmd order.Command) int {
+	param := cmd.Arg()
+	err := generate(book, cmd)
+	if err != nil {
+		logs.Error("gen %s error: %s\n", param, err.Error())
+		return exitError
+	}
+	return exitOK
+}
+
+// runUse is used to list notebooks, or switch to one.
+func runUse(book *note.Notebook, cmd order.Command) int {
+	param := cmd.Arg()
+	if param == "" {
+		for _, b := range note.Notebooks() {
+			if b == book {
+				fmt.Printf("* %s\n", b.Name())
+			} else {
+				fmt.Printf("  %s\n", b.Name())
+			}
+		}
+		return exitOK
+	}
+	_, err := note.Use(param)
+	if err != nil {
+		logs.Error("use %s error: %s\n", param, err.Error())
+		return exitError
+	}
+	succeed()
+	return exitOK
+}
+
+// runWeather is used to print the weather of an address.
+func runWeather(book *note.Notebook, cmd order.Command) int {
+	param := cmd.Arg()
+	all := cmd.Bool(order.FlagAll)
+	if param == "" {
+		fail("Need address.")
+		return exitError
+	}
+	err := weather.Search(param, all)
+	if err != nil {
+		logs.Error("search weather of %s error: %s\n", param, err.Error())
+		return exitError
+	}
+	return exitOK
+}
+
+// runExit is used to exit the program, which succeeds in one-shot mode like other orders.
+func runExit(book *note.Notebook, cmd order.Command) int {
+	stdin.Close()
+	if scripted {
+		os.Exit(exitOK)
+	}
+	os.Exit(1)
+	return exitOK
+}
+
+// runAlias is used to list aliases, show one, define one like 'alias prod = find prod db $@', or remove one.
+func runAlias(book *note.Notebook, cmd order.Command) int {
+	param := cmd.Text
+	if cmd.Bool(order.FlagDelete) {
+		if param == "" {
+			fail("Need name.")
+			return exitError
+		}
+		err := order.RemoveAlias(param)
+		if err != nil {
+			logs.Error("remove alias %s error: %s\n", param, err.Error())
+			return exitError
+		}
+		succeed()
+		return exitOK
+	}
+
+	i := strings.Index(param, "=")
+	if i == -1 {
+		if param == "" {
+			order.PrintAliases(order.Aliases())
+			return exitOK
+		}
+		if _, ok := order.LookupAlias(param); !ok {
+			fail(fmt.Sprintf("Alias %s not found.", param))
+			return exitNotFound
+		}
+		order.PrintAliases([]string{param})
+		return exitOK
+	}
+	name := strings.TrimSpace(param[:i])
+	err := order.DefineAlias(name, order.SplitMacro(param[i+1:]))
+	if err != nil {
+		logs.Error("define alias %s error: %s\n", name, err.Error())
+		return exitError
+	}
+	succeed()
+	return exitOK
+}
+
+// runHelp is used to list all orders, or show how to use one.
+func runHelp(book *note.Notebook, cmd order.Command) int {
+	param := cmd.Arg()
+	if param == "" {
+		fmt.Printf("Orders(try '%s order' for more):\n", order.Help)
+		order.PrintOrders()
+		return exitOK
+	}
+	o, ok := order.Lookup(param)
+	if !ok {
+		unknown(order.Unknown(param))
+		return exitError
+	}
+	order.PrintUsage(o)
+	return exitOK
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"find/internal/order"
+	"testing"
+)
+
+func TestDeleteKeyword(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{`del "my key"`, "my key"},
+		{`del my\ key`, "my key"},
+		{`del -a "my key" -old`, `"my key" -old`},
+		{`rm -fa val:"cache.local"`, `val:"cache.local"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			cmd, err := order.Parse(tt.input)
+			if err != nil {
+				t.Fatalf("parse error: %v", err)
+			}
+			if got := deleteKeyword(cmd); got != tt.want {
+				t.Errorf("keyword of del got %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+	// del -a deletes what find shows, so both take keywords as typed.
+	find, err := order.Parse(`find "my key" -old`)
+	if err != nil {
+		t.Fatalf("parse error: %v", err)
+	}
+	del, err := order.Parse(`del -a "my key" -old`)
+	if err != nil {
+		t.Fatalf("parse error: %v", err)
+	}
+	if deleteKeyword(del) != find.Text {
+		t.Errorf("keyword of del -a got %q, want %q of find", deleteKeyword(del), find.Text)
+	}
+}
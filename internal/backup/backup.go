@@ -2,29 +2,73 @@
 package backup
 
 import (
-	"encoding/json"
-	"find/internal/config"
-	"find/internal/files"
 	"find/internal/logs"
 	"find/internal/redish"
 	"fmt"
 	"github.com/go-redis/redis"
-	"os"
+	"sync"
 )
 
 // rds is a pointer of redis client.
 var rds *redis.Client
 
-// rdsKey is a redis key for representing backup.
-var rdsKey string
+// cursors are positions of the latest change pushed for each backup key,
+// which are empty until a full push.
+var cursors = make(map[string]string)
+
+// cursorMutex guards cursors, since notebooks are synchronized independently.
+var cursorMutex sync.Mutex
 
 func init() {
 	rds = redish.Client
-	rdsKey = config.RedisKey()
 }
 
-// Sync is used to pull or push redis backup, decided by different cases.
-func Sync(isNewNote bool, file *os.File, fileInfo os.FileInfo) error {
+// changesKey is used to get the redis key of the list of changes pushed after the latest backup.
+func changesKey(rdsKey string) string {
+	return rdsKey + ":changes"
+}
+
+// changedKey is used to get the redis key of the time of the latest change pushed.
+func changedKey(rdsKey string) string {
+	return rdsKey + ":changed"
+}
+
+// cursor is used to get the position of the latest change pushed for specified backup key.
+func cursor(rdsKey string) string {
+	cursorMutex.Lock()
+	defer cursorMutex.Unlock()
+	return cursors[rdsKey]
+}
+
+// setCursor is used to record the position of the latest change pushed for specified backup key.
+func setCursor(rdsKey, c string) {
+	cursorMutex.Lock()
+	defer cursorMutex.Unlock()
+	cursors[rdsKey] = c
+}
+
+// Local is the local side of backup, which is able to dump and load all notes as json.
+type Local interface {
+	// Dump is used to marshal all local notes into json, returning nil if there is no note.
+	Dump() ([]byte, error)
+	// Load is used to replace all local notes by json from backup.
+	Load(data []byte) error
+	// Apply is used to apply json of changes pulled from backup in order.
+	Apply(changes []string) error
+}
+
+// Incremental is implemented by locals recording their changes,
+// so that only the changes since last push are pushed rather than all notes.
+type Incremental interface {
+	// Cursor is used to get the position of the latest change, returning empty if not available.
+	Cursor() (string, error)
+	// Changes is used to get json of changes after the cursor, along with the position of the latest change.
+	// ok is false if the changes are not available any more.
+	Changes(cursor string) (changes []string, next string, ok bool, err error)
+}
+
+// Sync is used to pull or push redis backup of specified key, decided by different cases.
+func Sync(rdsKey string, isNewNote bool, lastModTime float64, local Local) error {
 	cmd := rds.ZCard(rdsKey)
 	size, err := cmd.Result()
 	if err != nil {
@@ -33,19 +77,18 @@ func Sync(isNewNote bool, file *os.File, fileInfo os.FileInfo) error {
 
 	// case1: If file is new, and redis backup is not empty, then pull.
 	if isNewNote && size > 0 {
-		err = pull(file)
+		err = pull(rdsKey, local)
 		if err != nil {
-			return fmt.Errorf("pull backup to %v error: %v", file, err)
+			return fmt.Errorf("pull backup error: %v", err)
 		}
 		return nil
 	}
 
-	lastModTime := float64(fileInfo.ModTime().Unix())
 	// case2: If file is not new, and redis backup is empty, then push.
 	if !isNewNote && size == 0 {
-		err = push(file, lastModTime)
+		err = push(rdsKey, local, lastModTime)
 		if err != nil {
-			return fmt.Errorf("push backup from %v error: %v", file, err)
+			return fmt.Errorf("push backup error: %v", err)
 		}
 		return nil
 	}
@@ -53,22 +96,29 @@ func Sync(isNewNote bool, file *os.File, fileInfo os.FileInfo) error {
 	// case3: If file is not new, and redis backup is not empty, then compare
 	// file's last modify time and redis' last backup time to decide pull or push.
 	if !isNewNote && size > 0 {
-		latestBak, err := getLatest()
+		latestBak, err := getLatest(rdsKey)
 		if err != nil {
 			return fmt.Errorf("get latest backup error: %v", err)
 		}
 		lastBakTime := latestBak.Score
+		changedTime, err := rds.Get(changedKey(rdsKey)).Float64()
+		if err != nil && err != redis.Nil {
+			return fmt.Errorf("get latest change time error: %v", err)
+		}
+		if changedTime > lastBakTime {
+			lastBakTime = changedTime
+		}
 		if lastBakTime > lastModTime {
-			err = pull(file)
+			err = pull(rdsKey, local)
 			if err != nil {
-				return fmt.Errorf("pull backup to %v error: %v", file, err)
+				return fmt.Errorf("pull backup error: %v", err)
 			}
 			return nil
 		}
 		if lastBakTime < lastModTime {
-			err = push(file, lastModTime)
+			err = push(rdsKey, local, lastModTime)
 			if err != nil {
-				return fmt.Errorf("push backup from %v error: %v", file, err)
+				return fmt.Errorf("push backup error: %v", err)
 			}
 			return nil
 		}
@@ -76,34 +126,46 @@ func Sync(isNewNote bool, file *os.File, fileInfo os.FileInfo) error {
 	return nil
 }
 
-// pull is used to sync newest backup from redis to file.
-func pull(file *os.File) error {
-	logs.Info("backup: pull start")
-	bak, err := getLatest()
+// pull is used to sync newest backup of specified key from redis to local.
+func pull(rdsKey string, local Local) error {
+	logs.Info("backup: pull %s start", rdsKey)
+	bak, err := getLatest(rdsKey)
 	if err != nil {
 		return fmt.Errorf("get latest bak error: %v", err)
 	}
 
-	var notes []string
 	jsonNotes := fmt.Sprintf("%s", bak.Member)
-	err = json.Unmarshal([]byte(jsonNotes), &notes)
+	err = local.Load([]byte(jsonNotes))
 	if err != nil {
-		return fmt.Errorf("json unmarshal of %v error: %v", jsonNotes, err)
+		return fmt.Errorf("load %d bytes of notes error: %v", len(jsonNotes), err)
 	}
 
-	if len(notes) > 0 {
-		err = files.WriteLinesToFile(file, &notes)
+	changes, err := rds.LRange(changesKey(rdsKey), 0, -1).Result()
+	if err != nil {
+		return fmt.Errorf("get changes error: %v", err)
+	}
+	if len(changes) > 0 {
+		err = local.Apply(changes)
 		if err != nil {
-			return fmt.Errorf("write %v to %v error: %v", notes, file, err)
+			return fmt.Errorf("apply %d changes error: %v", len(changes), err)
 		}
 	}
 
+	// Changes pulled needn't be pushed back.
+	if inc, ok := local.(Incremental); ok {
+		c, err := inc.Cursor()
+		if err != nil {
+			return fmt.Errorf("get cursor error: %v", err)
+		}
+		setCursor(rdsKey, c)
+	}
+
 	logs.Info("backup: pull finished")
 	return nil
 }
 
-// getLatest is used to fetch newest backup from redis, returning a pointer of redis zset and error.
-func getLatest() (*redis.Z, error) {
+// getLatest is used to fetch newest backup of specified key from redis, returning a pointer of redis zset and error.
+func getLatest(rdsKey string) (*redis.Z, error) {
 	cmd := rds.ZRangeWithScores(rdsKey, -1, -1)
 	bak, err := cmd.Result()
 	if err != nil {
@@ -112,30 +174,84 @@ func getLatest() (*redis.Z, error) {
 	return &bak[0], nil
 }
 
-// push is used to sync newest backup from file to redis.
-func push(file *os.File, lastModTime float64) error {
-	logs.Info("backup: push start")
-	notes, err := files.ReadLinesFromFile(file)
-	if err != nil {
-		return fmt.Errorf("read lines from %s error: %v", config.Conf.Find.NotePath, err)
+// push is used to sync newest backup of specified key from local to redis.
+// Only changes since last push are pushed if local is incremental,
+// otherwise all notes are pushed as a new backup.
+func push(rdsKey string, local Local, lastModTime float64) error {
+	logs.Info("backup: push %s start", rdsKey)
+	inc, incremental := local.(Incremental)
+	if incremental && cursor(rdsKey) != "" {
+		pushed, err := pushChanges(rdsKey, inc, lastModTime)
+		if err != nil {
+			return fmt.Errorf("push changes error: %v", err)
+		}
+		if pushed {
+			logs.Info("backup: push finished")
+			return nil
+		}
 	}
 
-	if len(notes) == 0 {
-		return nil
+	// Take the cursor before dumping, so that no change is missed if notes change meanwhile.
+	var next string
+	if incremental {
+		var err error
+		next, err = inc.Cursor()
+		if err != nil {
+			return fmt.Errorf("get cursor error: %v", err)
+		}
 	}
 
-	jsonNotes, err := json.Marshal(notes)
+	jsonNotes, err := local.Dump()
 	if err != nil {
-		return fmt.Errorf("json marshal of %v error: %v", notes, err)
+		return fmt.Errorf("dump notes error: %v", err)
+	}
+
+	if jsonNotes == nil {
+		return nil
 	}
 
 	jsonStr := string(jsonNotes)
-	rds.ZAdd(rdsKey, redis.Z{
-		// file's last modify time
-		Score: lastModTime,
-		// json of file's data
-		Member: jsonStr,
+	_, err = rds.TxPipelined(func(pipe redis.Pipeliner) error {
+		pipe.ZAdd(rdsKey, redis.Z{
+			// file's last modify time
+			Score: lastModTime,
+			// json of file's data
+			Member: jsonStr,
+		})
+		// Changes are included in the new backup.
+		pipe.Del(changesKey(rdsKey), changedKey(rdsKey))
+		return nil
 	})
+	if err != nil {
+		return fmt.Errorf("add backup error: %v", err)
+	}
+	setCursor(rdsKey, next)
 	logs.Info("backup: push finished")
 	return nil
 }
+
+// pushChanges is used to append changes since last push to redis backup of specified key,
+// returning false if the changes are not available any more.
+func pushChanges(rdsKey string, inc Incremental, lastModTime float64) (bool, error) {
+	changes, next, ok, err := inc.Changes(cursor(rdsKey))
+	if err != nil || !ok {
+		return false, err
+	}
+	if len(changes) > 0 {
+		values := make([]interface{}, 0, len(changes))
+		for _, c := range changes {
+			values = append(values, c)
+		}
+		_, err = rds.TxPipelined(func(pipe redis.Pipeliner) error {
+			pipe.RPush(changesKey(rdsKey), values...)
+			pipe.Set(changedKey(rdsKey), lastModTime, 0)
+			return nil
+		})
+		if err != nil {
+			return false, err
+		}
+	}
+	setCursor(rdsKey, next)
+	logs.Info("backup: pushed %d changes", len(changes))
+	return true, nil
+}
@@ -0,0 +1,159 @@
+package config
+
+import (
+	"find/internal/files"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+// aliasesKey is the key of the section of aliases in the config yaml.
+const aliasesKey = "aliases"
+
+// Alias is what a short name stands for, which is an order line, or order lines run in sequence as a macro.
+type Alias []string
+
+// UnmarshalYAML is used to read an alias from either a line or a list of lines.
+func (a *Alias) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var line string
+	if err := unmarshal(&line); err == nil {
+		*a = Alias{line}
+		return nil
+	}
+	var lines []string
+	if err := unmarshal(&lines); err != nil {
+		return err
+	}
+	*a = lines
+	return nil
+}
+
+// MarshalYAML is used to write an alias as a line if it has only one, or a list of lines otherwise.
+func (a Alias) MarshalYAML() (interface{}, error) {
+	if len(a) == 1 {
+		return a[0], nil
+	}
+	return []string(a), nil
+}
+
+// AliasNames is used to get names of all aliases, sorted.
+func AliasNames() []string {
+	names := make([]string, 0, len(Conf.Aliases))
+	for name := range Conf.Aliases {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// SetAlias is used to define or redefine an alias, which is saved into the config yaml at once.
+func SetAlias(name string, alias Alias) error {
+	old, existed := Conf.Aliases[name]
+	if Conf.Aliases == nil {
+		Conf.Aliases = make(map[string]Alias)
+	}
+	Conf.Aliases[name] = alias
+	err := saveAliases()
+	if err != nil {
+		if existed {
+			Conf.Aliases[name] = old
+		} else {
+			delete(Conf.Aliases, name)
+		}
+	}
+	return err
+}
+
+// RemoveAlias is used to remove an alias, which is saved into the config yaml at once,
+// returning error if the alias doesn't exist.
+func RemoveAlias(name string) error {
+	old, ok := Conf.Aliases[name]
+	if !ok {
+		return fmt.Errorf("alias %s not found", name)
+	}
+	delete(Conf.Aliases, name)
+	err := saveAliases()
+	if err != nil {
+		Conf.Aliases[name] = old
+	}
+	return err
+}
+
+// saveAliases is used to rewrite the section of aliases in the config yaml, keeping other bytes as they are,
+// including blank lines, comments and line endings out of the section.
+func saveAliases() error {
+	data, err := os.ReadFile(confPath)
+	if err != nil {
+		return fmt.Errorf("read config error: %v", err)
+	}
+	data, err = replaceAliases(data, Conf.Aliases)
+	if err != nil {
+		return err
+	}
+	// the content always ends with a line break, after which there is no line.
+	lines := strings.Split(strings.TrimSuffix(string(data), "\n"), "\n")
+	err = files.WriteLinesToPathAtomically(confPath, &lines)
+	if err != nil {
+		return fmt.Errorf("write config error: %v", err)
+	}
+	return nil
+}
+
+// replaceAliases is used to replace the section of aliases in data of the config yaml by aliases,
+// or append the section if there is none, returning the new data which ends with a line break, and error.
+// The section goes on from 'aliases:' to its last indented line before the next key, so that blank lines and
+// comments between aliases belong to it, while those before the next key are kept.
+// Comments in the section are lost, since the section is written from aliases.
+func replaceAliases(data []byte, aliases map[string]Alias) ([]byte, error) {
+	text := string(data)
+	newline := "\n"
+	if strings.Contains(text, "\r\n") {
+		newline = "\r\n"
+	}
+	var section strings.Builder
+	section.WriteString(aliasesKey + ":" + newline)
+	if len(aliases) > 0 {
+		out, err := yaml.Marshal(aliases)
+		if err != nil {
+			return nil, fmt.Errorf("marshal aliases error: %v", err)
+		}
+		for _, line := range strings.Split(strings.TrimRight(string(out), "\n"), "\n") {
+			section.WriteString("  " + line + newline)
+		}
+	}
+
+	start, end := -1, -1
+	for pos := 0; pos < len(text); {
+		next := len(text)
+		if i := strings.IndexByte(text[pos:], '\n'); i != -1 {
+			next = pos + i + 1
+		}
+		line := strings.TrimRight(text[pos:next], "\r\n")
+		switch {
+		case start == -1:
+			if strings.HasPrefix(line, aliasesKey+":") {
+				start, end = pos, next
+			}
+		case strings.TrimSpace(line) == "" || strings.HasPrefix(line, "#"):
+		case strings.HasPrefix(line, " ") || strings.HasPrefix(line, "\t"):
+			end = next
+		default:
+			next = len(text)
+		}
+		pos = next
+	}
+	if start == -1 {
+		if text != "" && !strings.HasSuffix(text, "\n") {
+			text += newline
+		}
+		return []byte(text + section.String()), nil
+	}
+	rest := text[end:]
+	if rest != "" && !strings.HasSuffix(rest, "\n") {
+		rest += newline
+	}
+	return []byte(text[:start] + section.String() + rest), nil
+}
@@ -0,0 +1,86 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestReplaceAliases(t *testing.T) {
+	aliases := map[string]Alias{"wx": {"weather -a $1"}, "morning": {"wx 北京", "find remind@"}}
+	section := "aliases:\n  morning:\n  - wx 北京\n  - find remind@\n  wx: weather -a $1\n"
+	tests := []struct {
+		name    string
+		data    string
+		aliases map[string]Alias
+		want    string
+	}{
+		{
+			"blank lines and comments",
+			"# FIND\n\nfind:\n  notePath: FIND.txt\n\naliases:\n  old: find x\n\n# work\n  prod: find prod\n\n# log\nlog:\n  level: info\n",
+			aliases,
+			"# FIND\n\nfind:\n  notePath: FIND.txt\n\n" + section + "\n# log\nlog:\n  level: info\n",
+		},
+		{
+			"last section without a line break",
+			"find:\n\n  notePath: FIND.txt\naliases:\n  old: find x",
+			aliases,
+			"find:\n\n  notePath: FIND.txt\n" + section,
+		},
+		{
+			"no section",
+			"find:\n  notePath: FIND.txt\n\n# end",
+			aliases,
+			"find:\n  notePath: FIND.txt\n\n# end\n" + section,
+		},
+		{
+			"empty section followed by a key",
+			"aliases:\nlog:\n  level: info\n",
+			nil,
+			"aliases:\nlog:\n  level: info\n",
+		},
+		{
+			"windows line endings",
+			"find:\r\n  notePath: FIND.txt\r\n\r\naliases:\r\n  old: find x\r\nlog: {}\r\n",
+			map[string]Alias{"wx": {"weather"}},
+			"find:\r\n  notePath: FIND.txt\r\n\r\naliases:\r\n  wx: weather\r\nlog: {}\r\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := replaceAliases([]byte(tt.data), tt.aliases)
+			if err != nil {
+				t.Fatalf("replace error: %v", err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("replace got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSetAlias(t *testing.T) {
+	oldConf, oldPath := Conf, confPath
+	t.Cleanup(func() { Conf, confPath = oldConf, oldPath })
+	confPath = filepath.Join(t.TempDir(), "FIND.yml")
+	data := "# FIND\n\nfind:\n  notePath: FIND.txt\n\naliases:\n  a: find a\n\n# kept\n  b: find b\n"
+	if err := os.WriteFile(confPath, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+	Conf = Config{Aliases: map[string]Alias{"a": {"find a"}, "b": {"find b"}}}
+
+	if err := SetAlias("c", Alias{"find c", "find d"}); err != nil {
+		t.Fatalf("set alias error: %v", err)
+	}
+	if err := RemoveAlias("a"); err != nil {
+		t.Fatalf("remove alias error: %v", err)
+	}
+	got, err := os.ReadFile(confPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "# FIND\n\nfind:\n  notePath: FIND.txt\n\naliases:\n  b: find b\n  c:\n  - find c\n  - find d\n"
+	if string(got) != want {
+		t.Errorf("config after set and remove is %q, want %q", got, want)
+	}
+}
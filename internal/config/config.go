@@ -9,6 +9,7 @@ import (
 	"gopkg.in/yaml.v2"
 	"io/ioutil"
 	"os"
+	"path/filepath"
 	"strconv"
 	"strings"
 )
@@ -16,8 +17,14 @@ import (
 // Config map to program config yaml.
 type Config struct {
 	Find struct {
-		NotePath string `yaml:"notePath"`
-		Username string `yaml:"username"`
+		NotePath         string `yaml:"notePath"`
+		Username         string `yaml:"username"`
+		Store            string `yaml:"store"`
+		DbPath           string `yaml:"dbPath"`
+		JournalPath      string `yaml:"journalPath"`
+		HistorySize      int    `yaml:"historySize"`
+		TrashDays        int    `yaml:"trashDays"`
+		InputHistoryPath string `yaml:"inputHistoryPath"`
 	} `yaml:"find"`
 	Log struct {
 		Enabled bool   `yaml:"enabled"`
@@ -31,7 +38,9 @@ type Config struct {
 			Db       int    `yaml:"db"`
 		} `yaml:"redis"`
 	} `yaml:"backup"`
-	Reminder struct {
+	Notebooks []Notebook       `yaml:"notebooks"`
+	Aliases   map[string]Alias `yaml:"aliases"`
+	Reminder  struct {
 		Enabled         bool   `yaml:"enabled"`
 		Type            string `yaml:"type"`
 		IntervalSeconds int    `yaml:"interval-seconds"`
@@ -44,16 +53,36 @@ type Config struct {
 	} `yaml:"reminder"`
 }
 
+// Notebook is a named set of notes with its own files, backup and reminder.
+// Empty configs of notebooks listed in 'notebooks' fall back to the ones of the default notebook.
+type Notebook struct {
+	Name        string `yaml:"name"`
+	NotePath    string `yaml:"notePath"`
+	Username    string `yaml:"username"`
+	Store       string `yaml:"store"`
+	DbPath      string `yaml:"dbPath"`
+	JournalPath string `yaml:"journalPath"`
+	Reminder    struct {
+		Enabled *bool  `yaml:"enabled"`
+		Type    string `yaml:"type"`
+	} `yaml:"reminder"`
+}
+
+// DefaultNotebook is the name of the notebook configured by 'find'.
+const DefaultNotebook = "default"
+
 // all configs
 var Conf Config
 
+// confPath is where configs are kept, which is unchangeable.
+var confPath string
+
 func init() {
 	homedir, err := os.UserHomeDir()
 	if err != nil {
 		panic(err)
 	}
-	// default unchangeable config path
-	confPath := homedir + "\\FIND.yml"
+	confPath = homedir + "\\FIND.yml"
 
 	// If config yaml not exists, then init.
 	if _, err = os.Stat(confPath); err != nil {
@@ -77,14 +106,76 @@ func init() {
 	}
 }
 
-// RedisKey is used to get a redis key for representing backup.
-func RedisKey() string {
-	if Conf.Find.Username != "" {
-		return "find:backup:" + Conf.Find.Username
+// RedisKey is used to get a redis key for representing backup of specified username,
+// returning empty if the username is empty.
+func RedisKey(username string) string {
+	if username != "" {
+		return "find:backup:" + username
 	}
 	return ""
 }
 
+// Notebooks is used to get configs of all notebooks, the default one first,
+// filling empty configs of other notebooks in by those of the default one.
+// Paths left out are beside those of the default one like FIND.work.txt, and username is never filled,
+// since notebooks of the same username would overwrite backups of each other.
+func Notebooks() []Notebook {
+	enabled := Conf.Reminder.Enabled
+	def := Notebook{
+		Name:        DefaultNotebook,
+		NotePath:    Conf.Find.NotePath,
+		Username:    Conf.Find.Username,
+		Store:       Conf.Find.Store,
+		DbPath:      Conf.Find.DbPath,
+		JournalPath: Conf.Find.JournalPath,
+	}
+	def.Reminder.Enabled = &enabled
+	def.Reminder.Type = Conf.Reminder.Type
+
+	notebooks := []Notebook{def}
+	for _, n := range Conf.Notebooks {
+		if n.Name == "" || n.Name == DefaultNotebook {
+			continue
+		}
+		if n.NotePath == "" {
+			n.NotePath = beside(def.NotePath, n.Name)
+		}
+		if n.Store == "" {
+			n.Store = def.Store
+		}
+		if n.DbPath == "" && def.DbPath != "" {
+			n.DbPath = beside(def.DbPath, n.Name)
+		}
+		if n.JournalPath == "" && def.JournalPath != "" {
+			n.JournalPath = beside(def.JournalPath, n.Name)
+		}
+		if n.Reminder.Enabled == nil {
+			n.Reminder.Enabled = &enabled
+		}
+		if n.Reminder.Type == "" {
+			n.Reminder.Type = def.Reminder.Type
+		}
+		notebooks = append(notebooks, n)
+	}
+	return notebooks
+}
+
+// beside is used to get the path of a file of notebook of specified name beside path of the default one,
+// like FIND.work.txt beside FIND.txt.
+func beside(path, name string) string {
+	ext := filepath.Ext(path)
+	return strings.TrimSuffix(path, ext) + "." + name + ext
+}
+
+// InputHistoryPath is used to get where lines typed at the prompt are kept,
+// which is beside notes like FIND.history if it's not configured.
+func InputHistoryPath() string {
+	if Conf.Find.InputHistoryPath != "" {
+		return Conf.Find.InputHistoryPath
+	}
+	return strings.TrimSuffix(Conf.Find.NotePath, filepath.Ext(Conf.Find.NotePath)) + ".history"
+}
+
 // RedisConf is used to get redis config for backup.
 func RedisConf() *redis.Options {
 	return &redis.Options{
@@ -116,6 +207,37 @@ func initYaml(confPath string) error {
 		"  notePath: " + homedir + "\\FIND.txt",
 		"  ## username is necessary for backup.",
 		"  username: " + _uuid.String(),
+		"  ## store is where notes are kept, for now support:",
+		"  ## 1.text file at notePath(text), which is default,",
+		"  ## 2.sqlite database at dbPath(sqlite),",
+		"  ## 3.append-only journal at journalPath(journal).",
+		"  ## Notes at notePath are imported when switching to a new database or journal.",
+		"  store: text",
+		"  dbPath: " + homedir + "\\FIND.db",
+		"  journalPath: " + homedir + "\\FIND.journal",
+		"  ## historySize is how many revisions are kept for each note, default 10.",
+		"  historySize: 10",
+		"  ## trashDays is how many days deleted notes are kept in trash, default 30.",
+		"  trashDays: 30",
+		"  ## inputHistoryPath is where lines typed at the prompt are kept, which can be searched by Ctrl-R.",
+		"  inputHistoryPath: " + homedir + "\\FIND.history",
+		"## notebooks are named sets of notes besides the default one above,",
+		"## each with its own files, backup and reminder, for example:",
+		"## - name: work",
+		"##   notePath: " + homedir + "\\FIND.work.txt",
+		"##   username: another-uuid-for-backup",
+		"##   store: text",
+		"##   reminder:",
+		"##     enabled: true",
+		"##     type: email",
+		"notebooks:",
+		"## aliases are short names of order lines, where $1, $2... are replaced by words after the name,",
+		"## and $@ by all of them, or lists of order lines run in sequence as macros, for example:",
+		"## prod: find prod db $@",
+		"## morning:",
+		"##   - weather -a 北京市昌平区",
+		"##   - find remind@",
+		"aliases:",
 		"log:",
 		"  enabled: true",
 		"  path: " + homedir + "\\FIND.log",
@@ -163,6 +285,14 @@ func Configs() []string {
 		"find:",
 		"  notePath: " + Conf.Find.NotePath,
 		"  username: " + Conf.Find.Username,
+		"  store: " + Conf.Find.Store,
+		"  dbPath: " + Conf.Find.DbPath,
+		"  journalPath: " + Conf.Find.JournalPath,
+		"  historySize: " + strconv.Itoa(Conf.Find.HistorySize),
+		"  trashDays: " + strconv.Itoa(Conf.Find.TrashDays),
+		"  inputHistoryPath: " + InputHistoryPath(),
+		"notebooks: " + strings.Join(notebookNames(), ","),
+		"aliases: " + strings.Join(AliasNames(), ","),
 		"log:",
 		"  enabled: " + strconv.FormatBool(Conf.Log.Enabled),
 		"  path: " + Conf.Log.Path,
@@ -183,3 +313,13 @@ func Configs() []string {
 		"    authCode: " + Conf.Reminder.Email.AuthCode,
 	}
 }
+
+// notebookNames is used to get names of all notebooks, returning a string slice.
+func notebookNames() []string {
+	notebooks := Notebooks()
+	names := make([]string, 0, len(notebooks))
+	for _, n := range notebooks {
+		names = append(names, n.Name)
+	}
+	return names
+}
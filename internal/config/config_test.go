@@ -0,0 +1,46 @@
+package config
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestNotebooks(t *testing.T) {
+	old := Conf
+	t.Cleanup(func() { Conf = old })
+	Conf = Config{}
+	Conf.Find.NotePath = `C:\Users\me\FIND.txt`
+	Conf.Find.Username = "uuid"
+	Conf.Find.Store = "sqlite"
+	Conf.Find.DbPath = `C:\Users\me\FIND.db`
+	Conf.Reminder.Enabled = true
+	Conf.Reminder.Type = "email"
+	disabled := false
+	Conf.Notebooks = []Notebook{
+		{Name: "work"},
+		{Name: ""},
+		{Name: DefaultNotebook, NotePath: "ignored.txt"},
+		{Name: "home", NotePath: `D:\home.txt`, Username: "home-uuid", Store: "journal", DbPath: `D:\home.db`},
+	}
+	Conf.Notebooks[3].Reminder.Enabled = &disabled
+	Conf.Notebooks[3].Reminder.Type = "toast"
+
+	notebooks := Notebooks()
+	type summary struct {
+		name, notePath, username, store, dbPath, journalPath, reminder string
+		enabled                                                        bool
+	}
+	got := make([]summary, 0, len(notebooks))
+	for _, n := range notebooks {
+		got = append(got, summary{n.Name, n.NotePath, n.Username, n.Store, n.DbPath, n.JournalPath, n.Reminder.Type,
+			*n.Reminder.Enabled})
+	}
+	want := []summary{
+		{DefaultNotebook, `C:\Users\me\FIND.txt`, "uuid", "sqlite", `C:\Users\me\FIND.db`, "", "email", true},
+		{"work", `C:\Users\me\FIND.work.txt`, "", "sqlite", `C:\Users\me\FIND.work.db`, "", "email", true},
+		{"home", `D:\home.txt`, "home-uuid", "journal", `D:\home.db`, "", "toast", false},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("notebooks got %+v, want %+v", got, want)
+	}
+}
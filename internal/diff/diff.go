@@ -0,0 +1,61 @@
+// Package diff implements methods for comparing texts line by line.
+package diff
+
+import "strings"
+
+const (
+	// Same starts a line which is in both texts.
+	Same = "  "
+	// Removed starts a line which is only in the old text.
+	Removed = "- "
+	// Added starts a line which is only in the new text.
+	Added = "+ "
+)
+
+// Lines is used to compare the old text with the new one by their longest common lines,
+// returning lines of both texts in order, each starting with Same, Removed or Added.
+func Lines(old, new string) []string {
+	a := strings.Split(old, "\n")
+	b := strings.Split(new, "\n")
+
+	// lcs[i][j] is the length of longest common lines of a[i:] and b[j:].
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else if lcs[i+1][j] >= lcs[i][j+1] {
+				lcs[i][j] = lcs[i+1][j]
+			} else {
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+
+	results := make([]string, 0, len(a)+len(b))
+	i, j := 0, 0
+	for i < len(a) && j < len(b) {
+		switch {
+		case a[i] == b[j]:
+			results = append(results, Same+a[i])
+			i++
+			j++
+		case lcs[i+1][j] >= lcs[i][j+1]:
+			results = append(results, Removed+a[i])
+			i++
+		default:
+			results = append(results, Added+b[j])
+			j++
+		}
+	}
+	for ; i < len(a); i++ {
+		results = append(results, Removed+a[i])
+	}
+	for ; j < len(b); j++ {
+		results = append(results, Added+b[j])
+	}
+	return results
+}
@@ -4,7 +4,10 @@ package files
 import (
 	"bufio"
 	"fmt"
+	"io"
 	"os"
+	"path/filepath"
+	"strings"
 )
 
 // ReadLinesFromPath is used to get all data from file of specified path,
@@ -23,21 +26,25 @@ func ReadLinesFromPath(path string) ([]string, error) {
 
 // ReadLinesFromFile is used to get all data from specified file,
 // returning a string slice of file data and error.
+// There is no limit on the length of a line.
 func ReadLinesFromFile(file *os.File) ([]string, error) {
-	scanner := bufio.NewScanner(file)
+	reader := bufio.NewReader(file)
 	var lines []string
-	for scanner.Scan() {
-		line := scanner.Text()
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil && err != io.EOF {
+			return nil, err
+		}
+
+		line = strings.TrimRight(line, "\r\n")
 		if line != "" {
 			lines = append(lines, line)
 		}
-	}
 
-	if scanner.Err() != nil {
-		return nil, scanner.Err()
+		if err == io.EOF {
+			return lines, nil
+		}
 	}
-
-	return lines, nil
 }
 
 // WriteLinesToFile is used to persist data into specified file.
@@ -52,3 +59,64 @@ func WriteLinesToFile(file *os.File, lines *[]string) error {
 
 	return w.Flush()
 }
+
+// WriteLinesToPath is used to persist data into file of specified path,
+// which is created or truncated before writing.
+func WriteLinesToPath(path string, lines *[]string) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = file.Close()
+	}()
+
+	return WriteLinesToFile(file, lines)
+}
+
+// WriteLinesToPathAtomically is used to persist data into file of specified path safely.
+// Data is written to a temporary file beside and flushed to disk, which then replaces the file,
+// so the file is either old or new even if the program crashes or the disk is full.
+func WriteLinesToPathAtomically(path string, lines *[]string) (err error) {
+	dir := filepath.Dir(path)
+	tmp, err := os.CreateTemp(dir, filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			_ = tmp.Close()
+			_ = os.Remove(tmp.Name())
+		}
+	}()
+
+	err = WriteLinesToFile(tmp, lines)
+	if err != nil {
+		return err
+	}
+	err = tmp.Sync()
+	if err != nil {
+		return err
+	}
+	err = tmp.Close()
+	if err != nil {
+		return err
+	}
+
+	// Keep permission of the old file, the temporary file is only readable by owner.
+	if fileInfo, statErr := os.Stat(path); statErr == nil {
+		_ = os.Chmod(tmp.Name(), fileInfo.Mode())
+	}
+	err = os.Rename(tmp.Name(), path)
+	if err != nil {
+		return err
+	}
+
+	// Flush the rename to disk as well. Directories can't be opened on windows,
+	// where the rename is already durable when it returns.
+	if d, dirErr := os.Open(dir); dirErr == nil {
+		_ = d.Sync()
+		_ = d.Close()
+	}
+	return nil
+}
@@ -0,0 +1,192 @@
+// Package fuzzy implements methods for matching text approximately, tolerating typos and scattered characters.
+package fuzzy
+
+import "unicode"
+
+// Match is used to find pattern in text ignoring the case, in the tightest way it can,
+// returning indexes of matched runes of text, the score, and if it matched.
+// The score is 1 if text contains pattern, between 0.5 and 1 if runes of pattern are in text in order
+// but scattered, and below 0.5 if a part of text is pattern with a few typos,
+// like missing, extra, wrong or swapped runes.
+func Match(text, pattern string) ([]int, float64, bool) {
+	t := lower(text)
+	p := lower(pattern)
+	if len(p) == 0 {
+		return nil, 1, true
+	}
+
+	if i := index(t, p); i != -1 {
+		return span(i, len(p)), 1, true
+	}
+
+	if positions := subsequence(t, p); positions != nil {
+		width := positions[len(positions)-1] - positions[0] + 1
+		return positions, 0.5 + 0.4*float64(len(p))/float64(width), true
+	}
+
+	maxTypos := Typos(len(p))
+	if maxTypos == 0 {
+		return nil, 0, false
+	}
+	best, start, end := -1, 0, 0
+	for l := len(p) - maxTypos; l <= len(p)+maxTypos; l++ {
+		if l <= 0 || l > len(t) {
+			continue
+		}
+		for i := 0; i+l <= len(t); i++ {
+			d := distance(t[i:i+l], p)
+			if best == -1 || d < best {
+				best, start, end = d, i, i+l
+			}
+		}
+	}
+	if best == -1 || best > maxTypos {
+		return nil, 0, false
+	}
+	positions := make([]int, 0, end-start)
+	for i := start; i < end; i++ {
+		if containsRune(p, t[i]) {
+			positions = append(positions, i)
+		}
+	}
+	return positions, 0.4 * (1 - float64(best)/float64(len(p))), true
+}
+
+// Typos is used to get how many typos are tolerated for a pattern of specified length in runes.
+func Typos(length int) int {
+	switch {
+	case length <= 2:
+		return 0
+	case length <= 5:
+		return 1
+	case length <= 9:
+		return 2
+	default:
+		return 3
+	}
+}
+
+// Distance is used to count the fewest edits turning a into b ignoring the case,
+// where an edit inserts, deletes or replaces a rune, or swaps two adjacent runes.
+func Distance(a, b string) int {
+	return distance(lower(a), lower(b))
+}
+
+// distance is the optimal string alignment distance of a and b.
+func distance(a, b []rune) int {
+	// d[i][j] is the distance of a[:i] and b[:j].
+	d := make([][]int, len(a)+1)
+	for i := range d {
+		d[i] = make([]int, len(b)+1)
+		d[i][0] = i
+	}
+	for j := range d[0] {
+		d[0][j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			d[i][j] = min(d[i-1][j]+1, d[i][j-1]+1, d[i-1][j-1]+cost)
+			if i > 1 && j > 1 && a[i-1] == b[j-2] && a[i-2] == b[j-1] {
+				d[i][j] = min(d[i][j], d[i-2][j-2]+1)
+			}
+		}
+	}
+	return d[len(a)][len(b)]
+}
+
+// subsequence is used to find runes of p in t in order within the shortest window,
+// returning indexes of them or nil if not all are found.
+func subsequence(t, p []rune) []int {
+	var best []int
+	for start := 0; start < len(t); start++ {
+		if t[start] != p[0] {
+			continue
+		}
+		// Match forward from start, then backward from the end to tighten the window.
+		j, end := 0, -1
+		for i := start; i < len(t); i++ {
+			if t[i] == p[j] {
+				j++
+				if j == len(p) {
+					end = i
+					break
+				}
+			}
+		}
+		if end == -1 {
+			break
+		}
+		positions := make([]int, len(p))
+		j = len(p) - 1
+		for i := end; i >= start && j >= 0; i-- {
+			if t[i] == p[j] {
+				positions[j] = i
+				j--
+			}
+		}
+		if best == nil || positions[len(p)-1]-positions[0] < best[len(p)-1]-best[0] {
+			best = positions
+		}
+		start = positions[0]
+	}
+	return best
+}
+
+// index is used to find the first p in t, returning the index or -1.
+func index(t, p []rune) int {
+	for i := 0; i+len(p) <= len(t); i++ {
+		if equal(t[i:i+len(p)], p) {
+			return i
+		}
+	}
+	return -1
+}
+
+// span is used to get length indexes starting from start.
+func span(start, length int) []int {
+	positions := make([]int, length)
+	for i := range positions {
+		positions[i] = start + i
+	}
+	return positions
+}
+
+func equal(a, b []rune) bool {
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func containsRune(runes []rune, r rune) bool {
+	for _, c := range runes {
+		if c == r {
+			return true
+		}
+	}
+	return false
+}
+
+// lower is used to get lower-case runes of s, one for each rune of s.
+func lower(s string) []rune {
+	runes := []rune(s)
+	for i, r := range runes {
+		runes[i] = unicode.ToLower(r)
+	}
+	return runes
+}
+
+func min(a int, others ...int) int {
+	for _, o := range others {
+		if o < a {
+			a = o
+		}
+	}
+	return a
+}
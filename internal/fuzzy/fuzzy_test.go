@@ -0,0 +1,62 @@
+package fuzzy
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestMatch(t *testing.T) {
+	tests := []struct {
+		text      string
+		pattern   string
+		positions []int
+		// low and high bound the score.
+		low, high float64
+		ok        bool
+	}{
+		{"MySQL", "sql", []int{2, 3, 4}, 1, 1, true},
+		{"anything", "", nil, 1, 1, true},
+		{"my-sql", "msl", []int{0, 3, 5}, 0.5, 0.9, true},
+		{"a_b_c_abc", "abc", []int{6, 7, 8}, 1, 1, true},
+		{"a-b-c", "abc", []int{0, 2, 4}, 0.5, 0.9, true},
+		{"mysql", "myslq", []int{0, 1, 2, 3}, 0, 0.5, true},
+		{"postgres", "postgers", []int{0, 1, 2, 3, 4, 5, 6, 7}, 0, 0.5, true},
+		{"北京市昌平区", "昌平", []int{3, 4}, 1, 1, true},
+		{"redis", "xy", nil, 0, 0, false},
+		{"redis", "mongo", nil, 0, 0, false},
+	}
+	for _, tt := range tests {
+		positions, score, ok := Match(tt.text, tt.pattern)
+		if ok != tt.ok || !reflect.DeepEqual(positions, tt.positions) || score < tt.low || score > tt.high {
+			t.Errorf("match %q in %q got %v %v %v, want %v with score in [%v, %v] and %v",
+				tt.pattern, tt.text, positions, score, ok, tt.positions, tt.low, tt.high, tt.ok)
+		}
+	}
+}
+
+func TestDistance(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{"", "", 0},
+		{"abc", "ABC", 0},
+		{"abc", "", 3},
+		{"mysql", "myslq", 1},
+		{"kitten", "sitting", 3},
+		{"北京", "京北", 1},
+	}
+	for _, tt := range tests {
+		if got := Distance(tt.a, tt.b); got != tt.want {
+			t.Errorf("distance of %q and %q = %d, want %d", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+func TestTypos(t *testing.T) {
+	for length, want := range map[int]int{1: 0, 2: 0, 3: 1, 5: 1, 6: 2, 9: 2, 10: 3, 30: 3} {
+		if got := Typos(length); got != want {
+			t.Errorf("typos of %d runes = %d, want %d", length, got, want)
+		}
+	}
+}
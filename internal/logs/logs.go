@@ -7,11 +7,18 @@ import (
 	"io"
 	"log"
 	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
 )
 
 // enabled is the switch of log.
 var enabled bool
 
+// output is where error logs are shown to the user.
+var output io.Writer = os.Stdout
+
 // levelCode describes log level.
 var levelCode int
 
@@ -69,34 +76,104 @@ func getLevelCode() (int, error) {
 	return -1, fmt.Errorf("invalid log level: %s", config.Conf.Log.Level)
 }
 
+// mask replaces secrets in logs.
+const mask = "******"
+
+// shortSecret is the length under which a secret is replaced only as a whole word,
+// so that a short one like 'a' won't ruin every log.
+const shortSecret = 4
+
+// secrets are values which must never be recorded, like values of secret notes.
+var secrets = struct {
+	sync.RWMutex
+	values map[string]bool
+	// sorted are values from the longest, so that a secret containing another one is replaced as a whole.
+	sorted []string
+	// words match short secrets as whole words.
+	words map[string]*regexp.Regexp
+}{values: make(map[string]bool), words: make(map[string]*regexp.Regexp)}
+
+// Redact is used to register values which must never be recorded,
+// each of them and each of its lines will be replaced by '******' in all logs from now on.
+func Redact(values ...string) {
+	secrets.Lock()
+	defer secrets.Unlock()
+	changed := false
+	for _, value := range values {
+		for _, v := range append(strings.Split(value, "\n"), value) {
+			v = strings.TrimSpace(v)
+			if v == "" || secrets.values[v] {
+				continue
+			}
+			secrets.values[v] = true
+			secrets.sorted = append(secrets.sorted, v)
+			if len([]rune(v)) < shortSecret {
+				secrets.words[v] = regexp.MustCompile(`(^|[^\pL\pN])` + regexp.QuoteMeta(v) + `($|[^\pL\pN])`)
+			}
+			changed = true
+		}
+	}
+	if changed {
+		sort.SliceStable(secrets.sorted, func(i, j int) bool {
+			return len(secrets.sorted[i]) > len(secrets.sorted[j])
+		})
+	}
+}
+
+// redact is used to replace registered secrets in a formatted log.
+func redact(format string, v ...interface{}) string {
+	s := fmt.Sprintf(format, v...)
+	secrets.RLock()
+	defer secrets.RUnlock()
+	for _, secret := range secrets.sorted {
+		if word, ok := secrets.words[secret]; ok {
+			s = word.ReplaceAllString(s, "${1}"+mask+"${2}")
+			continue
+		}
+		s = strings.ReplaceAll(s, secret, mask)
+	}
+	return s
+}
+
+// Secret is used to check if text contains any registered secret.
+func Secret(text string) bool {
+	return redact("%s", text) != text
+}
+
 // Debug is used to record log of debug level.
 func Debug(format string, v ...interface{}) {
 	if enabled && levelCode <= levelCodeDebug {
-		log.Printf("[debug] "+format, v...)
+		log.Print("[debug] " + redact(format, v...))
 	}
 }
 
 // Info is used to record log of info level.
 func Info(format string, v ...interface{}) {
 	if enabled && levelCode <= levelCodeInfo {
-		log.Printf("[info] "+format, v...)
+		log.Print("[info] " + redact(format, v...))
 	}
 }
 
 // Warn is used to record log of warn level.
 func Warn(format string, v ...interface{}) {
 	if enabled && levelCode <= levelCodeWarn {
-		log.Printf("[warn] "+format, v...)
+		log.Print("[warn] " + redact(format, v...))
 	}
 }
 
 // Error is used to record log of error level.
-// Only error log uses standard output which means it'll directly show to the user.
+// Only error log is shown to the user, on standard output by default.
+// Like all logs, registered secrets are replaced in both outputs.
 func Error(format string, v ...interface{}) {
 	if enabled && levelCode <= levelCodeError {
-		log.Printf("[error] "+format, v...)
+		log.Print("[error] " + redact(format, v...))
 	}
-	fmt.Printf(format, v...)
+	_, _ = fmt.Fprint(output, redact(format, v...))
+}
+
+// SetOutput is used to change where error logs are shown to the user, which is standard output by default.
+func SetOutput(w io.Writer) {
+	output = w
 }
 
 // Config is used to record all configs.
@@ -0,0 +1,26 @@
+package logs
+
+import "testing"
+
+func TestRedact(t *testing.T) {
+	Redact("s3cr3t-token", "line one\nline two", "ab")
+	tests := []struct {
+		name   string
+		format string
+		v      []interface{}
+		want   string
+	}{
+		{"whole secret", "put %s", []interface{}{"s3cr3t-token"}, "put ******"},
+		{"inside text", "url=http://x?t=s3cr3t-token&a=1", nil, "url=http://x?t=******&a=1"},
+		{"each line", "got %q", []interface{}{"line two"}, `got "******"`},
+		{"short secret as a word", "ab: a b abc", nil, "******: a b abc"},
+		{"nothing", "plain %d", []interface{}{1}, "plain 1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := redact(tt.format, tt.v...); got != tt.want {
+				t.Errorf("redact got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,556 @@
+package note
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"find/internal/files"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+)
+
+// sealedHeaderPrefix starts the first line of an encrypted file, followed by the id of the key sealing it.
+const sealedHeaderPrefix = "#FIND sealed v2 "
+
+// Kinds of encrypted files. Each line is sealed along with the kind and its index, and the file ends with
+// a sealed count of its lines, so that lines can't be reordered, dropped, duplicated, truncated or moved between
+// files unnoticed. A whole file can still be replaced by an older one of the same kind.
+const (
+	sealedNote    = "note"
+	sealedHistory = "history"
+	sealedTrash   = "trash"
+	sealedUndo    = "undo"
+)
+
+// Parameters of argon2id deriving the key which wraps data keys from the passphrase.
+const (
+	kdfName    = "argon2id"
+	kdfTime    = 3
+	kdfMemory  = 64 * 1024
+	kdfThreads = 4
+	kdfSaltLen = 16
+	keyLen     = 32
+)
+
+// errLocked means the notebook is encrypted but the passphrase hasn't been given.
+var errLocked = fmt.Errorf("notebook is encrypted and locked, please restart FIND to unlock it")
+
+// keyFile is kept beside the note of an encrypted notebook, which keeps data keys sealing files of notebook,
+// each wrapped by the key derived from the passphrase.
+type keyFile struct {
+	Version int `json:"version"`
+	KDF     struct {
+		Name    string `json:"name"`
+		Time    uint32 `json:"time"`
+		Memory  uint32 `json:"memory"`
+		Threads uint8  `json:"threads"`
+		Salt    string `json:"salt"`
+	} `json:"kdf"`
+	// Keys are data keys, the current one first. Older keys are only kept while files are converted.
+	Keys []wrappedKey `json:"keys"`
+	// Converting is true while files are turned from plain text into encrypted ones or back,
+	// so that files in plain text are only taken as notes of an encrypted notebook after such a crash.
+	Converting bool `json:"converting,omitempty"`
+}
+
+// wrappedKey is a data key encrypted by the key derived from the passphrase.
+type wrappedKey struct {
+	ID      string `json:"id"`
+	Wrapped string `json:"wrapped"`
+}
+
+// sealer encrypts lines of files with the current data key, and decrypts them with the key they were sealed by.
+type sealer struct {
+	current string
+	keys    map[string]cipher.AEAD
+	// dataKeys are data keys by id, which are wrapped again when the passphrase is changed.
+	dataKeys map[string][]byte
+	// plain is true if files in plain text are accepted, which are left by a conversion interrupted.
+	plain bool
+}
+
+// add is used to add a data key of id, returning error.
+func (s *sealer) add(id string, dataKey []byte) error {
+	aead, err := newAEAD(dataKey)
+	if err != nil {
+		return err
+	}
+	s.keys[id] = aead
+	s.dataKeys[id] = dataKey
+	return nil
+}
+
+// keyPath is used to get the path of the key file, whose existence means the notebook is encrypted.
+func (b *Notebook) keyPath() string {
+	return b.storePath() + ".key"
+}
+
+// Encrypted is used to check if files of notebook are encrypted.
+func (b *Notebook) Encrypted() bool {
+	_, err := os.Stat(b.keyPath())
+	return err == nil
+}
+
+// Locked is used to check if notebook is encrypted but not unlocked by Unlock yet.
+func (b *Notebook) Locked() bool {
+	return b.Encrypted() && b.sealer == nil
+}
+
+// Unlock is used to open data keys of an encrypted notebook with the passphrase,
+// which must be done before its notes are used.
+func (b *Notebook) Unlock(passphrase string) error {
+	kf, err := b.readKeyFile()
+	if err != nil {
+		return err
+	}
+	s, err := kf.open(passphrase)
+	if err != nil {
+		return err
+	}
+	s.plain = kf.Converting
+	b.sealer = s
+	return nil
+}
+
+// Encrypt is used to encrypt the note and other files of notebook by the passphrase,
+// which only supports the text store.
+func (b *Notebook) Encrypt(passphrase string) error {
+	if b.conf.Store != "" && b.conf.Store != storeTypeText {
+		return fmt.Errorf("encryption only supports the text store, but the store is %s", b.conf.Store)
+	}
+	if err := b.available(); err != nil {
+		return err
+	}
+	if b.Encrypted() {
+		return fmt.Errorf("notebook %s is already encrypted", b.conf.Name)
+	}
+	kf, next, err := newKeyFile(passphrase, nil)
+	if err != nil {
+		return err
+	}
+
+	unlock, err := b.lock()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	// The key file is written first, so that files can be read whether they're converted or not after a crash.
+	kf.Converting = true
+	err = b.writeKeyFile(kf)
+	if err != nil {
+		return err
+	}
+	err = b.convert(next)
+	if err != nil {
+		return err
+	}
+	kf.Converting = false
+	return b.writeKeyFile(kf)
+}
+
+// Decrypt is used to turn encrypted files of notebook back into plain text after checking the passphrase.
+func (b *Notebook) Decrypt(passphrase string) error {
+	if err := b.available(); err != nil {
+		return err
+	}
+	if !b.Encrypted() {
+		return fmt.Errorf("notebook %s isn't encrypted", b.conf.Name)
+	}
+	kf, err := b.readKeyFile()
+	if err != nil {
+		return err
+	}
+	_, err = kf.open(passphrase)
+	if err != nil {
+		return err
+	}
+
+	unlock, err := b.lock()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	kf.Converting = true
+	err = b.writeKeyFile(kf)
+	if err != nil {
+		return err
+	}
+	err = b.convert(nil)
+	if err != nil {
+		return err
+	}
+	err = os.Remove(b.keyPath())
+	if err != nil {
+		return fmt.Errorf("remove %s error: %v", b.keyPath(), err)
+	}
+	return nil
+}
+
+// ChangePassphrase is used to encrypt files of notebook again by a new data key wrapped by the new passphrase
+// after checking the old one.
+func (b *Notebook) ChangePassphrase(old, passphrase string) error {
+	if err := b.available(); err != nil {
+		return err
+	}
+	if !b.Encrypted() {
+		return fmt.Errorf("notebook %s isn't encrypted", b.conf.Name)
+	}
+	kf, err := b.readKeyFile()
+	if err != nil {
+		return err
+	}
+	current, err := kf.open(old)
+	if err != nil {
+		return err
+	}
+	// Old data keys are kept until files are converted, so that files can be read after a crash.
+	kf, next, err := newKeyFile(passphrase, current)
+	if err != nil {
+		return err
+	}
+
+	unlock, err := b.lock()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	err = b.writeKeyFile(kf)
+	if err != nil {
+		return err
+	}
+	err = b.convert(next)
+	if err != nil {
+		return err
+	}
+	kf.Keys = kf.Keys[:1]
+	return b.writeKeyFile(kf)
+}
+
+// convert is used to rewrite the note and other files of notebook sealed by next, or in plain text if next is nil.
+// It must be called with the lock taken.
+func (b *Notebook) convert(next *sealer) error {
+	if t, ok := b.store.Store.(*textStore); ok {
+		err := t.reseal(next)
+		if err != nil {
+			return err
+		}
+	}
+	others := []struct{ path, kind string }{
+		{b.storePath() + ".history", sealedHistory},
+		{b.trashPath(), sealedTrash},
+		{b.undoPath(), sealedUndo},
+	}
+	for _, f := range others {
+		if _, err := os.Stat(f.path); os.IsNotExist(err) {
+			continue
+		}
+		lines, _, err := readSealedLines(f.path, f.kind, b.sealer)
+		if err != nil {
+			return err
+		}
+		err = writeSealedLines(f.path, f.kind, lines, next)
+		if err != nil {
+			return fmt.Errorf("write %s error: %v", f.path, err)
+		}
+	}
+	b.sealer = next
+	return nil
+}
+
+// resumeConversion is used to encrypt files of notebook left in plain text by a conversion interrupted,
+// which is resumed as encryption even if it was decryption, since the passphrase has been given.
+func (b *Notebook) resumeConversion() error {
+	unlock, err := b.lock()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	kf, err := b.readKeyFile()
+	if err != nil {
+		return err
+	}
+	err = b.convert(b.sealer)
+	if err != nil {
+		return err
+	}
+	b.sealer.plain = false
+	kf.Converting = false
+	return b.writeKeyFile(kf)
+}
+
+// readKeyFile is used to read the key file of notebook, returning the key file and error.
+func (b *Notebook) readKeyFile() (*keyFile, error) {
+	data, err := os.ReadFile(b.keyPath())
+	if err != nil {
+		return nil, fmt.Errorf("read %s error: %v", b.keyPath(), err)
+	}
+	kf := &keyFile{}
+	err = json.Unmarshal(data, kf)
+	if err != nil {
+		return nil, fmt.Errorf("json unmarshal of %s error: %v", b.keyPath(), err)
+	}
+	if kf.Version != 1 || kf.KDF.Name != kdfName || len(kf.Keys) == 0 {
+		return nil, fmt.Errorf("unsupported key file %s, please upgrade FIND", b.keyPath())
+	}
+	return kf, nil
+}
+
+// writeKeyFile is used to replace the key file of notebook atomically.
+func (b *Notebook) writeKeyFile(kf *keyFile) error {
+	data, err := json.MarshalIndent(kf, "", "  ")
+	if err != nil {
+		return fmt.Errorf("json marshal of key file error: %v", err)
+	}
+	lines := []string{string(data)}
+	err = files.WriteLinesToPathAtomically(b.keyPath(), &lines)
+	if err != nil {
+		return fmt.Errorf("write %s error: %v", b.keyPath(), err)
+	}
+	return nil
+}
+
+// newKeyFile is used to make a key file of a new random data key wrapped by the passphrase,
+// along with carried data keys of s if s isn't nil, returning the key file, the sealer of its keys, and error.
+// The new data key is the current one.
+func newKeyFile(passphrase string, carried *sealer) (*keyFile, *sealer, error) {
+	if passphrase == "" {
+		return nil, nil, fmt.Errorf("passphrase can't be empty")
+	}
+	kf := &keyFile{Version: 1}
+	kf.KDF.Name = kdfName
+	kf.KDF.Time = kdfTime
+	kf.KDF.Memory = kdfMemory
+	kf.KDF.Threads = kdfThreads
+	salt, err := randomBytes(kdfSaltLen)
+	if err != nil {
+		return nil, nil, err
+	}
+	kf.KDF.Salt = base64.StdEncoding.EncodeToString(salt)
+	kek, err := newAEAD(kf.derive(passphrase, salt))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	dataKey, err := randomBytes(keyLen)
+	if err != nil {
+		return nil, nil, err
+	}
+	idBytes, err := randomBytes(4)
+	if err != nil {
+		return nil, nil, err
+	}
+	s := &sealer{current: hex.EncodeToString(idBytes), keys: make(map[string]cipher.AEAD),
+		dataKeys: make(map[string][]byte)}
+	err = s.add(s.current, dataKey)
+	if err != nil {
+		return nil, nil, err
+	}
+	ids := []string{s.current}
+	if carried != nil {
+		for id, k := range carried.dataKeys {
+			if id == s.current {
+				continue
+			}
+			err = s.add(id, k)
+			if err != nil {
+				return nil, nil, err
+			}
+			ids = append(ids, id)
+		}
+	}
+	for _, id := range ids {
+		wrapped, err := seal(kek, s.dataKeys[id], []byte(id))
+		if err != nil {
+			return nil, nil, err
+		}
+		kf.Keys = append(kf.Keys, wrappedKey{ID: id, Wrapped: wrapped})
+	}
+	return kf, s, nil
+}
+
+// derive is used to derive the key which wraps data keys from the passphrase.
+func (kf *keyFile) derive(passphrase string, salt []byte) []byte {
+	return argon2.IDKey([]byte(passphrase), salt, kf.KDF.Time, kf.KDF.Memory, kf.KDF.Threads, keyLen)
+}
+
+// open is used to unwrap all data keys by the passphrase, returning the sealer and error.
+func (kf *keyFile) open(passphrase string) (*sealer, error) {
+	salt, err := base64.StdEncoding.DecodeString(kf.KDF.Salt)
+	if err != nil {
+		return nil, fmt.Errorf("invalid salt: %v", err)
+	}
+	kek, err := newAEAD(kf.derive(passphrase, salt))
+	if err != nil {
+		return nil, err
+	}
+	s := &sealer{current: kf.Keys[0].ID, keys: make(map[string]cipher.AEAD, len(kf.Keys)),
+		dataKeys: make(map[string][]byte, len(kf.Keys))}
+	for _, k := range kf.Keys {
+		dataKey, err := open(kek, k.Wrapped, []byte(k.ID))
+		if err != nil {
+			return nil, fmt.Errorf("wrong passphrase")
+		}
+		err = s.add(k.ID, dataKey)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return s, nil
+}
+
+// sealLines is used to encrypt lines of a file of kind, returning them as they are if s is nil.
+func (s *sealer) sealLines(kind string, lines []string) ([]string, error) {
+	if s == nil {
+		return lines, nil
+	}
+	sealed := make([]string, 0, len(lines)+2)
+	sealed = append(sealed, sealedHeaderPrefix+s.current)
+	for i, line := range lines {
+		l, err := s.sealLine(kind, i, line)
+		if err != nil {
+			return nil, err
+		}
+		sealed = append(sealed, l)
+	}
+	end, err := seal(s.keys[s.current], []byte(strconv.Itoa(len(lines))), endAdditional(kind))
+	if err != nil {
+		return nil, err
+	}
+	return append(sealed, end), nil
+}
+
+// sealLine is used to encrypt a line by the current data key, which is the line of index in a file of kind,
+// counting from 0 after the header.
+func (s *sealer) sealLine(kind string, index int, line string) (string, error) {
+	return seal(s.keys[s.current], []byte(line), additional(kind, index))
+}
+
+// additional is used to get the additional data of the line of index in a file of kind,
+// which must be the same to open the line as to seal it.
+func additional(kind string, index int) []byte {
+	return []byte(kind + ":" + strconv.Itoa(index))
+}
+
+// endAdditional is used to get the additional data of the last line of a file of kind, which is the count of lines.
+func endAdditional(kind string) []byte {
+	return []byte(kind + ":end")
+}
+
+// openLines is used to decrypt lines of a file of kind, returning lines as they are if they aren't encrypted,
+// and whether they should be rewritten, which is true if they aren't sealed by the current key of s.
+// Lines in plain text are refused by s unless a conversion was interrupted.
+func (s *sealer) openLines(kind string, lines []string) ([]string, bool, error) {
+	if len(lines) == 0 {
+		return lines, false, nil
+	}
+	if !strings.HasPrefix(lines[0], sealedHeaderPrefix) {
+		if s != nil && !s.plain {
+			return nil, false, fmt.Errorf("lines aren't encrypted, but the notebook is")
+		}
+		return lines, s != nil, nil
+	}
+	if s == nil {
+		return nil, false, errLocked
+	}
+	id := strings.TrimPrefix(lines[0], sealedHeaderPrefix)
+	aead, ok := s.keys[id]
+	if !ok {
+		return nil, false, fmt.Errorf("sealed by unknown key %s", id)
+	}
+	body := lines[1:]
+	count := -1
+	if len(body) > 0 {
+		data, err := open(aead, body[len(body)-1], endAdditional(kind))
+		if err == nil {
+			count, _ = strconv.Atoi(string(data))
+		}
+		body = body[:len(body)-1]
+	}
+	if count != len(body) {
+		return nil, false, fmt.Errorf("sealed lines are truncated or added")
+	}
+	opened := make([]string, 0, len(body))
+	for i, line := range body {
+		data, err := open(aead, line, additional(kind, i))
+		if err != nil {
+			return nil, false, fmt.Errorf("decrypt line %d error: %v", i+2, err)
+		}
+		opened = append(opened, string(data))
+	}
+	return opened, id != s.current, nil
+}
+
+// readSealedLines is used to read lines of a file of notebook of kind, decrypting them if they're encrypted,
+// returning lines, whether they should be rewritten by s, and error.
+func readSealedLines(path, kind string, s *sealer) ([]string, bool, error) {
+	lines, err := files.ReadLinesFromPath(path)
+	if err != nil {
+		return nil, false, fmt.Errorf("read lines from %s error: %v", path, err)
+	}
+	lines, stale, err := s.openLines(kind, lines)
+	if err != nil {
+		return nil, false, fmt.Errorf("open %s error: %v", path, err)
+	}
+	return lines, stale, nil
+}
+
+// writeSealedLines is used to replace lines of a file of notebook of kind atomically, encrypting them if s isn't nil.
+func writeSealedLines(path, kind string, lines []string, s *sealer) error {
+	sealed, err := s.sealLines(kind, lines)
+	if err != nil {
+		return fmt.Errorf("seal %s error: %v", path, err)
+	}
+	return files.WriteLinesToPathAtomically(path, &sealed)
+}
+
+// newAEAD is used to make AES-256-GCM of key.
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("new cipher error: %v", err)
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, fmt.Errorf("new gcm error: %v", err)
+	}
+	return aead, nil
+}
+
+// seal is used to encrypt data with a random nonce, returning base64 of the nonce followed by cipher text.
+func seal(aead cipher.AEAD, data, additional []byte) (string, error) {
+	nonce, err := randomBytes(aead.NonceSize())
+	if err != nil {
+		return "", err
+	}
+	return base64.RawStdEncoding.EncodeToString(aead.Seal(nonce, nonce, data, additional)), nil
+}
+
+// open is used to decrypt the result of seal, returning data and error.
+func open(aead cipher.AEAD, sealed string, additional []byte) ([]byte, error) {
+	data, err := base64.RawStdEncoding.DecodeString(sealed)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) < aead.NonceSize() {
+		return nil, fmt.Errorf("too short")
+	}
+	return aead.Open(nil, data[:aead.NonceSize()], data[aead.NonceSize():], additional)
+}
+
+// randomBytes is used to get n random bytes.
+func randomBytes(n int) ([]byte, error) {
+	data := make([]byte, n)
+	_, err := io.ReadFull(rand.Reader, data)
+	if err != nil {
+		return nil, fmt.Errorf("read random bytes error: %v", err)
+	}
+	return data, nil
+}
@@ -0,0 +1,227 @@
+package note
+
+import (
+	"bytes"
+	"crypto/cipher"
+	"errors"
+	"find/internal/files"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// testSealer is used to make a sealer of a fixed data key, without deriving it from a passphrase.
+func testSealer(t *testing.T, id string) *sealer {
+	s := &sealer{current: id, keys: make(map[string]cipher.AEAD), dataKeys: make(map[string][]byte)}
+	err := s.add(id, bytes.Repeat([]byte(id[:1]), keyLen))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func TestSealOpenLines(t *testing.T) {
+	s := testSealer(t, "k1")
+	lines := []string{"#FIND v3", "a:1", "b:北京", "a:1", ""}
+	sealed, err := s.sealLines(sealedNote, lines)
+	if err != nil {
+		t.Fatalf("seal error: %v", err)
+	}
+	if sealed[0] != sealedHeaderPrefix+"k1" || len(sealed) != len(lines)+2 {
+		t.Fatalf("seal got header %q and %d lines, want %q and %d", sealed[0], len(sealed), sealedHeaderPrefix+"k1",
+			len(lines)+2)
+	}
+	for _, line := range sealed[1:] {
+		if strings.Contains(line, "北京") || strings.ContainsAny(line, "\n\r") {
+			t.Fatalf("sealed line %q isn't encrypted in one line", line)
+		}
+	}
+	opened, stale, err := s.openLines(sealedNote, sealed)
+	if err != nil {
+		t.Fatalf("open error: %v", err)
+	}
+	if stale || !reflect.DeepEqual(opened, lines) {
+		t.Errorf("open got %q and stale %v, want %q and false", opened, stale, lines)
+	}
+}
+
+func TestOpenTamperedLines(t *testing.T) {
+	s := testSealer(t, "k1")
+	lines := []string{"a:1", "b:2", "c:3"}
+	note, err := s.sealLines(sealedNote, lines)
+	if err != nil {
+		t.Fatal(err)
+	}
+	trash, err := s.sealLines(sealedTrash, lines)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		kind   string
+		sealed []string
+	}{
+		{"swapped lines", sealedNote, []string{note[0], note[2], note[1], note[3], note[4]}},
+		{"dropped line", sealedNote, []string{note[0], note[1], note[3], note[4]}},
+		{"duplicated line", sealedNote, []string{note[0], note[1], note[1], note[2], note[3], note[4]}},
+		{"line of another file", sealedNote, []string{note[0], note[1], trash[2], note[3], note[4]}},
+		{"wrong kind", sealedHistory, note},
+		{"truncated line", sealedNote, []string{note[0], note[1][:len(note[1])-2], note[2], note[3], note[4]}},
+		{"dropped last line", sealedNote, []string{note[0], note[1], note[2], note[4]}},
+		{"truncated trailing lines", sealedNote, note[:3]},
+		{"only header", sealedNote, note[:1]},
+		{"end of another file", sealedNote, append(note[:4:4], trash[4])},
+		{"unknown key", sealedNote, append([]string{sealedHeaderPrefix + "k2"}, note[1:]...)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opened, _, err := s.openLines(tt.kind, tt.sealed)
+			if err == nil {
+				t.Errorf("open got %q, want error", opened)
+			}
+		})
+	}
+}
+
+func TestOpenLinesStale(t *testing.T) {
+	old := testSealer(t, "k1")
+	converting := testSealer(t, "k1")
+	converting.plain = true
+	current, err := old.sealLines(sealedUndo, []string{"a:1", "b:2"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	rotated := testSealer(t, "k2")
+	err = rotated.add("k1", old.dataKeys["k1"])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		s      *sealer
+		lines  []string
+		stale  bool
+		locked bool
+	}{
+		{"current", old, current, false, false},
+		{"sealed by an older key", rotated, current, true, false},
+		{"plain lines left by a conversion", converting, []string{"a:1", "b:2"}, true, false},
+		{"plain lines of a plain notebook", nil, []string{"a:1", "b:2"}, false, false},
+		{"empty file", old, nil, false, false},
+		{"locked", nil, current, false, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opened, stale, err := tt.s.openLines(sealedUndo, tt.lines)
+			if tt.locked {
+				if !errors.Is(err, errLocked) {
+					t.Errorf("open got error %v, want %v", err, errLocked)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("open error: %v", err)
+			}
+			if want := []string{"a:1", "b:2"}; len(tt.lines) > 0 && !reflect.DeepEqual(opened, want) {
+				t.Errorf("open got %q, want %q", opened, want)
+			}
+			if stale != tt.stale {
+				t.Errorf("open got stale %v, want %v", stale, tt.stale)
+			}
+		})
+	}
+}
+
+func TestOpenPlainLinesRefused(t *testing.T) {
+	s := testSealer(t, "k1")
+	if opened, _, err := s.openLines(sealedNote, []string{"a:1", "b:2"}); err == nil {
+		t.Errorf("open plain lines got %q, want error", opened)
+	}
+}
+
+func TestKeyFilePassphrase(t *testing.T) {
+	kf, s, err := newKeyFile("correct horse", nil)
+	if err != nil {
+		t.Fatalf("new key file error: %v", err)
+	}
+	sealed, err := s.sealLines(sealedNote, []string{"a:1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = kf.open("wrong horse"); err == nil {
+		t.Errorf("open by a wrong passphrase got no error")
+	}
+	opened, err := kf.open("correct horse")
+	if err != nil {
+		t.Fatalf("open by the passphrase error: %v", err)
+	}
+	lines, _, err := opened.openLines(sealedNote, sealed)
+	if err != nil || !reflect.DeepEqual(lines, []string{"a:1"}) {
+		t.Errorf("open lines got %q and error %v, want [a:1]", lines, err)
+	}
+}
+
+func TestResumeConversion(t *testing.T) {
+	b := testNotebook(t)
+	if err := b.Write([]Note{mustNote(t, "a", "1")}); err != nil {
+		t.Fatalf("write error: %v", err)
+	}
+	if err := b.Encrypt("correct horse"); err != nil {
+		t.Fatalf("encrypt error: %v", err)
+	}
+	kf, err := b.readKeyFile()
+	if err != nil || kf.Converting {
+		t.Fatalf("key file after encrypt got %+v and error %v, want it converted", kf, err)
+	}
+
+	// The note is written in plain text again, like a crash in the middle of encryption.
+	plain := encode([]Note{mustNote(t, "a", "1")})
+	if err = files.WriteLinesToPath(b.storePath(), &plain); err != nil {
+		t.Fatal(err)
+	}
+	reopen := func() *Notebook {
+		if b.store != nil {
+			_ = b.store.Close()
+		}
+		b = &Notebook{conf: b.conf}
+		if err := b.Unlock("correct horse"); err != nil {
+			t.Fatalf("unlock error: %v", err)
+		}
+		return b
+	}
+	if err = reopen().Check(); err == nil {
+		t.Fatalf("check of a note in plain text got no error, want it refused")
+	}
+
+	kf.Converting = true
+	if err = b.writeKeyFile(kf); err != nil {
+		t.Fatal(err)
+	}
+	if err = reopen().Check(); err != nil {
+		t.Fatalf("check while converting error: %v", err)
+	}
+	t.Cleanup(func() { _ = b.store.Close() })
+	if got := currentValues(t, b); !reflect.DeepEqual(got, map[string]string{"a": "1"}) {
+		t.Errorf("notes after resumed are %v, want a:1", got)
+	}
+	lines, err := files.ReadLinesFromPath(b.storePath())
+	if err != nil || !strings.HasPrefix(lines[0], sealedHeaderPrefix) {
+		t.Errorf("note after resumed starts with %q, want it encrypted", lines[0])
+	}
+	if kf, err = b.readKeyFile(); err != nil || kf.Converting {
+		t.Errorf("key file after resumed got %+v and error %v, want it converted", kf, err)
+	}
+
+	// revisions of an encrypted notebook are sealed by rewriting its history, which must be read again.
+	for _, val := range []string{"2", "3"} {
+		if err = b.Modify(mustNote(t, "a", val), "mod"); err != nil {
+			t.Fatalf("modify error: %v", err)
+		}
+	}
+	b.history = nil
+	if got, want := revisionsOf(t, b, "a"), []string{"user:1", "mod:2", "mod:3"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("revisions of a are %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,252 @@
+package note
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// version1 is the original format: plain 'key:content' lines without header.
+	version1 = 1
+	// version2 adds a header line and a metadata prefix to each note.
+	version2 = 2
+	// version3 escapes backslashes, line breaks and tabs in notes, and ':' in keys,
+	// so that any text can be kept in one line.
+	version3 = 3
+	// currentVersion is the format used when writing note.
+	currentVersion = version3
+)
+
+// headerPrefix starts the first line of a versioned note, followed by the version number.
+const headerPrefix = "#FIND v"
+
+// metaPrefix starts the metadata part of a note line,
+// which is separated from 'key:content' by a tab.
+const metaPrefix = "@"
+
+// header is used to get the header line of specified version.
+func header(version int) string {
+	return headerPrefix + strconv.Itoa(version)
+}
+
+// parseHeader is used to get version from the first line of note,
+// returning version1 if the line is not a header.
+func parseHeader(line string) (int, error) {
+	if !strings.HasPrefix(line, headerPrefix) {
+		return version1, nil
+	}
+	version, err := strconv.Atoi(strings.TrimPrefix(line, headerPrefix))
+	if err != nil {
+		return 0, fmt.Errorf("invalid header %s: %v", line, err)
+	}
+	if version > currentVersion {
+		return 0, fmt.Errorf("unsupported version %d, please upgrade FIND", version)
+	}
+	return version, nil
+}
+
+// encode is used to format notes into lines of current version, including the header.
+func encode(notes []Note) []string {
+	lines := make([]string, 0, len(notes)+1)
+	lines = append(lines, header(currentVersion))
+	for _, n := range notes {
+		lines = append(lines, encodeNote(n))
+	}
+	return lines
+}
+
+// encodeNote is used to format a note into a line of current version.
+func encodeNote(n Note) string {
+	meta := url.Values{}
+	meta.Set("id", n.ID)
+	meta.Set("created", strconv.FormatInt(n.Created.Unix(), 10))
+	meta.Set("updated", strconv.FormatInt(n.Updated.Unix(), 10))
+	meta.Set("host", n.Host)
+	meta.Set("rev", strconv.Itoa(n.Rev))
+	if len(n.Tags) > 0 {
+		meta.Set("tags", strings.Join(n.Tags, ","))
+	}
+	return metaPrefix + meta.Encode() + "\t" + escape(n.Key, true) + ":" + escape(n.Val, false)
+}
+
+// decode is used to parse lines of any version into notes,
+// returning notes, version of lines and error.
+// The stale flag reports whether lines should be rewritten in current version,
+// which happens for older versions and for hand-written lines without metadata.
+func decode(lines []string) (notes []Note, version int, stale bool, err error) {
+	version = version1
+	if len(lines) > 0 {
+		version, err = parseHeader(lines[0])
+		if err != nil {
+			return nil, 0, false, err
+		}
+		if version != version1 {
+			lines = lines[1:]
+		}
+	}
+	stale = version != currentVersion
+
+	notes = make([]Note, 0, len(lines))
+	for i, line := range lines {
+		n, complete, err := decodeNote(line, version)
+		if err != nil {
+			return nil, 0, false, fmt.Errorf("decode line %d error: %v", i+1, err)
+		}
+		if !complete {
+			stale = true
+		}
+		notes = append(notes, n)
+	}
+	return notes, version, stale, nil
+}
+
+// decodeNote is used to parse a line of specified version into a note,
+// returning the note, whether the line has complete metadata, and error.
+// Lines without metadata are accepted in every version, so users can still add notes by hand,
+// which are taken as they are rather than unescaped, so that a hand-written 'C:\new' keeps its backslash.
+func decodeNote(line string, version int) (Note, bool, error) {
+	if version == version1 || !strings.HasPrefix(line, metaPrefix) {
+		n, err := parseLine(line, false)
+		return n, false, err
+	}
+
+	i := strings.Index(line, "\t")
+	if i == -1 {
+		return Note{}, false, fmt.Errorf("missing tab after metadata")
+	}
+	meta, err := url.ParseQuery(strings.TrimPrefix(line[:i], metaPrefix))
+	if err != nil {
+		return Note{}, false, fmt.Errorf("parse metadata error: %v", err)
+	}
+	n, err := parseLine(line[i+1:], version >= version3)
+	if err != nil {
+		return Note{}, false, err
+	}
+
+	complete := true
+	if id := meta.Get("id"); id != "" {
+		n.ID = id
+	} else {
+		complete = false
+	}
+	if created, ok := parseUnix(meta.Get("created")); ok {
+		n.Created = created
+	} else {
+		complete = false
+	}
+	if updated, ok := parseUnix(meta.Get("updated")); ok {
+		n.Updated = updated
+	} else {
+		complete = false
+	}
+	n.Host = meta.Get("host")
+	// Notes written before revisions are counted are taken as the first revision.
+	if rev, err := strconv.Atoi(meta.Get("rev")); err == nil && rev > 0 {
+		n.Rev = rev
+	}
+	if tags := meta.Get("tags"); tags != "" {
+		n.Tags = normalizeTags(strings.Split(tags, ","))
+	}
+	return n, complete, nil
+}
+
+// parseUnix is used to parse unix seconds, returning the time and whether it's valid.
+func parseUnix(s string) (time.Time, bool) {
+	sec, err := strconv.ParseInt(s, 10, 64)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return time.Unix(sec, 0), true
+}
+
+// parseLine is used to parse 'key:content' read from note, which is unescaped if it's escaped as version3 does,
+// returning a new note and error.
+// Unlike Parse, it accepts any line so that nothing in an old note is dropped,
+// and a line without ':' is kept as a key with empty content.
+func parseLine(line string, escaped bool) (Note, error) {
+	if !escaped {
+		i := strings.Index(line, ":")
+		if i == -1 {
+			return New(line, "")
+		}
+		return New(line[:i], line[i+1:])
+	}
+
+	i := indexUnescaped(line, ':')
+	if i == -1 {
+		return New(unescape(line), "")
+	}
+	return New(unescape(line[:i]), unescape(line[i+1:]))
+}
+
+// escape is used to make text fit in one line of note,
+// and ':' is escaped as well if the text is a key.
+func escape(text string, isKey bool) string {
+	var b strings.Builder
+	for _, r := range text {
+		switch {
+		case r == '\\':
+			b.WriteString(`\\`)
+		case r == '\n':
+			b.WriteString(`\n`)
+		case r == '\r':
+			b.WriteString(`\r`)
+		case r == '\t':
+			b.WriteString(`\t`)
+		case r == ':' && isKey:
+			b.WriteString(`\:`)
+		default:
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// unescape is the reverse of escape. Unknown escapes are kept as they are,
+// so that a hand-written backslash (e.g. in a windows path) isn't lost.
+func unescape(text string) string {
+	if !strings.Contains(text, `\`) {
+		return text
+	}
+	var b strings.Builder
+	for i := 0; i < len(text); i++ {
+		if text[i] != '\\' || i == len(text)-1 {
+			b.WriteByte(text[i])
+			continue
+		}
+		switch text[i+1] {
+		case '\\':
+			b.WriteByte('\\')
+		case 'n':
+			b.WriteByte('\n')
+		case 'r':
+			b.WriteByte('\r')
+		case 't':
+			b.WriteByte('\t')
+		case ':':
+			b.WriteByte(':')
+		default:
+			b.WriteByte('\\')
+			b.WriteByte(text[i+1])
+		}
+		i++
+	}
+	return b.String()
+}
+
+// indexUnescaped is used to find the first c which is not escaped by a backslash,
+// returning its index or -1 if not found.
+func indexUnescaped(text string, c byte) int {
+	for i := 0; i < len(text); i++ {
+		switch text[i] {
+		case '\\':
+			i++
+		case c:
+			return i
+		}
+	}
+	return -1
+}
@@ -0,0 +1,131 @@
+package note
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestEscapeRoundTrip(t *testing.T) {
+	tests := []struct {
+		name  string
+		text  string
+		isKey bool
+		want  string
+	}{
+		{"plain", "mysql", true, "mysql"},
+		{"colon of key", "host:port", true, `host\:port`},
+		{"colon of content", "host:port", false, "host:port"},
+		{"line breaks", "a\nb\r\nc", false, `a\nb\r\nc`},
+		{"tab", "a\tb", false, `a\tb`},
+		{"backslashes", `C:\temp\new`, true, `C\:\\temp\\new`},
+		{"escape-like text", `\n\:`, false, `\\n\\:`},
+		{"trailing backslash", `a\`, false, `a\\`},
+		{"cjk", "北京:昌平\n全", true, `北京\:昌平\n全`},
+		{"empty", "", false, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := escape(tt.text, tt.isKey)
+			if got != tt.want {
+				t.Errorf("escape(%q) = %q, want %q", tt.text, got, tt.want)
+			}
+			if strings.ContainsAny(got, "\n\r\t") {
+				t.Errorf("escape(%q) = %q, which isn't one line", tt.text, got)
+			}
+			if back := unescape(got); back != tt.text {
+				t.Errorf("unescape(%q) = %q, want %q", got, back, tt.text)
+			}
+		})
+	}
+}
+
+func TestUnescapeKeepsUnknownEscapes(t *testing.T) {
+	tests := []struct {
+		text string
+		want string
+	}{
+		{`C:\dir\file`, `C:\dir\file`},
+		{`a\`, `a\`},
+		{`\d+\n`, "\\d+\n"},
+	}
+	for _, tt := range tests {
+		if got := unescape(tt.text); got != tt.want {
+			t.Errorf("unescape(%q) = %q, want %q", tt.text, got, tt.want)
+		}
+	}
+}
+
+func TestEncodeDecode(t *testing.T) {
+	at := time.Unix(1700000000, 0)
+	notes := []Note{
+		{ID: "1", Key: "sql", Val: "SELECT *\nFROM t\tWHERE a = 'b:c';", Created: at, Updated: at, Host: "vm", Rev: 2,
+			Tags: []string{"db"}},
+		{ID: "2", Key: `a:b\c 北京`, Val: strings.Repeat("long ", 20*1024), Created: at, Updated: at.Add(time.Hour),
+			Rev: 1},
+		{ID: "3", Key: "empty", Val: "", Created: at, Updated: at, Rev: 1},
+	}
+	lines := encode(notes)
+	if len(lines) != len(notes)+1 {
+		t.Fatalf("encode got %d lines, want %d", len(lines), len(notes)+1)
+	}
+	got, version, stale, err := decode(lines)
+	if err != nil {
+		t.Fatalf("decode error: %v", err)
+	}
+	if version != currentVersion || stale {
+		t.Errorf("decode got version %d and stale %v, want %d and false", version, stale, currentVersion)
+	}
+	if !reflect.DeepEqual(got, notes) {
+		t.Errorf("decode got %+v, want %+v", got, notes)
+	}
+}
+
+func TestDecodeOlderVersions(t *testing.T) {
+	tests := []struct {
+		name  string
+		lines []string
+		key   string
+		val   string
+	}{
+		{"version 1", []string{`C:\temp:dir`}, "C", `\temp:dir`},
+		{"hand-written version 2", []string{header(version2), `url:http://a\n`}, "url", `http://a\n`},
+		{"hand-written version 3", []string{header(version3), `path:C:\new\table`}, "path", `C:\new\table`},
+		{"escaped version 3", []string{header(version3), "@id=1\t" + `a\:b:x\ny`}, "a:b", "x\ny"},
+		{"without content", []string{header(version3), "todo"}, "todo", ""},
+		{"without some metadata", []string{header(version2), "@id=1\tk:v"}, "k", "v"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			notes, _, stale, err := decode(tt.lines)
+			if err != nil {
+				t.Fatalf("decode error: %v", err)
+			}
+			if len(notes) != 1 || notes[0].Key != tt.key || notes[0].Val != tt.val {
+				t.Fatalf("decode got %+v, want %q:%q", notes, tt.key, tt.val)
+			}
+			if !stale {
+				t.Errorf("decode got stale false, want true")
+			}
+		})
+	}
+}
+
+func TestDecodeError(t *testing.T) {
+	tests := []struct {
+		name  string
+		lines []string
+	}{
+		{"newer version", []string{header(currentVersion + 1), "k:v"}},
+		{"invalid version", []string{headerPrefix + "x", "k:v"}},
+		{"missing tab", []string{header(version2), "@id=1 k:v"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if notes, _, _, err := decode(tt.lines); err == nil {
+				t.Errorf("decode got %+v, want error", notes)
+			}
+		})
+	}
+}
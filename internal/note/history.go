@@ -0,0 +1,371 @@
+package note
+
+import (
+	"find/internal/config"
+	"find/internal/logs"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Sources of revisions, which tell who changed the note.
+const (
+	// SourceUser means the note was changed by orders of user.
+	SourceUser = "user"
+	// SourceReminder means the note was changed by reminder after reminding.
+	SourceReminder = "reminder"
+	// SourceSync means the note was pulled from backup.
+	SourceSync = "sync"
+	// sourceUnknown means the revision was made before history was kept.
+	sourceUnknown = "unknown"
+)
+
+// historyHeaderPrefix starts the first line of history, followed by the version of notes in it.
+const historyHeaderPrefix = "#FIND history v"
+
+// defaultHistorySize is how many revisions are kept for each key if it's not configured.
+const defaultHistorySize = 10
+
+// Revision is a value which a note once had, along with who made it.
+type Revision struct {
+	Note
+	Source string `json:"source"`
+}
+
+// history keeps recent revisions of every key in a file beside the store,
+// where each revision is a line of source and encoded note separated by a tab.
+// Revisions are appended, and the file is rewritten once dropped revisions outnumber kept ones.
+type history struct {
+	path string
+	// size is how many revisions are kept for each key.
+	size      int
+	revisions map[string][]Revision
+	// lines is the number of revisions in the file, including the dropped ones.
+	lines int
+	// fileInfo identifies the loaded file, which may be changed by another FIND.
+	fileInfo os.FileInfo
+	// sealer encrypts the file, which is nil if it isn't encrypted.
+	sealer *sealer
+}
+
+// openHistory is used to get the history of notebook, loading it if changed by others.
+// It must be called with the lock taken.
+func (b *Notebook) openHistory() (*history, error) {
+	if b.history == nil {
+		size := config.Conf.Find.HistorySize
+		if size <= 0 {
+			size = defaultHistorySize
+		}
+		b.history = &history{path: b.storePath() + ".history", size: size}
+	}
+	b.history.sealer = b.sealer
+	err := b.history.load()
+	if err != nil {
+		return nil, fmt.Errorf("load history error: %v", err)
+	}
+	return b.history, nil
+}
+
+// load is used to read revisions from the file if it's not loaded or changed by others,
+// rewriting it if it's written in an older version or has too many dropped revisions.
+func (h *history) load() error {
+	fileInfo, err := os.Stat(h.path)
+	if os.IsNotExist(err) {
+		h.revisions = make(map[string][]Revision)
+		h.lines = 0
+		h.fileInfo = nil
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("stat %s error: %v", h.path, err)
+	}
+	if h.fileInfo != nil && os.SameFile(h.fileInfo, fileInfo) &&
+		h.fileInfo.Size() == fileInfo.Size() && h.fileInfo.ModTime().Equal(fileInfo.ModTime()) {
+		return nil
+	}
+
+	lines, sealStale, err := readSealedLines(h.path, sealedHistory, h.sealer)
+	if err != nil {
+		return err
+	}
+	version := currentVersion
+	if len(lines) > 0 && strings.HasPrefix(lines[0], historyHeaderPrefix) {
+		version, err = strconv.Atoi(strings.TrimPrefix(lines[0], historyHeaderPrefix))
+		if err != nil || version > currentVersion {
+			return fmt.Errorf("unsupported header %s, please upgrade FIND", lines[0])
+		}
+		lines = lines[1:]
+	}
+
+	h.revisions = make(map[string][]Revision)
+	h.lines = 0
+	for i, line := range lines {
+		r, err := parseRevision(line, version)
+		if err != nil {
+			logs.Warn("note: skip line %d of %s: %s", i+2, h.path, err.Error())
+			continue
+		}
+		h.keep(r)
+	}
+	h.fileInfo = fileInfo
+	if version != currentVersion || sealStale || h.lines > 2*h.kept() {
+		return h.rewrite()
+	}
+	return nil
+}
+
+// keep is used to add a revision in memory, dropping the oldest one of its key if there are too many.
+func (h *history) keep(r Revision) {
+	revs := append(h.revisions[r.Key], r)
+	if len(revs) > h.size {
+		revs = revs[len(revs)-h.size:]
+	}
+	h.revisions[r.Key] = revs
+	h.lines++
+}
+
+// kept is used to count revisions kept in memory.
+func (h *history) kept() int {
+	count := 0
+	for _, revs := range h.revisions {
+		count += len(revs)
+	}
+	return count
+}
+
+// add is used to append revisions to the file, which is rewritten instead if it's encrypted,
+// since it ends with a sealed count of its lines.
+func (h *history) add(revs ...Revision) error {
+	if len(revs) == 0 {
+		return nil
+	}
+	if h.sealer != nil {
+		for _, r := range revs {
+			h.keep(r)
+		}
+		return h.rewrite()
+	}
+	lines := make([]string, 0, len(revs)+1)
+	if h.fileInfo == nil {
+		lines = append(lines, historyHeaderPrefix+strconv.Itoa(currentVersion))
+	}
+	for _, r := range revs {
+		lines = append(lines, formatRevision(r))
+	}
+	var b strings.Builder
+	for _, line := range lines {
+		b.WriteString(line)
+		b.WriteByte('\n')
+	}
+
+	file, err := os.OpenFile(h.path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	if err != nil {
+		return fmt.Errorf("open %s error: %v", h.path, err)
+	}
+	_, err = file.WriteString(b.String())
+	if err == nil {
+		err = file.Sync()
+	}
+	closeErr := file.Close()
+	if err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("append to %s error: %v", h.path, err)
+	}
+
+	for _, r := range revs {
+		h.keep(r)
+	}
+	h.fileInfo, err = os.Stat(h.path)
+	if err != nil {
+		return fmt.Errorf("stat %s error: %v", h.path, err)
+	}
+	if h.lines > 2*h.kept() {
+		return h.rewrite()
+	}
+	return nil
+}
+
+// rewrite is used to replace the file by revisions kept in memory atomically.
+func (h *history) rewrite() error {
+	keys := make([]string, 0, len(h.revisions))
+	for key := range h.revisions {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	lines := make([]string, 0, h.kept()+1)
+	lines = append(lines, historyHeaderPrefix+strconv.Itoa(currentVersion))
+	for _, key := range keys {
+		for _, r := range h.revisions[key] {
+			lines = append(lines, formatRevision(r))
+		}
+	}
+	err := writeSealedLines(h.path, sealedHistory, lines, h.sealer)
+	if err != nil {
+		return fmt.Errorf("rewrite %s error: %v", h.path, err)
+	}
+	h.lines = len(lines) - 1
+	h.fileInfo, err = os.Stat(h.path)
+	if err != nil {
+		return fmt.Errorf("stat %s error: %v", h.path, err)
+	}
+	return nil
+}
+
+// of is used to get revisions of specified key from the oldest to the latest.
+func (h *history) of(key string) []Revision {
+	return h.revisions[key]
+}
+
+// lastRev is used to get the latest revision number of specified key, returning 0 if there is none.
+func (h *history) lastRev(key string) int {
+	last := 0
+	for _, r := range h.revisions[key] {
+		if r.Rev > last {
+			last = r.Rev
+		}
+	}
+	return last
+}
+
+// formatRevision is used to format a revision into a line of history.
+func formatRevision(r Revision) string {
+	return r.Source + "\t" + encodeNote(r.Note)
+}
+
+// parseRevision is used to parse a line of history of specified version, returning the revision and error.
+func parseRevision(line string, version int) (Revision, error) {
+	i := strings.Index(line, "\t")
+	if i == -1 {
+		return Revision{}, fmt.Errorf("missing tab after source")
+	}
+	n, complete, err := decodeNote(line[i+1:], version)
+	if err != nil {
+		return Revision{}, err
+	}
+	if !complete {
+		return Revision{}, fmt.Errorf("incomplete metadata")
+	}
+	return Revision{Note: n, Source: line[:i]}, nil
+}
+
+// record is used to add notes to history as revisions from specified source.
+// The value replaced is recorded as well if it was made before history was kept,
+// so that it can still be reverted to. It must be called with the lock taken.
+func (b *Notebook) record(notes []Note, olds map[string]*Note, source string) error {
+	h, err := b.openHistory()
+	if err != nil {
+		return err
+	}
+	revs := make([]Revision, 0, len(notes))
+	for _, n := range notes {
+		if old := olds[n.Key]; old != nil && h.lastRev(n.Key) < old.Rev {
+			revs = append(revs, Revision{Note: *old, Source: sourceUnknown})
+		}
+		revs = append(revs, Revision{Note: n, Source: source})
+	}
+	return h.add(revs...)
+}
+
+// nextRev is used to get the revision number for a new value of specified key,
+// which follows both the current note and its history. It must be called with the lock taken.
+func (b *Notebook) nextRev(key string, old *Note) (int, error) {
+	h, err := b.openHistory()
+	if err != nil {
+		return 0, err
+	}
+	rev := h.lastRev(key)
+	if old != nil && old.Rev > rev {
+		rev = old.Rev
+	}
+	return rev + 1, nil
+}
+
+// History is used to get revisions of note whose key equals to specified key,
+// from the oldest to the latest, returning revisions and error.
+func (b *Notebook) History(key string) ([]Revision, error) {
+	if err := b.available(); err != nil {
+		return nil, err
+	}
+	unlock, err := b.lock()
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	h, err := b.openHistory()
+	if err != nil {
+		return nil, err
+	}
+	revs := h.of(key)
+	results := make([]Revision, len(revs))
+	copy(results, revs)
+	if SecretRevisions(results) {
+		for _, r := range results {
+			logs.Redact(r.Val)
+		}
+	}
+	return results, nil
+}
+
+// SecretRevisions is used to check if any revision is of a secret note,
+// which means all revisions of the key are taken as secrets.
+func SecretRevisions(revs []Revision) bool {
+	for _, r := range revs {
+		if r.Secret() {
+			return true
+		}
+	}
+	return false
+}
+
+// Revert is used to bring the value of specified revision back as a new revision of the note,
+// and will asynchronously update the backup if the redis config is available.
+func (b *Notebook) Revert(key string, rev int) error {
+	revs, err := b.History(key)
+	if err != nil {
+		return err
+	}
+	target := RevisionOf(revs, rev)
+	if target == nil {
+		return fmt.Errorf("revision %d of %s not found", rev, key)
+	}
+
+	return b.modify(target.Note, SourceUser, fmt.Sprintf("revert %s %d", key, rev), false)
+}
+
+// RevisionOf is used to pick the latest revision of specified number from revisions,
+// returning nil if not found.
+func RevisionOf(revs []Revision, rev int) *Revision {
+	for i := len(revs) - 1; i >= 0; i-- {
+		if revs[i].Rev == rev {
+			return &revs[i]
+		}
+	}
+	return nil
+}
+
+// PrintHistory is used to show revisions to the user, the latest first,
+// along with the first line of each value, which is masked if the note is a secret.
+func PrintHistory(revs []Revision) {
+	if len(revs) == 0 {
+		fmt.Println("Empty result.")
+		return
+	}
+	secret := SecretRevisions(revs)
+	for i := len(revs) - 1; i >= 0; i-- {
+		r := revs[i]
+		val := r.Val
+		if secret {
+			val = secretMask
+		}
+		if j := strings.IndexAny(val, "\r\n"); j != -1 {
+			val = val[:j] + " ..."
+		}
+		fmt.Printf("#%d %s by %s on %s: %s\n", r.Rev, r.Updated.Format(timeLayout), r.Source, r.Host, val)
+	}
+}
@@ -0,0 +1,90 @@
+package note
+
+import (
+	"find/internal/config"
+	"reflect"
+	"testing"
+)
+
+// revisionsOf is used to get values and sources of revisions of key, checking their numbers.
+func revisionsOf(t *testing.T, b *Notebook, key string) []string {
+	revs, err := b.History(key)
+	if err != nil {
+		t.Fatalf("history of %s error: %v", key, err)
+	}
+	got := make([]string, 0, len(revs))
+	for i, r := range revs {
+		if i > 0 && r.Rev <= revs[i-1].Rev {
+			t.Errorf("revision %d follows %d, want a greater one", r.Rev, revs[i-1].Rev)
+		}
+		got = append(got, r.Source+":"+r.Val)
+	}
+	return got
+}
+
+func TestHistoryAndRevert(t *testing.T) {
+	b := testNotebook(t)
+	if err := b.Write([]Note{mustNote(t, "k", "v1")}); err != nil {
+		t.Fatalf("write error: %v", err)
+	}
+	if err := b.Modify(mustNote(t, "k", "v2"), SourceReminder); err != nil {
+		t.Fatalf("modify error: %v", err)
+	}
+	want := []string{"user:v1", "reminder:v2"}
+	if got := revisionsOf(t, b, "k"); !reflect.DeepEqual(got, want) {
+		t.Errorf("history got %q, want %q", got, want)
+	}
+
+	if err := b.Revert("k", 1); err != nil {
+		t.Fatalf("revert error: %v", err)
+	}
+	notes, err := b.Find("k", true)
+	if err != nil || len(notes) != 1 || notes[0].Val != "v1" || notes[0].Rev != 3 {
+		t.Fatalf("find after revert got %+v and error %v, want v1 of revision 3", notes, err)
+	}
+	want = append(want, "user:v1")
+	if got := revisionsOf(t, b, "k"); !reflect.DeepEqual(got, want) {
+		t.Errorf("history after revert got %q, want %q", got, want)
+	}
+	if err = b.Revert("k", 9); err == nil {
+		t.Errorf("revert to a missing revision got no error")
+	}
+
+	// History is read from the file again, as another FIND would.
+	b.history = nil
+	if got := revisionsOf(t, b, "k"); !reflect.DeepEqual(got, want) {
+		t.Errorf("history reloaded got %q, want %q", got, want)
+	}
+}
+
+func TestHistorySize(t *testing.T) {
+	old := config.Conf.Find.HistorySize
+	config.Conf.Find.HistorySize = 2
+	t.Cleanup(func() { config.Conf.Find.HistorySize = old })
+	b := testNotebook(t)
+
+	for _, val := range []string{"v1", "v2", "v3"} {
+		if err := b.Modify(mustNote(t, "k", val), SourceUser); err != nil {
+			t.Fatalf("modify error: %v", err)
+		}
+	}
+	want := []string{"user:v2", "user:v3"}
+	if got := revisionsOf(t, b, "k"); !reflect.DeepEqual(got, want) {
+		t.Errorf("history got %q, want %q", got, want)
+	}
+	if err := b.Revert("k", 1); err == nil {
+		t.Errorf("revert to a dropped revision got no error")
+	}
+
+	// A key used again continues its revisions rather than starting over.
+	if err := b.Delete("k", false, true); err != nil {
+		t.Fatalf("delete error: %v", err)
+	}
+	if err := b.Write([]Note{mustNote(t, "k", "v4")}); err != nil {
+		t.Fatalf("write error: %v", err)
+	}
+	revs, err := b.History("k")
+	if err != nil || len(revs) != 2 || revs[1].Rev != 4 {
+		t.Errorf("history after the key used again got %+v and error %v, want revision 4 last", revs, err)
+	}
+}
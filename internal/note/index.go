@@ -0,0 +1,397 @@
+package note
+
+import (
+	"find/internal/logs"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// watchDelay is how long the index waits after the last change of the watched file
+// before reloading, so that a burst of writes only causes one reload.
+const watchDelay = 200 * time.Millisecond
+
+// index keeps all notes of a store in memory along with inverted indexes of tokens,
+// so that queries don't touch the store. It updates itself on changes made through it,
+// and reloads when the watched file is changed by others (e.g. by hand or another FIND).
+type index struct {
+	Store
+	mutex sync.RWMutex
+	// notes maps keys to notes.
+	notes map[string]Note
+	// seqs maps keys to the order of insertion.
+	seqs map[string]int64
+	seq  int64
+	// keyTokens and valTokens map tokens to keys of the notes containing them.
+	keyTokens map[string]map[string]bool
+	valTokens map[string]map[string]bool
+	// tags maps tags to keys of the notes having them.
+	tags map[string]map[string]bool
+	// hanKeys are keys having Chinese characters, which may be matched by pinyin rather than tokens.
+	hanKeys map[string]bool
+	// modTime is the modified time of the store when the index was last synchronized.
+	modTime time.Time
+	watcher *fsnotify.Watcher
+}
+
+// newIndex is used to load all notes of the store into an index,
+// and watch the file of specified path if it's not empty.
+func newIndex(s Store, watchPath string) (*index, error) {
+	x := &index{Store: s}
+	err := x.load()
+	if err != nil {
+		return nil, err
+	}
+	if watchPath != "" {
+		err = x.watch(watchPath)
+		if err != nil {
+			// The index still works without watching, it just misses changes made by others.
+			logs.Warn("note: watch %s error: %s", watchPath, err.Error())
+		}
+	}
+	return x, nil
+}
+
+// load is used to rebuild the index from the store, and must be called with mutex locked.
+func (x *index) load() error {
+	modTime, err := x.Store.ModTime()
+	if err != nil {
+		return fmt.Errorf("get modified time error: %v", err)
+	}
+	x.notes = make(map[string]Note)
+	x.seqs = make(map[string]int64)
+	x.keyTokens = make(map[string]map[string]bool)
+	x.valTokens = make(map[string]map[string]bool)
+	x.tags = make(map[string]map[string]bool)
+	x.hanKeys = make(map[string]bool)
+	err = x.Store.Iterate(func(n Note) bool {
+		x.add(n)
+		return true
+	})
+	if err != nil {
+		return fmt.Errorf("load notes error: %v", err)
+	}
+	x.modTime = modTime
+	return nil
+}
+
+// add is used to put a note into the index, replacing the one with same key.
+func (x *index) add(n Note) {
+	x.remove(n.Key)
+	redact(n)
+	x.seq++
+	x.notes[n.Key] = n
+	x.seqs[n.Key] = x.seq
+	for _, token := range tokenize(n.Key) {
+		addPosting(x.keyTokens, token, n.Key)
+	}
+	for _, token := range tokenize(n.Val) {
+		addPosting(x.valTokens, token, n.Key)
+	}
+	for _, tag := range n.Tags {
+		addPosting(x.tags, tag, n.Key)
+	}
+	if hasHan([]rune(n.Key)) {
+		x.hanKeys[n.Key] = true
+	}
+}
+
+// remove is used to drop the note of specified key from the index.
+func (x *index) remove(key string) {
+	n, ok := x.notes[key]
+	if !ok {
+		return
+	}
+	for _, token := range tokenize(n.Key) {
+		removePosting(x.keyTokens, token, key)
+	}
+	for _, token := range tokenize(n.Val) {
+		removePosting(x.valTokens, token, key)
+	}
+	for _, tag := range n.Tags {
+		removePosting(x.tags, tag, key)
+	}
+	delete(x.notes, key)
+	delete(x.seqs, key)
+	delete(x.hanKeys, key)
+}
+
+// synced is used to record the modified time of the store after a change made through the index,
+// so that the watcher won't reload for it. It must be called with mutex locked.
+func (x *index) synced() {
+	modTime, err := x.Store.ModTime()
+	if err != nil {
+		logs.Warn("note: get modified time error: %s", err.Error())
+		return
+	}
+	x.modTime = modTime
+}
+
+func (x *index) Get(key string) (*Note, error) {
+	x.mutex.RLock()
+	defer x.mutex.RUnlock()
+	n, ok := x.notes[key]
+	if !ok {
+		return nil, nil
+	}
+	return &n, nil
+}
+
+func (x *index) Put(notes ...Note) error {
+	x.mutex.Lock()
+	defer x.mutex.Unlock()
+	err := x.Store.Put(notes...)
+	if err != nil {
+		return err
+	}
+	for _, n := range notes {
+		x.add(n)
+	}
+	x.synced()
+	return nil
+}
+
+func (x *index) Delete(keys ...string) error {
+	x.mutex.Lock()
+	defer x.mutex.Unlock()
+	err := x.Store.Delete(keys...)
+	if err != nil {
+		return err
+	}
+	for _, key := range keys {
+		x.remove(key)
+	}
+	x.synced()
+	return nil
+}
+
+func (x *index) Query(match func(Note) bool) ([]Note, error) {
+	x.mutex.RLock()
+	defer x.mutex.RUnlock()
+	results := make([]Note, 0)
+	for _, key := range x.sortedKeys(nil) {
+		if n := x.notes[key]; match(n) {
+			results = append(results, n)
+		}
+	}
+	return results, nil
+}
+
+func (x *index) Iterate(fn func(Note) bool) error {
+	notes, err := x.Query(func(Note) bool { return true })
+	if err != nil {
+		return err
+	}
+	for _, n := range notes {
+		if !fn(n) {
+			break
+		}
+	}
+	return nil
+}
+
+func (x *index) Replace(notes []Note) error {
+	x.mutex.Lock()
+	defer x.mutex.Unlock()
+	err := x.Store.Replace(notes)
+	if err != nil {
+		return err
+	}
+	return x.load()
+}
+
+func (x *index) Close() error {
+	if x.watcher != nil {
+		_ = x.watcher.Close()
+	}
+	return x.Store.Close()
+}
+
+// Search is used to fetch notes which may contain all keywords in fields of scope
+// and which have all tags, in order of insertion.
+// Candidates are narrowed by tags and tokens, and keys having Chinese characters are kept
+// for keywords which may be pinyin, so notes found must be checked further.
+func (x *index) Search(keywords []string, tags []string, scope Scope) []Note {
+	x.mutex.RLock()
+	defer x.mutex.RUnlock()
+
+	var candidates map[string]bool
+	narrow := func(keys map[string]bool) {
+		if candidates == nil {
+			candidates = make(map[string]bool, len(keys))
+			for key := range keys {
+				candidates[key] = true
+			}
+			return
+		}
+		for key := range candidates {
+			if !keys[key] {
+				delete(candidates, key)
+			}
+		}
+	}
+	for _, tag := range tags {
+		narrow(x.tags[tag])
+	}
+	for _, keyword := range keywords {
+		for _, token := range tokenize(keyword) {
+			keys := make(map[string]bool)
+			if scope != ScopeVal {
+				x.keysContaining(keys, x.keyTokens, token)
+				if isPinyin(token) {
+					for key := range x.hanKeys {
+						keys[key] = true
+					}
+				}
+			}
+			if scope != ScopeKey {
+				x.keysContaining(keys, x.valTokens, token)
+			}
+			narrow(keys)
+		}
+	}
+
+	results := make([]Note, 0)
+	for _, key := range x.sortedKeys(candidates) {
+		results = append(results, x.notes[key])
+	}
+	return results
+}
+
+// TagCounts is used to count notes of every tag, returning a map of tag to count.
+func (x *index) TagCounts() map[string]int {
+	x.mutex.RLock()
+	defer x.mutex.RUnlock()
+	counts := make(map[string]int, len(x.tags))
+	for tag, keys := range x.tags {
+		counts[tag] = len(keys)
+	}
+	return counts
+}
+
+// keysContaining is used to collect keys of notes which have a token containing part into keys.
+// A keyword containing part must be in such notes, since part is made of letters and digits only.
+func (x *index) keysContaining(keys map[string]bool, tokens map[string]map[string]bool, part string) {
+	for token, posting := range tokens {
+		if !strings.Contains(token, part) {
+			continue
+		}
+		for key := range posting {
+			keys[key] = true
+		}
+	}
+}
+
+// sortedKeys is used to sort keys of specified set in order of insertion,
+// or all keys if the set is nil.
+func (x *index) sortedKeys(set map[string]bool) []string {
+	keys := make([]string, 0, len(x.notes))
+	if set == nil {
+		for key := range x.notes {
+			keys = append(keys, key)
+		}
+	} else {
+		for key := range set {
+			keys = append(keys, key)
+		}
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		return x.seqs[keys[i]] < x.seqs[keys[j]]
+	})
+	return keys
+}
+
+// watch is used to reload the index after the file of specified path changed.
+// The directory is watched rather than the file, since the file may be replaced rather than written.
+func (x *index) watch(path string) error {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return fmt.Errorf("new watcher error: %v", err)
+	}
+	err = watcher.Add(filepath.Dir(path))
+	if err != nil {
+		_ = watcher.Close()
+		return fmt.Errorf("add %s to watcher error: %v", filepath.Dir(path), err)
+	}
+	x.watcher = watcher
+
+	go func() {
+		var timer *time.Timer
+		for {
+			select {
+			case event, ok := <-watcher.Events:
+				if !ok {
+					return
+				}
+				if filepath.Clean(event.Name) != filepath.Clean(path) {
+					continue
+				}
+				if timer != nil {
+					timer.Stop()
+				}
+				timer = time.AfterFunc(watchDelay, x.refresh)
+			case err, ok := <-watcher.Errors:
+				if !ok {
+					return
+				}
+				logs.Warn("note: watch %s error: %s", path, err.Error())
+			}
+		}
+	}()
+	return nil
+}
+
+// refresh is used to reload the index if the store was changed by others.
+func (x *index) refresh() {
+	x.mutex.Lock()
+	defer x.mutex.Unlock()
+	modTime, err := x.Store.ModTime()
+	if err != nil {
+		logs.Warn("note: get modified time error: %s", err.Error())
+		return
+	}
+	if modTime.Equal(x.modTime) {
+		return
+	}
+	err = x.load()
+	if err != nil {
+		logs.Error("reload note error: %s\n", err.Error())
+		return
+	}
+	logs.Info("note: reloaded %d notes changed by others", len(x.notes))
+}
+
+// tokenize is used to split text into normalized tokens of letters and digits, see normalizeKeyword.
+func tokenize(text string) []string {
+	return strings.FieldsFunc(normalizeKeyword(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// addPosting is used to record that the note of key contains token.
+func addPosting(tokens map[string]map[string]bool, token, key string) {
+	posting, ok := tokens[token]
+	if !ok {
+		posting = make(map[string]bool)
+		tokens[token] = posting
+	}
+	posting[key] = true
+}
+
+// removePosting is used to forget that the note of key contains token.
+func removePosting(tokens map[string]map[string]bool, token, key string) {
+	posting, ok := tokens[token]
+	if !ok {
+		return
+	}
+	delete(posting, key)
+	if len(posting) == 0 {
+		delete(tokens, token)
+	}
+}
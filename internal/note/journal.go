@@ -0,0 +1,502 @@
+package note
+
+import (
+	"bytes"
+	"find/internal/files"
+	"find/internal/logs"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// journalHeaderPrefix starts the first line of journal, followed by the version of notes in it.
+const journalHeaderPrefix = "#FIND journal v"
+
+const (
+	// journalPut starts a record of adding or modifying a note, followed by the encoded note.
+	journalPut = '+'
+	// journalDelete starts a record of deleting a note, followed by the escaped key.
+	journalDelete = '-'
+	// journalCommit is the line after records of each change,
+	// records without it are torn by a crash and ignored.
+	journalCommit = "."
+)
+
+// compactMinGarbage is the least number of garbage records to compact the journal,
+// so that a small journal isn't compacted again and again.
+const compactMinGarbage = 100
+
+// journalStore keeps notes as an append-only journal of changes, which is replayed on open,
+// so that changing notes only appends a few lines no matter how many notes there are.
+// The journal is compacted in background once garbage records outnumber live notes.
+type journalStore struct {
+	path  string
+	mutex sync.Mutex
+	// notes and seqs are the state replayed from the journal.
+	notes map[string]Note
+	seqs  map[string]int64
+	seq   int64
+	// records is the number of put and delete records in the journal.
+	records int
+	// offset is where the last committed change ends in the journal.
+	offset int64
+	// fileInfo identifies the replayed journal, which is replaced by compaction.
+	fileInfo os.FileInfo
+	// generation grows each time the journal is rewritten, which invalidates cursors of changes.
+	generation int
+	compacting bool
+	// lock is the lock of notebook, which is taken while compacting.
+	lock func() (func(), error)
+}
+
+// change is a committed change of notes in the journal,
+// which is also pushed to backup as json.
+type change struct {
+	Op   string `json:"op"`
+	Note *Note  `json:"note,omitempty"`
+	Key  string `json:"key,omitempty"`
+}
+
+const (
+	changeOpPut    = "put"
+	changeOpDelete = "delete"
+)
+
+// openJournalStore is used to open the journal of specified path, creating it if not exists,
+// returning the store, whether it's newly created, and error.
+// A torn change at the end is cut off, and a journal of an older version is rewritten.
+func openJournalStore(path string) (*journalStore, bool, error) {
+	s := &journalStore{path: path}
+	if _, err := os.Stat(path); err != nil {
+		// If journal not exists, then create.
+		err = s.rewrite(nil)
+		if err != nil {
+			return nil, false, fmt.Errorf("create %s error: %v", path, err)
+		}
+		return s, true, nil
+	}
+
+	version, err := s.replay()
+	if err != nil {
+		return nil, false, fmt.Errorf("replay %s error: %v", path, err)
+	}
+	if version != currentVersion {
+		logs.Info("note: migrate journal from v%d to v%d", version, currentVersion)
+		return s, false, s.rewrite(s.sorted())
+	}
+	if s.fileInfo.Size() > s.offset {
+		logs.Warn("note: cut off torn change at the end of %s", path)
+		err = os.Truncate(path, s.offset)
+		if err != nil {
+			return nil, false, fmt.Errorf("truncate %s error: %v", path, err)
+		}
+	}
+	return s, false, nil
+}
+
+// replay is used to rebuild the state by reading the whole journal,
+// returning version of notes in the journal and error.
+func (s *journalStore) replay() (int, error) {
+	s.notes = make(map[string]Note)
+	s.seqs = make(map[string]int64)
+	s.records = 0
+	s.offset = 0
+	s.generation++
+
+	file, err := os.Open(s.path)
+	if err != nil {
+		return 0, err
+	}
+	defer func() {
+		_ = file.Close()
+	}()
+	s.fileInfo, err = file.Stat()
+	if err != nil {
+		return 0, err
+	}
+
+	data, err := io.ReadAll(file)
+	if err != nil {
+		return 0, err
+	}
+	i := bytes.IndexByte(data, '\n')
+	if i == -1 || !strings.HasPrefix(string(data[:i]), journalHeaderPrefix) {
+		return 0, fmt.Errorf("missing header")
+	}
+	version, err := strconv.Atoi(strings.TrimSpace(strings.TrimPrefix(string(data[:i]), journalHeaderPrefix)))
+	if err != nil || version > currentVersion {
+		return 0, fmt.Errorf("unsupported header %s", data[:i])
+	}
+
+	s.offset = int64(i + 1)
+	changes, n, err := parseJournal(data[i+1:], version)
+	if err != nil {
+		return 0, err
+	}
+	s.apply(changes)
+	s.offset += n
+	return version, nil
+}
+
+// catchUp is used to replay changes appended by others since last replay,
+// or the whole journal if it has been rewritten by others.
+func (s *journalStore) catchUp() error {
+	fileInfo, err := os.Stat(s.path)
+	if err != nil {
+		return err
+	}
+	if !os.SameFile(fileInfo, s.fileInfo) || fileInfo.Size() < s.offset {
+		_, err = s.replay()
+		return err
+	}
+	if fileInfo.Size() == s.offset {
+		return nil
+	}
+
+	data, err := readFrom(s.path, s.offset)
+	if err != nil {
+		return err
+	}
+	changes, n, err := parseJournal(data, currentVersion)
+	if err != nil {
+		return err
+	}
+	s.apply(changes)
+	s.offset += n
+	s.fileInfo = fileInfo
+	return nil
+}
+
+// apply is used to apply committed changes to the state.
+func (s *journalStore) apply(changes []change) {
+	for _, c := range changes {
+		s.records++
+		switch c.Op {
+		case changeOpPut:
+			s.seq++
+			s.notes[c.Note.Key] = *c.Note
+			s.seqs[c.Note.Key] = s.seq
+		case changeOpDelete:
+			delete(s.notes, c.Key)
+			delete(s.seqs, c.Key)
+		}
+	}
+}
+
+// append is used to write records of a change with a commit line to the end of journal,
+// and apply the change to the state. It must be called with mutex locked.
+func (s *journalStore) append(changes []change) error {
+	err := s.catchUp()
+	if err != nil {
+		return fmt.Errorf("catch up %s error: %v", s.path, err)
+	}
+	if s.fileInfo.Size() > s.offset {
+		// Cut off a change torn by others, otherwise it would swallow the change appended.
+		err = os.Truncate(s.path, s.offset)
+		if err != nil {
+			return fmt.Errorf("truncate %s error: %v", s.path, err)
+		}
+	}
+
+	var b strings.Builder
+	for _, c := range changes {
+		b.WriteString(formatChange(c))
+		b.WriteByte('\n')
+	}
+	b.WriteString(journalCommit + "\n")
+
+	file, err := os.OpenFile(s.path, os.O_WRONLY|os.O_APPEND, 0)
+	if err != nil {
+		return fmt.Errorf("open %s error: %v", s.path, err)
+	}
+	_, err = file.WriteString(b.String())
+	if err == nil {
+		err = file.Sync()
+	}
+	closeErr := file.Close()
+	if err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("append to %s error: %v", s.path, err)
+	}
+
+	s.apply(changes)
+	s.offset += int64(b.Len())
+	s.fileInfo, err = os.Stat(s.path)
+	if err != nil {
+		return err
+	}
+	s.compactIfNecessary()
+	return nil
+}
+
+// rewrite is used to replace the journal by one put record for each note atomically,
+// which drops all garbage records. It must be called with mutex locked.
+func (s *journalStore) rewrite(notes []Note) error {
+	lines := make([]string, 0, len(notes)+2)
+	lines = append(lines, journalHeaderPrefix+strconv.Itoa(currentVersion))
+	for i := range notes {
+		lines = append(lines, formatChange(change{Op: changeOpPut, Note: &notes[i]}))
+	}
+	if len(notes) > 0 {
+		lines = append(lines, journalCommit)
+	}
+	err := files.WriteLinesToPathAtomically(s.path, &lines)
+	if err != nil {
+		return err
+	}
+	_, err = s.replay()
+	return err
+}
+
+// compactIfNecessary is used to compact the journal in background
+// if garbage records outnumber live notes and the lock of notebook is given.
+// It must be called with mutex locked.
+func (s *journalStore) compactIfNecessary() {
+	garbage := s.records - len(s.notes)
+	if !background || s.lock == nil || s.compacting || garbage < compactMinGarbage || garbage < len(s.notes) {
+		return
+	}
+	s.compacting = true
+	go func() {
+		err := s.compact()
+		if err != nil {
+			logs.Error("compact %s error: %s\n", s.path, err.Error())
+		}
+	}()
+}
+
+// compact is used to rewrite the journal with the lock of note taken,
+// so that no one appends to the journal meanwhile.
+func (s *journalStore) compact() error {
+	defer func() {
+		s.mutex.Lock()
+		s.compacting = false
+		s.mutex.Unlock()
+	}()
+	unlock, err := s.lock()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	err = s.catchUp()
+	if err != nil {
+		return err
+	}
+	before := s.records
+	err = s.rewrite(s.sorted())
+	if err != nil {
+		return err
+	}
+	logs.Info("note: compacted %s from %d records to %d", s.path, before, s.records)
+	return nil
+}
+
+// sorted is used to get notes of the state in order of insertion.
+func (s *journalStore) sorted() []Note {
+	notes := make([]Note, 0, len(s.notes))
+	for _, n := range s.notes {
+		notes = append(notes, n)
+	}
+	sort.Slice(notes, func(i, j int) bool {
+		return s.seqs[notes[i].Key] < s.seqs[notes[j].Key]
+	})
+	return notes
+}
+
+// cursor is used to get the position of the latest change, which changesSince accepts.
+func (s *journalStore) cursor() (string, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	err := s.catchUp()
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%d:%d", s.generation, s.offset), nil
+}
+
+// changesSince is used to get changes after specified cursor along with the cursor of latest change,
+// and ok is false if the changes are not available any more since the journal has been rewritten.
+func (s *journalStore) changesSince(cursor string) (changes []change, next string, ok bool, err error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	err = s.catchUp()
+	if err != nil {
+		return nil, "", false, err
+	}
+	next = fmt.Sprintf("%d:%d", s.generation, s.offset)
+
+	var generation int
+	var offset int64
+	_, scanErr := fmt.Sscanf(cursor, "%d:%d", &generation, &offset)
+	if scanErr != nil || generation != s.generation || offset > s.offset {
+		return nil, next, false, nil
+	}
+
+	data, err := readFrom(s.path, offset)
+	if err != nil {
+		return nil, "", false, err
+	}
+	changes, _, err = parseJournal(data[:s.offset-offset], currentVersion)
+	if err != nil {
+		return nil, "", false, err
+	}
+	return changes, next, true, nil
+}
+
+func (s *journalStore) Get(key string) (*Note, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	err := s.catchUp()
+	if err != nil {
+		return nil, err
+	}
+	n, ok := s.notes[key]
+	if !ok {
+		return nil, nil
+	}
+	return &n, nil
+}
+
+func (s *journalStore) Put(notes ...Note) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	changes := make([]change, 0, len(notes))
+	for i := range notes {
+		changes = append(changes, change{Op: changeOpPut, Note: &notes[i]})
+	}
+	return s.append(changes)
+}
+
+func (s *journalStore) Delete(keys ...string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	changes := make([]change, 0, len(keys))
+	for _, key := range keys {
+		changes = append(changes, change{Op: changeOpDelete, Key: key})
+	}
+	return s.append(changes)
+}
+
+func (s *journalStore) Query(match func(Note) bool) ([]Note, error) {
+	results := make([]Note, 0)
+	err := s.Iterate(func(n Note) bool {
+		if match(n) {
+			results = append(results, n)
+		}
+		return true
+	})
+	return results, err
+}
+
+func (s *journalStore) Iterate(fn func(Note) bool) error {
+	s.mutex.Lock()
+	err := s.catchUp()
+	notes := s.sorted()
+	s.mutex.Unlock()
+	if err != nil {
+		return err
+	}
+	for _, n := range notes {
+		if !fn(n) {
+			break
+		}
+	}
+	return nil
+}
+
+func (s *journalStore) Replace(notes []Note) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.rewrite(notes)
+}
+
+func (s *journalStore) ModTime() (time.Time, error) {
+	fileInfo, err := os.Stat(s.path)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("stat %s error: %v", s.path, err)
+	}
+	return fileInfo.ModTime(), nil
+}
+
+func (s *journalStore) Close() error {
+	return nil
+}
+
+// formatChange is used to format a change into a record line of current version.
+func formatChange(c change) string {
+	if c.Op == changeOpDelete {
+		return string(journalDelete) + escape(c.Key, true)
+	}
+	return string(journalPut) + encodeNote(*c.Note)
+}
+
+// parseJournal is used to parse records of specified version into committed changes,
+// returning the changes, length of data they take up, and error.
+// Records after the last commit line are left out, even if they are broken.
+func parseJournal(data []byte, version int) ([]change, int64, error) {
+	var changes, pending []change
+	var committed int64
+	var pos int64
+	// broken is used to decide whether a broken record is torn by a crash or corrupted:
+	// nothing is committed after a torn record. pos is where the next line starts,
+	// so the search starts from the line break before it, in case the next line is a commit.
+	broken := func(pos int64, err error) ([]change, int64, error) {
+		if bytes.Contains(data[pos-1:], []byte("\n"+journalCommit+"\n")) {
+			return nil, 0, err
+		}
+		return changes, committed, nil
+	}
+
+	for {
+		i := bytes.IndexByte(data[pos:], '\n')
+		if i == -1 {
+			return changes, committed, nil
+		}
+		line := string(data[pos : pos+int64(i)])
+		pos += int64(i) + 1
+
+		switch {
+		case line == journalCommit:
+			changes = append(changes, pending...)
+			pending = nil
+			committed = pos
+		case strings.HasPrefix(line, string(journalPut)):
+			n, _, err := decodeNote(line[1:], version)
+			if err != nil {
+				return broken(pos, fmt.Errorf("decode record at %d error: %v", pos, err))
+			}
+			pending = append(pending, change{Op: changeOpPut, Note: &n})
+		case strings.HasPrefix(line, string(journalDelete)):
+			pending = append(pending, change{Op: changeOpDelete, Key: unescape(line[1:])})
+		case line == "":
+		default:
+			return broken(pos, fmt.Errorf("invalid record at %d of %d bytes", pos, len(line)))
+		}
+	}
+}
+
+// readFrom is used to read data of file of specified path from offset to the end.
+func readFrom(path string, offset int64) ([]byte, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = file.Close()
+	}()
+	_, err = file.Seek(offset, io.SeekStart)
+	if err != nil {
+		return nil, err
+	}
+	return io.ReadAll(file)
+}
@@ -0,0 +1,155 @@
+package note
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"testing"
+)
+
+// journalKeys is used to get keys and values of notes replayed by s.
+func journalKeys(t *testing.T, s *journalStore) map[string]string {
+	notes, err := s.Query(func(Note) bool { return true })
+	if err != nil {
+		t.Fatalf("query error: %v", err)
+	}
+	got := make(map[string]string, len(notes))
+	for _, n := range notes {
+		got[n.Key] = n.Val
+	}
+	return got
+}
+
+func TestJournalReplayTornLastRecord(t *testing.T) {
+	a, b, c := storeNote("a", "1"), storeNote("b:x", "2\n3"), storeNote("c", "4")
+	committed := journalHeaderPrefix + strconv.Itoa(currentVersion) + "\n" +
+		formatChange(change{Op: changeOpPut, Note: &a}) + "\n" +
+		formatChange(change{Op: changeOpPut, Note: &b}) + "\n" + journalCommit + "\n" +
+		formatChange(change{Op: changeOpDelete, Key: a.Key}) + "\n" + journalCommit + "\n"
+	put := formatChange(change{Op: changeOpPut, Note: &c})
+
+	tests := []struct {
+		name string
+		torn string
+	}{
+		{"nothing torn", ""},
+		{"record without commit", put + "\n"},
+		{"half a record", put[:len(put)/2]},
+		{"broken record without commit", "+@broken\n"},
+		{"delete and put without commit", formatChange(change{Op: changeOpDelete, Key: b.Key}) + "\n" + put + "\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "FIND.journal")
+			err := os.WriteFile(path, []byte(committed+tt.torn), 0644)
+			if err != nil {
+				t.Fatal(err)
+			}
+			s, isNew, err := openJournalStore(path)
+			if err != nil {
+				t.Fatalf("open error: %v", err)
+			}
+			if isNew {
+				t.Errorf("open got a new journal, want the existing one")
+			}
+			want := map[string]string{b.Key: b.Val}
+			if got := journalKeys(t, s); !reflect.DeepEqual(got, want) {
+				t.Errorf("replay got %v, want %v", got, want)
+			}
+			data, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(data) != committed {
+				t.Errorf("journal after open is %q, want the torn change cut off", data)
+			}
+
+			err = s.Put(c)
+			if err != nil {
+				t.Fatalf("put error: %v", err)
+			}
+			reopened, _, err := openJournalStore(path)
+			if err != nil {
+				t.Fatalf("reopen error: %v", err)
+			}
+			want[c.Key] = c.Val
+			if got := journalKeys(t, reopened); !reflect.DeepEqual(got, want) {
+				t.Errorf("replay after put got %v, want %v", got, want)
+			}
+		})
+	}
+}
+
+func TestJournalChangesSince(t *testing.T) {
+	s, _, err := openJournalStore(filepath.Join(t.TempDir(), "FIND.journal"))
+	if err != nil {
+		t.Fatalf("open error: %v", err)
+	}
+	a := storeNote("a", "1")
+	cursor, err := s.cursor()
+	if err != nil {
+		t.Fatalf("cursor error: %v", err)
+	}
+	if err = s.Put(a); err != nil {
+		t.Fatalf("put error: %v", err)
+	}
+	if err = s.Delete("b"); err != nil {
+		t.Fatalf("delete error: %v", err)
+	}
+
+	changes, next, ok, err := s.changesSince(cursor)
+	if err != nil || !ok {
+		t.Fatalf("changes got ok %v and error %v, want changes", ok, err)
+	}
+	want := []change{{Op: changeOpPut, Note: &a}, {Op: changeOpDelete, Key: "b"}}
+	if !reflect.DeepEqual(changes, want) {
+		t.Errorf("changes got %+v, want %+v", changes, want)
+	}
+	changes, _, ok, err = s.changesSince(next)
+	if err != nil || !ok || len(changes) != 0 {
+		t.Errorf("changes since the latest got %+v, %v and error %v, want nothing", changes, ok, err)
+	}
+
+	if err = s.Replace([]Note{a}); err != nil {
+		t.Fatalf("replace error: %v", err)
+	}
+	if _, _, ok, err = s.changesSince(next); err != nil || ok {
+		t.Errorf("changes after rewritten got ok %v and error %v, want not available", ok, err)
+	}
+}
+
+func TestJournalReplayCorruptedRecord(t *testing.T) {
+	a := storeNote("a", "1")
+	header := journalHeaderPrefix + strconv.Itoa(currentVersion) + "\n"
+	put := formatChange(change{Op: changeOpPut, Note: &a}) + "\n"
+
+	tests := []struct {
+		name    string
+		journal string
+	}{
+		{"broken record before its commit", header + "+@broken\n" + journalCommit + "\n"},
+		{"broken record before a later commit", header + "+@broken\n" + journalCommit + "\n" + put + journalCommit + "\n"},
+		{"unknown record before a commit", header + put + "?\n" + journalCommit + "\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "FIND.journal")
+			err := os.WriteFile(path, []byte(tt.journal), 0644)
+			if err != nil {
+				t.Fatal(err)
+			}
+			_, _, err = openJournalStore(path)
+			if err == nil {
+				t.Fatalf("open got no error, want the corrupted record reported")
+			}
+			data, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(data) != tt.journal {
+				t.Errorf("journal after open is %q, want it untouched", data)
+			}
+		})
+	}
+}
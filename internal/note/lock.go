@@ -0,0 +1,57 @@
+package note
+
+import (
+	"context"
+	"find/internal/logs"
+	"fmt"
+	"time"
+
+	"github.com/gofrs/flock"
+)
+
+// lockTimeout is how long to wait for another FIND to finish changing the note.
+const lockTimeout = 5 * time.Second
+
+// lockRetryDelay is how often to retry taking the lock while waiting.
+const lockRetryDelay = 100 * time.Millisecond
+
+// lock is used to take the lock of notebook, waiting lockTimeout at most if it's held by others,
+// and reload the index if others changed the notebook before,
+// returning a function to release the lock and error.
+func (b *Notebook) lock() (func(), error) {
+	b.lockMutex.Lock()
+	if b.fileLock == nil {
+		b.fileLock = flock.New(b.storePath() + ".lock")
+	}
+	fileLock := b.fileLock
+
+	ok, err := fileLock.TryLock()
+	if err == nil && !ok {
+		logs.Info("note: %s is locked by others, waiting", fileLock.Path())
+		ctx, cancel := context.WithTimeout(context.Background(), lockTimeout)
+		defer cancel()
+		ok, err = fileLock.TryLockContext(ctx, lockRetryDelay)
+		if err == context.DeadlineExceeded {
+			err = nil
+		}
+	}
+	if err != nil {
+		b.lockMutex.Unlock()
+		return nil, fmt.Errorf("lock %s error: %v", fileLock.Path(), err)
+	}
+	if !ok {
+		b.lockMutex.Unlock()
+		return nil, fmt.Errorf("note is being changed by another FIND for more than %v, please try again later", lockTimeout)
+	}
+
+	if b.store != nil {
+		b.store.refresh()
+	}
+	return func() {
+		err := fileLock.Unlock()
+		if err != nil {
+			logs.Warn("note: unlock %s error: %s", fileLock.Path(), err.Error())
+		}
+		b.lockMutex.Unlock()
+	}, nil
+}
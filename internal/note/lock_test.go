@@ -0,0 +1,43 @@
+package note
+
+import (
+	"testing"
+	"time"
+
+	"github.com/gofrs/flock"
+)
+
+func TestLockWaitsForOthers(t *testing.T) {
+	b := testNotebook(t)
+	// A lock of its own file stands for another FIND, since flock isn't shared by files opened twice.
+	other := flock.New(b.storePath() + ".lock")
+	ok, err := other.TryLock()
+	if err != nil || !ok {
+		t.Fatalf("lock by others got %v and error %v", ok, err)
+	}
+	const held = 300 * time.Millisecond
+	go func() {
+		time.Sleep(held)
+		_ = other.Unlock()
+	}()
+
+	start := time.Now()
+	unlock, err := b.lock()
+	if err != nil {
+		t.Fatalf("lock error: %v", err)
+	}
+	if waited := time.Since(start); waited < held {
+		t.Errorf("lock waited %v, want at least %v", waited, held)
+	}
+	ok, err = other.TryLock()
+	if err != nil || ok {
+		t.Errorf("lock by others while locked got %v and error %v, want false", ok, err)
+	}
+	unlock()
+
+	ok, err = other.TryLock()
+	if err != nil || !ok {
+		t.Errorf("lock by others after unlock got %v and error %v, want true", ok, err)
+	}
+	_ = other.Unlock()
+}
@@ -2,199 +2,557 @@
 package note
 
 import (
+	"encoding/json"
 	"find/internal/backup"
 	"find/internal/config"
 	"find/internal/constant"
-	"find/internal/files"
 	"find/internal/logs"
 	"find/internal/redish"
 	"find/internal/stdin"
 	"fmt"
+	uuid "github.com/nu7hatch/gouuid"
 	"os"
+	"sort"
 	"strings"
+	"time"
 )
 
-// Path is the path of note which is loaded from config.
-var Path string
+// host is the name of this computer, which is recorded as the last modifier of notes.
+var host string
 
 func init() {
-	Path = config.Conf.Find.NotePath
+	host, _ = os.Hostname()
 }
 
-// Check is used to ensure that the note is available,
+// Note is a key-value record of user's data, along with metadata of its changes.
+type Note struct {
+	ID      string    `json:"id"`
+	Key     string    `json:"key"`
+	Val     string    `json:"val"`
+	Created time.Time `json:"created"`
+	Updated time.Time `json:"updated"`
+	// Host is the computer which modified the note last time.
+	Host string `json:"host"`
+	// Rev is the number of revision, which grows each time the note is modified.
+	Rev int `json:"rev"`
+	// Tags are sorted lower-case words for grouping notes, like 'db' of '#db' in user's input.
+	Tags []string `json:"tags,omitempty"`
+}
+
+// New is used to create a note with fresh metadata, returning the note and error.
+func New(key, val string) (Note, error) {
+	id, err := uuid.NewV4()
+	if err != nil {
+		return Note{}, fmt.Errorf("generate id error: %v", err)
+	}
+	now := time.Now()
+	return Note{
+		ID:      id.String(),
+		Key:     key,
+		Val:     val,
+		Created: now,
+		Updated: now,
+		Host:    host,
+		Rev:     1,
+	}, nil
+}
+
+// Parse is used to create a note from user's input like 'key #tag:content', returning the note and error.
+// A ':' or '\' in the key should be escaped like '\:' or '\\', while the content is taken as it is.
+// Words starting with '#' before ':' are tags rather than part of the key, unless escaped like '\#'.
+func Parse(input string) (Note, error) {
+	i := indexUnescaped(input, ':')
+	if i == -1 {
+		return Note{}, fmt.Errorf("missing ':' between key and content")
+	}
+	key := input[:i]
+	var tags []string
+	if strings.Contains(key, tagPrefix) {
+		var words []string
+		words, tags = splitTags(strings.Split(key, " "))
+		key = strings.TrimSpace(strings.Join(words, " "))
+	}
+	if key == "" {
+		return Note{}, fmt.Errorf("missing key")
+	}
+	n, err := New(unescape(key), input[i+1:])
+	n.Tags = tags
+	return n, err
+}
+
+// Check is used to ensure that the store of notebook is available,
 // and then synchronize if redis config is available too.
-func Check() error {
-	logs.Info("note: check start")
-	fileInfo, err := os.Stat(Path)
-	isNewNote := err != nil
-	var file *os.File
-	if isNewNote {
-		// If note not exists, then create.
-		file, err = os.Create(Path)
+func (b *Notebook) Check() error {
+	b.checkMutex.Lock()
+	defer b.checkMutex.Unlock()
+	logs.Info("note: check %s start", b.conf.Name)
+	if b.store == nil {
+		unlock, err := b.lock()
 		if err != nil {
-			return fmt.Errorf("create %s error: %v", Path, err)
+			return err
 		}
-	} else {
-		// If note exists, then open.
-		file, err = os.OpenFile(Path, os.O_RDWR, 0)
+		s, isNew, err := b.openStore()
+		unlock()
 		if err != nil {
-			return fmt.Errorf("open %s error: %v", Path, err)
+			return fmt.Errorf("open store error: %v", err)
+		}
+		watchPath := ""
+		if background {
+			watchPath = b.storePath()
+		}
+		x, err := newIndex(s, watchPath)
+		if err != nil {
+			_ = s.Close()
+			return fmt.Errorf("index store error: %v", err)
+		}
+		b.store = x
+		b.isNew = isNew
+		if b.sealer != nil && b.sealer.plain {
+			err = b.resumeConversion()
+			if err != nil {
+				return fmt.Errorf("resume encryption error: %v", err)
+			}
 		}
 	}
-	defer func() {
-		_ = file.Close()
-	}()
 
-	if config.RedisKey() != "" && redish.Client != nil {
+	if key := config.RedisKey(b.conf.Username); background && key != "" && redish.Client != nil {
 		// If redis config is available, then sync.
-		err = backup.Sync(isNewNote, file, fileInfo)
+		err := b.sync(key)
 		if err != nil {
-			return fmt.Errorf("sync backup error: %v", err)
+			return err
 		}
 	}
 
-	logs.Info("note: check finished")
+	logs.Info("note: check %s finished", b.conf.Name)
 	return nil
 }
 
-// Find is used to lookup note according to keyword from user's input and multiple options,
-// returning a string slice of result and error.
-func Find(keyword string, include bool, accurate bool, print bool) ([]string, error) {
-	keywords := strings.Split(keyword, " ")
-	notes, err := files.ReadLinesFromPath(Path)
+// sync is used to pull or push the redis backup of specified key,
+// which is skipped if the notebook is encrypted, since backups are kept in plain text.
+func (b *Notebook) sync(key string) error {
+	if b.Encrypted() {
+		logs.Info("note: skip backup of %s, since it's encrypted", b.conf.Name)
+		return nil
+	}
+	modTime, err := b.store.ModTime()
+	if err != nil {
+		return fmt.Errorf("get modified time error: %v", err)
+	}
+	// Keep fractions of a second, or changes within a second after last sync would be missed.
+	lastModTime := float64(modTime.UnixNano()) / float64(time.Second)
+	err = backup.Sync(key, b.isNew, lastModTime, local{b})
 	if err != nil {
-		return nil, fmt.Errorf("read lines from %s error: %v", Path, err)
+		return fmt.Errorf("sync backup error: %v", err)
 	}
+	b.isNew = false
+	return nil
+}
 
-	results := make([]string, 0)
-	for _, note := range notes {
-		var hit bool
-		if accurate {
-			hit = GetKey(note) == keyword
-		} else {
-			hit = containsAll(GetKey(note), keywords)
+// available is used to ensure that the store has been opened by Check,
+// which is tried again if it failed before (e.g. the note was locked by another FIND).
+func (b *Notebook) available() error {
+	if b.store == nil {
+		err := b.Check()
+		if err != nil {
+			return fmt.Errorf("note is not available: %v", err)
 		}
+	}
+	return nil
+}
 
-		if !include {
-			hit = !hit
-		}
+// Find is used to lookup note according to keyword from user's input,
+// returning a slice of result and error.
+// If accurate is true, keyword is the key of the note,
+// or else keyword is a query like 'mysql OR redis -test #prod',
+// where text is searched in keys and words like 'prod.db.*' are subtrees which keys must be in,
+// see query.Parse for the syntax.
+func (b *Notebook) Find(keyword string, accurate bool) ([]Note, error) {
+	if err := b.available(); err != nil {
+		return nil, err
+	}
+	if !accurate {
+		notes, _, err := b.search(keyword, ScopeKey, ModeSubstring)
+		return notes, err
+	}
+	return b.store.Query(func(note Note) bool {
+		return note.Key == keyword
+	})
+}
 
-		if hit {
-			results = append(results, note)
-		}
+// Print is used to show notes to the user, along with metadata if long is true.
+func Print(notes []Note, long bool) {
+	if len(notes) == 0 {
+		fmt.Println("Empty result.")
+		return
+	}
+	for _, note := range notes {
+		printNote(note, long)
 	}
+}
 
-	if print {
-		if len(results) == 0 {
-			fmt.Println("Empty result.")
-		} else {
-			for _, result := range results {
-				fmt.Printf("%s: %s\n", GetKey(result), GetVal(result))
-			}
-		}
+// printNote is used to show a note to the user, along with metadata if long is true.
+func printNote(note Note, long bool) {
+	fmt.Printf("%s: %s\n", title(note), shown(note))
+	if long {
+		printMeta(note)
 	}
+}
 
-	return results, nil
+// printMeta is used to show metadata of a note to the user.
+func printMeta(note Note) {
+	fmt.Printf("    revision %d updated %s on %s, created %s, id %s\n",
+		note.Rev, note.Updated.Format(timeLayout), note.Host, note.Created.Format(timeLayout), note.ID)
 }
 
-// containsAll is used to judge if source string contains all target strings ignoring the case,
-// returning true if contains all and false otherwise.
-func containsAll(source string, targets []string) bool {
-	for _, target := range targets {
-		if !strings.Contains(strings.ToLower(source), strings.ToLower(target)) {
-			return false
+// timeLayout is the layout of time shown to the user.
+const timeLayout = "2006-01-02 15:04:05"
+
+// Write is used to persist notes into store, replacing the ones with same keys,
+// and will asynchronously update the backup if the redis config is available.
+// Revision numbers of notes follow their history, in case the keys were used before.
+func (b *Notebook) Write(notes []Note) error {
+	if err := b.available(); err != nil {
+		return err
+	}
+	unlock, err := b.lock()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	olds, err := b.currents(notes)
+	if err != nil {
+		return err
+	}
+	for i := range notes {
+		notes[i].Rev, err = b.nextRev(notes[i].Key, olds[notes[i].Key])
+		if err != nil {
+			return err
 		}
 	}
-	return true
+	err = b.put(notes, olds, SourceUser)
+	if err != nil {
+		return err
+	}
+	keys := make([]string, 0, len(notes))
+	for _, n := range notes {
+		keys = append(keys, n.Key)
+	}
+	b.pushUndo(describe("add", keys...), olds, notes)
+	return nil
 }
 
-// Write is used to persist notes into local data file by specified mode,
+// put is used to persist notes into store with the lock taken and record them in history,
 // and will asynchronously update the backup if the redis config is available.
-func Write(notes *[]string, mod int) error {
-	file, err := os.OpenFile(Path, mod, 0)
+// The olds are current notes with the same keys.
+func (b *Notebook) put(notes []Note, olds map[string]*Note, source string) error {
+	err := b.store.Put(notes...)
 	if err != nil {
-		return fmt.Errorf("open %s error: %v", Path, err)
+		return fmt.Errorf("put notes error: %v", err)
 	}
-
-	err = files.WriteLinesToFile(file, notes)
+	err = b.record(notes, olds, source)
 	if err != nil {
-		return fmt.Errorf("write %v to %v error: %v", notes, file, err)
+		// The notes are changed anyway, so only the history is missing.
+		logs.Error("record history error: %s\n", err.Error())
+	}
+	b.checkAsync()
+	return nil
+}
+
+// currents is used to get current notes with the same keys as specified notes,
+// returning a map of key to note which is nil if not exists, and error.
+func (b *Notebook) currents(notes []Note) (map[string]*Note, error) {
+	olds := make(map[string]*Note, len(notes))
+	for _, n := range notes {
+		old, err := b.store.Get(n.Key)
+		if err != nil {
+			return nil, fmt.Errorf("get %s error: %v", n.Key, err)
+		}
+		olds[n.Key] = old
 	}
+	return olds, nil
+}
 
+// checkAsync is used to run Check in background after notes changed.
+func (b *Notebook) checkAsync() {
+	if !background {
+		return
+	}
 	go func() {
-		err = Check()
+		err := b.Check()
 		if err != nil {
 			logs.Error("check note error: %s", err.Error())
 		}
 	}()
-
-	return nil
 }
 
-// Delete is used to remove note from local data file after optional confirming,
+// Delete is used to remove note from store after optional confirming,
 // and will asynchronously update the backup if the redis config is available.
-func Delete(keyword string, confirm bool, accurate bool) error {
-	var yesOrNo string
+func (b *Notebook) Delete(keyword string, confirm bool, accurate bool) error {
+	notes, err := b.Find(keyword, accurate)
+	if err != nil {
+		return fmt.Errorf("find %s error: %v", keyword, err)
+	}
+
+	sure := true
 	if confirm {
 		fmt.Println("Will delete:")
-		_, err := Find(keyword, true, accurate, true)
+		Print(notes, false)
+		sure, err = Confirmed("Sure delete?")
 		if err != nil {
-			return fmt.Errorf("find %s error: %v", keyword, err)
+			return err
 		}
-		fmt.Println("Sure delete? [y/n]")
-		tmp, err := stdin.ReadString()
+	}
+
+	if sure && len(notes) > 0 {
+		unlock, err := b.lock()
 		if err != nil {
-			return fmt.Errorf("read input error: %v", err)
+			return err
 		}
-		yesOrNo = tmp
-	} else {
-		yesOrNo = constant.Yes
-	}
+		defer unlock()
 
-	if yesOrNo == constant.Yes {
-		notes, err := Find(keyword, false, accurate, false)
+		olds, err := b.currents(notes)
 		if err != nil {
-			return fmt.Errorf("find %s error: %v", keyword, err)
+			return err
+		}
+		keys := make([]string, 0, len(notes))
+		deleted := make([]Note, 0, len(notes))
+		befores := make(map[string]*Note, len(notes))
+		for key, old := range olds {
+			if old != nil {
+				keys = append(keys, key)
+				deleted = append(deleted, *old)
+				befores[key] = old
+			}
 		}
-		err = Write(&notes, os.O_RDWR|os.O_TRUNC)
+		err = b.store.Delete(keys...)
 		if err != nil {
-			return fmt.Errorf("write note error: %v", err)
+			return fmt.Errorf("delete notes error: %v", err)
 		}
+		err = b.trash(deleted)
+		if err != nil {
+			// The notes are deleted anyway, so only the copy in trash is missing.
+			logs.Error("move %d notes into trash error: %s\n", len(deleted), err.Error())
+		}
+		b.pushUndo(describe("del", keys...), befores, nil)
+		b.checkAsync()
 	}
 
 	return nil
 }
 
-// Modify is used to update note in local date file by delete and write,
+// Confirmed is used to ask the user a yes-or-no question, returning the answer and error.
+func Confirmed(question string) (bool, error) {
+	fmt.Println(question + " [y/n]")
+	yesOrNo, err := stdin.ReadString()
+	if err != nil {
+		return false, fmt.Errorf("read input error: %v", err)
+	}
+	return yesOrNo == constant.Yes, nil
+}
+
+// describe is used to describe an order of user for undo, returning a short text.
+func describe(order string, keys ...string) string {
+	sort.Strings(keys)
+	if len(keys) > 3 {
+		return fmt.Sprintf("%s %s and %d more", order, strings.Join(keys[:3], ", "), len(keys)-3)
+	}
+	return order + " " + strings.Join(keys, ", ")
+}
+
+// Modify is used to update note in store, or add it if not exists,
 // and will asynchronously update the backup if the redis config is available.
-func Modify(note string) error {
-	err := Delete(GetKey(note), false, true)
+// The id and creation time of the old note are kept if it exists, so are its tags if the note has none,
+// and the change is recorded in history as a new revision from specified source.
+func (b *Notebook) Modify(note Note, source string) error {
+	return b.modify(note, source, describe("mod", note.Key), true)
+}
+
+// modify is used to update note in store like Modify, keeping old tags only if keepTags is true,
+// and record the change as the action for undo if it's made by user.
+func (b *Notebook) modify(note Note, source string, action string, keepTags bool) error {
+	if err := b.available(); err != nil {
+		return err
+	}
+	unlock, err := b.lock()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	olds, err := b.currents([]Note{note})
+	if err != nil {
+		return err
+	}
+	old := olds[note.Key]
+	if old != nil {
+		note.ID = old.ID
+		note.Created = old.Created
+		if keepTags && len(note.Tags) == 0 {
+			note.Tags = old.Tags
+		}
+	}
+	note.Rev, err = b.nextRev(note.Key, old)
 	if err != nil {
-		return fmt.Errorf("delete %s error: %v", GetKey(note), err)
+		return err
 	}
-	err = Write(&[]string{note}, os.O_APPEND)
+	note.Updated = time.Now()
+	note.Host = host
+	err = b.put([]Note{note}, olds, source)
 	if err != nil {
-		return fmt.Errorf("append %s error: %v", note, err)
+		return err
+	}
+	if source == SourceUser {
+		b.pushUndo(action, olds, []Note{note})
 	}
 	return nil
 }
 
-// GetKey is used to parse key of note, returning the key.
-func GetKey(note string) string {
-	i := strings.Index(note, ":")
-	if i == -1 {
-		return ""
+// local is the note side of backup.
+type local struct {
+	b *Notebook
+}
+
+// Dump is used to marshal all notes into json, returning nil if there is no note.
+// Notes of an encrypted notebook are never dumped, since the json is kept in plain text.
+func (l local) Dump() ([]byte, error) {
+	if l.b.Encrypted() {
+		return nil, fmt.Errorf("notebook %s is encrypted", l.b.conf.Name)
 	}
+	notes, err := l.b.store.Query(func(Note) bool { return true })
+	if err != nil {
+		return nil, err
+	}
+	if len(notes) == 0 {
+		return nil, nil
+	}
+	return json.Marshal(notes)
+}
 
-	return note[:i]
+// Load is used to replace all notes by json from backup,
+// which may also be a string slice of 'key:content' pushed by older versions.
+func (l local) Load(data []byte) error {
+	var notes []Note
+	err := json.Unmarshal(data, &notes)
+	if err != nil {
+		var lines []string
+		if json.Unmarshal(data, &lines) != nil {
+			return fmt.Errorf("json unmarshal of %d bytes error: %v", len(data), err)
+		}
+		notes, _, _, err = decode(lines)
+		if err != nil {
+			return fmt.Errorf("decode backup error: %v", err)
+		}
+	}
+	if len(notes) == 0 {
+		return nil
+	}
+	for i := range notes {
+		// Notes pushed before revisions are counted are taken as the first revision.
+		if notes[i].Rev <= 0 {
+			notes[i].Rev = 1
+		}
+	}
+	unlock, err := l.b.lock()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	olds, err := l.b.currents(notes)
+	if err != nil {
+		return err
+	}
+	err = l.b.store.Replace(notes)
+	if err != nil {
+		return err
+	}
+	changed := make([]Note, 0)
+	for _, n := range notes {
+		if old := olds[n.Key]; old == nil || old.Rev != n.Rev || old.Val != n.Val {
+			changed = append(changed, n)
+		}
+	}
+	return l.b.recordSync(changed, olds)
 }
 
-// GetVal is used to parse value of note, returning the value.
-func GetVal(note string) string {
-	i := strings.Index(note, ":")
-	if i == -1 {
-		return ""
+// Apply is used to apply changes pulled from backup in order.
+func (l local) Apply(changes []string) error {
+	unlock, err := l.b.lock()
+	if err != nil {
+		return err
 	}
+	defer unlock()
 
-	return note[i+1:]
+	// changes may carry values of secret notes, so errors tell which change rather than what it is.
+	for i, data := range changes {
+		var c change
+		err = json.Unmarshal([]byte(data), &c)
+		if err != nil {
+			return fmt.Errorf("json unmarshal of change %d of %d bytes error: %v", i+1, len(data), err)
+		}
+		switch {
+		case c.Op == changeOpPut && c.Note != nil:
+			var olds map[string]*Note
+			olds, err = l.b.currents([]Note{*c.Note})
+			if err == nil {
+				err = l.b.store.Put(*c.Note)
+			}
+			if err == nil {
+				err = l.b.recordSync([]Note{*c.Note}, olds)
+			}
+		case c.Op == changeOpDelete:
+			err = l.b.store.Delete(c.Key)
+		default:
+			err = fmt.Errorf("invalid change %d of %d bytes", i+1, len(data))
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// recordSync is used to record notes pulled from backup in history,
+// which only logs the error since notes are changed anyway.
+func (b *Notebook) recordSync(notes []Note, olds map[string]*Note) error {
+	if len(notes) == 0 {
+		return nil
+	}
+	err := b.record(notes, olds, SourceSync)
+	if err != nil {
+		logs.Warn("note: record history of %d notes pulled error: %s", len(notes), err.Error())
+	}
+	return nil
+}
+
+// Cursor is used to get the position of the latest change if notes are kept in journal.
+func (l local) Cursor() (string, error) {
+	j, ok := l.b.store.Store.(*journalStore)
+	if !ok {
+		return "", nil
+	}
+	return j.cursor()
+}
+
+// Changes is used to get json of changes after the cursor if notes are kept in journal.
+func (l local) Changes(cursor string) ([]string, string, bool, error) {
+	j, ok := l.b.store.Store.(*journalStore)
+	if !ok {
+		return nil, "", false, nil
+	}
+	changes, next, ok, err := j.changesSince(cursor)
+	if err != nil || !ok {
+		return nil, next, ok, err
+	}
+
+	results := make([]string, 0, len(changes))
+	for _, c := range changes {
+		data, err := json.Marshal(c)
+		if err != nil {
+			return nil, "", false, fmt.Errorf("json marshal of %v error: %v", c, err)
+		}
+		results = append(results, string(data))
+	}
+	return results, next, true, nil
 }
@@ -0,0 +1,29 @@
+package note
+
+import (
+	"find/internal/config"
+	"path/filepath"
+	"testing"
+)
+
+// testNotebook is used to make a notebook keeping notes in a new text file, which is checked
+// and closed when the test ends.
+func testNotebook(t *testing.T) *Notebook {
+	b := &Notebook{conf: config.Notebook{Name: "test", NotePath: filepath.Join(t.TempDir(), "FIND.txt")}}
+	if err := b.Check(); err != nil {
+		t.Fatalf("check error: %v", err)
+	}
+	t.Cleanup(func() {
+		_ = b.store.Close()
+	})
+	return b
+}
+
+// mustNote is used to make a note of key and val, failing the test on error.
+func mustNote(t *testing.T, key, val string) Note {
+	n, err := New(key, val)
+	if err != nil {
+		t.Fatalf("new note error: %v", err)
+	}
+	return n
+}
@@ -0,0 +1,151 @@
+package note
+
+import (
+	"find/internal/config"
+	"find/internal/logs"
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/gofrs/flock"
+)
+
+// Notebook is a named set of notes, kept in its own store along with its own history, trash and backup.
+type Notebook struct {
+	conf config.Notebook
+	// store is the index of where notes are kept, which is opened by Check.
+	store *index
+	// isNew reports whether the store is newly created when it's opened.
+	isNew bool
+	// checkMutex serializes checks, which are also run in background after notes changed.
+	checkMutex sync.Mutex
+	// fileLock is an advisory lock across processes, kept in a file beside the store,
+	// which is taken around every read-modify-write of notes.
+	fileLock *flock.Flock
+	// lockMutex serializes read-modify-write of notes in this process,
+	// since fileLock is already held by this process when another goroutine asks for it.
+	lockMutex sync.Mutex
+	// history is the revisions of notes, which is opened on first use.
+	history *history
+	// sealer encrypts files of notebook, which is nil if it isn't encrypted or not unlocked yet.
+	sealer *sealer
+}
+
+// notebooks are all notebooks configured, the default one first.
+var notebooks []*Notebook
+
+// active is the notebook which orders work on, switched by Use.
+var active *Notebook
+
+// activeMutex guards active, which is read by reminder in background.
+var activeMutex sync.RWMutex
+
+// background reports whether notebooks may work in background, like watching files changed by others,
+// synchronizing the backup and compacting journals, which is disabled for a process running a single order.
+var background = true
+
+// SetBackground is used to enable or disable work of notebooks in background, which must be called before Check.
+func SetBackground(enabled bool) {
+	background = enabled
+}
+
+func init() {
+	for _, conf := range config.Notebooks() {
+		notebooks = append(notebooks, &Notebook{conf: conf})
+	}
+	active = notebooks[0]
+}
+
+// Name is used to get the name of notebook.
+func (b *Notebook) Name() string {
+	return b.conf.Name
+}
+
+// Reminds is used to check if the reminder is enabled for notebook, returning the check result
+// and the ways to remind.
+func (b *Notebook) Reminds() (bool, string) {
+	return b.conf.Reminder.Enabled != nil && *b.conf.Reminder.Enabled, b.conf.Reminder.Type
+}
+
+// Notebooks is used to get all notebooks, the default one first.
+func Notebooks() []*Notebook {
+	return notebooks
+}
+
+// Active is used to get the notebook which orders work on.
+func Active() *Notebook {
+	activeMutex.RLock()
+	defer activeMutex.RUnlock()
+	return active
+}
+
+// Use is used to switch the active notebook to the one of specified name,
+// returning the notebook and error if there is no such notebook.
+func Use(name string) (*Notebook, error) {
+	for _, b := range notebooks {
+		if b.conf.Name != name {
+			continue
+		}
+		activeMutex.Lock()
+		active = b
+		activeMutex.Unlock()
+		return b, nil
+	}
+	return nil, fmt.Errorf("notebook %s not found", name)
+}
+
+// Check is used to ensure that stores of all notebooks are available,
+// and then synchronize each of them if its redis config is available too.
+// Errors of notebooks don't stop checking others, and the first one is returned.
+func Check() error {
+	var first error
+	for _, b := range notebooks {
+		err := b.Check()
+		if err != nil && first == nil {
+			first = fmt.Errorf("check notebook %s error: %v", b.conf.Name, err)
+		}
+	}
+	return first
+}
+
+// Found is a note found in a notebook.
+type Found struct {
+	Match
+	Notebook string
+}
+
+// FindAll is used to lookup notes like Search in every notebook except locked ones,
+// returning notes found along with their notebooks, ranked like Search, and error.
+func FindAll(keyword string, scope Scope, mode Mode) ([]Found, error) {
+	results := make([]Found, 0)
+	for _, b := range notebooks {
+		if b.Locked() {
+			logs.Warn("note: skip locked notebook %s in find", b.conf.Name)
+			continue
+		}
+		matches, err := b.Search(keyword, scope, mode)
+		if err != nil {
+			return nil, fmt.Errorf("find in notebook %s error: %v", b.conf.Name, err)
+		}
+		for _, m := range matches {
+			results = append(results, Found{Match: m, Notebook: b.conf.Name})
+		}
+	}
+	sort.SliceStable(results, func(i, j int) bool {
+		return ranksBefore(results[i].Match, results[j].Match)
+	})
+	return results, nil
+}
+
+// PrintFound is used to show notes found in notebooks to the user like PrintMatches,
+// along with the notebook of each note.
+func PrintFound(found []Found, long bool, fields bool) {
+	if len(found) == 0 {
+		fmt.Println("Empty result.")
+		return
+	}
+	for _, f := range found {
+		fmt.Printf("[%s] ", f.Notebook)
+		printMatch(f.Match, long, fields)
+	}
+}
@@ -0,0 +1,42 @@
+package note
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestUseAndFindAll(t *testing.T) {
+	def, work := testNotebook(t), testNotebook(t)
+	def.conf.Name, work.conf.Name = "default", "work"
+	oldNotebooks, oldActive := notebooks, active
+	notebooks, active = []*Notebook{def, work}, def
+	t.Cleanup(func() { notebooks, active = oldNotebooks, oldActive })
+
+	if err := def.Write([]Note{mustNote(t, "mysql", "1")}); err != nil {
+		t.Fatalf("write error: %v", err)
+	}
+	if err := work.Write([]Note{mustNote(t, "mysql", "2"), mustNote(t, "redis", "3")}); err != nil {
+		t.Fatalf("write error: %v", err)
+	}
+
+	b, err := Use("work")
+	if err != nil || b != work || Active() != work {
+		t.Fatalf("use work got %v and error %v, want the notebook work active", b, err)
+	}
+	if _, err = Use("missing"); err == nil || Active() != work {
+		t.Errorf("use of a missing notebook got error %v, want error with work kept active", err)
+	}
+
+	found, err := FindAll("mysql", ScopeKey, ModeSubstring)
+	if err != nil {
+		t.Fatalf("find all error: %v", err)
+	}
+	got := make([]string, 0, len(found))
+	for _, f := range found {
+		got = append(got, f.Notebook+":"+f.Val)
+	}
+	// Notes of the same rank are sorted by the time updated, the latest first.
+	if want := []string{"work:2", "default:1"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("find all got %q, want %q", got, want)
+	}
+}
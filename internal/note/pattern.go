@@ -0,0 +1,183 @@
+package note
+
+import (
+	"find/internal/fuzzy"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// Mode is how text of query is matched against notes.
+type Mode int
+
+const (
+	// ModeSubstring matches text which contains the keyword ignoring the case.
+	ModeSubstring Mode = iota
+	// ModeRegex matches text by a regular expression ignoring the case.
+	ModeRegex
+	// ModeFuzzy matches text approximately, tolerating typos and scattered characters.
+	ModeFuzzy
+)
+
+// hit is where a pattern matched text, along with the score of match from 0 to 1.
+type hit struct {
+	// positions are indexes of matched runes of text in order.
+	positions []int
+	score     float64
+}
+
+// pattern is used to match text, which is a key if isKey is true, returning where it matched and if it matched.
+// Text and the keyword are compared in normalized forms, see normalize.
+type pattern func(text string, isKey bool) (hit, bool)
+
+// newPattern is used to make a pattern of specified mode from keyword, returning the pattern and error.
+// Besides ModeRegex, Chinese characters of keys can be matched by pinyin, like 'bjcp' for '北京昌平'.
+func newPattern(keyword string, mode Mode) (pattern, error) {
+	if mode == ModeRegex {
+		// The regex isn't turned into lower case, since it may have classes like '\D'.
+		re, err := regexp.Compile("(?i)" + norm.NFKC.String(keyword))
+		if err != nil {
+			return nil, fmt.Errorf("invalid regex %s: %v", keyword, err)
+		}
+		return func(text string, isKey bool) (hit, bool) {
+			n := normalize(text)
+			s := string(n.runes)
+			loc := re.FindStringIndex(s)
+			if loc == nil {
+				return hit{}, false
+			}
+			start := utf8.RuneCountInString(s[:loc[0]])
+			return hit{positions: n.origins(runeSpan(start, utf8.RuneCountInString(s[loc[0]:loc[1]]))), score: 1}, true
+		}, nil
+	}
+
+	keyword = normalizeKeyword(keyword)
+	length := utf8.RuneCountInString(keyword)
+	pinyinable := isPinyin(keyword)
+	return func(text string, isKey bool) (hit, bool) {
+		n := normalize(text)
+		s := string(n.runes)
+		if i := strings.Index(s, keyword); i != -1 {
+			start := utf8.RuneCountInString(s[:i])
+			return hit{positions: n.origins(runeSpan(start, length)), score: 1}, true
+		}
+		if isKey && pinyinable && hasHan(n.runes) {
+			if positions, ok := matchPinyin(n.runes, keyword, false); ok {
+				return hit{positions: n.origins(positions), score: 1}, true
+			}
+		}
+		if mode != ModeFuzzy {
+			return hit{}, false
+		}
+		positions, score, ok := fuzzy.Match(s, keyword)
+		return hit{positions: n.origins(positions), score: score}, ok
+	}, nil
+}
+
+// normalized is text in NFKC and lower case, so that full-width and half-width characters are the same,
+// along with original runes of each rune.
+type normalized struct {
+	runes []rune
+	// indexes are indexes of the first original rune of each rune, and widths are how many original runes it's from,
+	// which is more than one if characters are composed, like 'e' followed by a combining acute accent.
+	indexes []int
+	widths  []int
+}
+
+// normalize is used to normalize text like normalizeKeyword, segment by segment so that each rune is mapped to
+// the original runes it's from, returning the normalized text.
+func normalize(text string) normalized {
+	n := normalized{}
+	var it norm.Iter
+	it.InitString(norm.NFKC, text)
+	index := 0
+	for !it.Done() {
+		start := it.Pos()
+		segment := strings.ToLower(string(it.Next()))
+		width := utf8.RuneCountInString(text[start:it.Pos()])
+		for _, c := range segment {
+			n.runes = append(n.runes, c)
+			n.indexes = append(n.indexes, index)
+			n.widths = append(n.widths, width)
+		}
+		index += width
+	}
+	return n
+}
+
+// origins is used to map indexes of normalized runes to the original ones, returning sorted indexes.
+func (n normalized) origins(positions []int) []int {
+	results := make([]int, 0, len(positions))
+	for _, p := range positions {
+		for o := n.indexes[p]; o < n.indexes[p]+n.widths[p]; o++ {
+			if len(results) == 0 || results[len(results)-1] < o {
+				results = append(results, o)
+			}
+		}
+	}
+	return results
+}
+
+// normalizeKeyword is used to get text in NFKC and lower case.
+func normalizeKeyword(text string) string {
+	return strings.ToLower(norm.NFKC.String(text))
+}
+
+// runeSpan is used to get length indexes of runes starting from start.
+func runeSpan(start, length int) []int {
+	positions := make([]int, length)
+	for i := range positions {
+		positions[i] = start + i
+	}
+	return positions
+}
+
+// highlightStart and highlightEnd wrap matched characters shown in the terminal.
+const (
+	highlightStart = "\033[1;33m"
+	highlightEnd   = "\033[0m"
+)
+
+// colorful reports whether matched characters are highlighted, which is only for a terminal
+// and can be turned off by the environment variable NO_COLOR.
+var colorful = isTerminal(os.Stdout) && os.Getenv("NO_COLOR") == ""
+
+// isTerminal is used to check if the file is a terminal.
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// highlight is used to wrap runes of text at specified sorted indexes for the terminal,
+// returning text as it is if it's not colorful.
+func highlight(text string, positions []int) string {
+	if !colorful || len(positions) == 0 {
+		return text
+	}
+	var builder strings.Builder
+	lit := false
+	p := 0
+	for i, r := range []rune(text) {
+		for p < len(positions) && positions[p] < i {
+			p++
+		}
+		on := p < len(positions) && positions[p] == i
+		if on != lit {
+			if on {
+				builder.WriteString(highlightStart)
+			} else {
+				builder.WriteString(highlightEnd)
+			}
+			lit = on
+		}
+		builder.WriteRune(r)
+	}
+	if lit {
+		builder.WriteString(highlightEnd)
+	}
+	return builder.String()
+}
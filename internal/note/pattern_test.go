@@ -0,0 +1,57 @@
+package note
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestNormalize(t *testing.T) {
+	tests := []struct {
+		name    string
+		text    string
+		want    string
+		origins []int
+	}{
+		{"full width", "ＭｙＳＱＬ", "mysql", []int{0, 1, 2, 3, 4}},
+		{"combining accent", "cafe\u0301!", "caf\u00e9!", []int{0, 1, 2, 3, 5}},
+		{"half-width voiced katakana", "ｶﾞｽ", "ガス", []int{0, 2}},
+		{"expanded ligature", "ﬁx", "fix", []int{0, 0, 1}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			n := normalize(tt.text)
+			if got := string(n.runes); got != tt.want || got != normalizeKeyword(tt.text) {
+				t.Errorf("normalize got %q, want %q like the keyword", got, tt.want)
+			}
+			if got := n.indexes; !reflect.DeepEqual(got, tt.origins) {
+				t.Errorf("original indexes got %v, want %v", got, tt.origins)
+			}
+		})
+	}
+}
+
+func TestSearchComposed(t *testing.T) {
+	b := testNotebook(t)
+	if err := b.Write([]Note{mustNote(t, "cafe\u0301 menu", "1"), mustNote(t, "ｶﾞｽ", "2")}); err != nil {
+		t.Fatalf("write error: %v", err)
+	}
+	tests := []struct {
+		keyword string
+		key     string
+		hits    []int
+	}{
+		{"caf\u00e9", "cafe\u0301 menu", []int{0, 1, 2, 3, 4}},
+		{"e\u0301", "cafe\u0301 menu", []int{3, 4}},
+		{"ガ", "ｶﾞｽ", []int{0, 1}},
+		{"ス", "ｶﾞｽ", []int{2}},
+	}
+	for _, tt := range tests {
+		matches, err := b.Search(tt.keyword, ScopeKey, ModeSubstring)
+		if err != nil {
+			t.Fatalf("search %s error: %v", tt.keyword, err)
+		}
+		if len(matches) != 1 || matches[0].Key != tt.key || !reflect.DeepEqual(matches[0].keyHits, tt.hits) {
+			t.Errorf("search %q got %+v, want %q with hits %v", tt.keyword, matches, tt.key, tt.hits)
+		}
+	}
+}
@@ -0,0 +1,140 @@
+package note
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+
+	"github.com/mozillazg/go-pinyin"
+)
+
+// pinyinArgs gets all readings of a Chinese character without tones, like 'chang' and 'zhang' for '长'.
+var pinyinArgs = func() pinyin.Args {
+	a := pinyin.NewArgs()
+	a.Heteronym = true
+	return a
+}()
+
+// readings is used to get pinyin of a Chinese character, returning nil for other characters.
+func readings(r rune) []string {
+	if !unicode.Is(unicode.Han, r) {
+		return nil
+	}
+	return pinyin.SinglePinyin(r, pinyinArgs)
+}
+
+// isPinyin is used to check if keyword may be pinyin, which is lower-case latin letters only.
+func isPinyin(keyword string) bool {
+	if keyword == "" {
+		return false
+	}
+	for _, r := range keyword {
+		if r < 'a' || r > 'z' {
+			return false
+		}
+	}
+	return true
+}
+
+// hasHan is used to check if text has any Chinese character.
+func hasHan(text []rune) bool {
+	for _, r := range text {
+		if unicode.Is(unicode.Han, r) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchPinyin is used to find lower-case keyword in text by pinyin of Chinese characters,
+// where each character is typed as its full pinyin or the beginning of it, like 'bjcp' or 'beijingchangping'
+// for '北京昌平', and other characters are typed as they are or skipped if they aren't letters or digits.
+// If whole is true, keyword must cover all of text. It returns indexes of matched runes and if it matched.
+func matchPinyin(text []rune, keyword string, whole bool) ([]int, bool) {
+	last := len(text) - 1
+	if whole {
+		last = 0
+	}
+	for start := 0; start <= last; start++ {
+		if positions, ok := consumePinyin(text, start, keyword, nil, whole); ok && len(positions) > 0 {
+			return positions, true
+		}
+	}
+	return nil, false
+}
+
+// consumePinyin is used to match the rest of keyword from text[i:], returning matched indexes and if it matched.
+func consumePinyin(text []rune, i int, rest string, positions []int, whole bool) ([]int, bool) {
+	if rest == "" {
+		if whole && i < len(text) {
+			return nil, false
+		}
+		return positions, true
+	}
+	if i >= len(text) {
+		return nil, false
+	}
+	r := text[i]
+	choices := readings(r)
+	if choices == nil {
+		if r == rune(rest[0]) {
+			return consumePinyin(text, i+1, rest[1:], append(append([]int(nil), positions...), i), whole)
+		}
+		if len(positions) > 0 && !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			return consumePinyin(text, i+1, rest, positions, whole)
+		}
+		return nil, false
+	}
+	for _, reading := range choices {
+		for l := len(reading); l > 0; l-- {
+			if l > len(rest) || reading[:l] != rest[:l] {
+				continue
+			}
+			matched := append(append([]int(nil), positions...), i)
+			if result, ok := consumePinyin(text, i+1, rest[l:], matched, whole); ok {
+				return result, true
+			}
+		}
+	}
+	return nil, false
+}
+
+// Resolve is used to get the key of note which user means by key, which is key itself if there is a note of it,
+// or the only key having Chinese characters which key is the whole pinyin of, like 'bjcp' for '北京昌平',
+// returning the key and error if more than one key is meant.
+func (b *Notebook) Resolve(key string) (string, error) {
+	if err := b.available(); err != nil {
+		return "", err
+	}
+	old, err := b.store.Get(key)
+	if err != nil {
+		return "", fmt.Errorf("get %s error: %v", key, err)
+	}
+	keyword := normalizeKeyword(key)
+	if old != nil || !isPinyin(keyword) {
+		return key, nil
+	}
+	notes, err := b.store.Query(func(n Note) bool {
+		text := normalize(n.Key).runes
+		if !hasHan(text) {
+			return false
+		}
+		_, ok := matchPinyin(text, keyword, true)
+		return ok
+	})
+	if err != nil {
+		return "", err
+	}
+	switch len(notes) {
+	case 0:
+		return key, nil
+	case 1:
+		return notes[0].Key, nil
+	default:
+		keys := make([]string, 0, len(notes))
+		for _, n := range notes {
+			keys = append(keys, n.Key)
+		}
+		return "", fmt.Errorf("%s may be any of %s, please type more", key, strings.Join(keys, ", "))
+	}
+}
@@ -0,0 +1,94 @@
+package note
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestMatchPinyin(t *testing.T) {
+	tests := []struct {
+		text      string
+		keyword   string
+		whole     bool
+		positions []int
+		ok        bool
+	}{
+		{"北京昌平", "bjcp", false, []int{0, 1, 2, 3}, true},
+		{"北京昌平", "beijingchangping", true, []int{0, 1, 2, 3}, true},
+		{"北京昌平", "changp", false, []int{2, 3}, true},
+		{"北京昌平", "cp", true, nil, false},
+		{"北京昌平", "bjc", true, nil, false},
+		{"长城", "zc", false, []int{0, 1}, true},
+		{"长城", "cc", false, []int{0, 1}, true},
+		{"北京-2号线", "bj2hx", true, []int{0, 1, 3, 4, 5}, true},
+		{"mysql北京", "sqlbj", false, []int{2, 3, 4, 5, 6}, true},
+		{"北京", "xa", false, nil, false},
+	}
+	for _, tt := range tests {
+		positions, ok := matchPinyin([]rune(tt.text), tt.keyword, tt.whole)
+		if ok != tt.ok || !reflect.DeepEqual(positions, tt.positions) {
+			t.Errorf("match %q in %q got %v %v, want %v %v", tt.keyword, tt.text, positions, ok, tt.positions, tt.ok)
+		}
+	}
+}
+
+func TestSearchPinyinAndFullWidth(t *testing.T) {
+	b := testNotebook(t)
+	err := b.Write([]Note{mustNote(t, "北京昌平", "回龙观"), mustNote(t, "ＭｙＳＱＬ", "３３０６"),
+		mustNote(t, "背景", "bj")})
+	if err != nil {
+		t.Fatalf("write error: %v", err)
+	}
+	tests := []struct {
+		keyword string
+		scope   Scope
+		want    []string
+	}{
+		{"bjcp", ScopeKey, []string{"北京昌平"}},
+		{"bj", ScopeKey, []string{"背景", "北京昌平"}},
+		{"hlg", ScopeVal, []string{}},
+		{"mysql", ScopeKey, []string{"ＭｙＳＱＬ"}},
+		{"ｍｙ", ScopeKey, []string{"ＭｙＳＱＬ"}},
+		{"3306", ScopeVal, []string{"ＭｙＳＱＬ"}},
+	}
+	for _, tt := range tests {
+		matches, err := b.Search(tt.keyword, tt.scope, ModeSubstring)
+		if err != nil {
+			t.Fatalf("search %s error: %v", tt.keyword, err)
+		}
+		got := make([]string, 0, len(matches))
+		for _, m := range matches {
+			got = append(got, m.Key)
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("search %q got %q, want %q", tt.keyword, got, tt.want)
+		}
+	}
+}
+
+func TestResolve(t *testing.T) {
+	b := testNotebook(t)
+	err := b.Write([]Note{mustNote(t, "北京昌平", "1"), mustNote(t, "北京", "2"), mustNote(t, "背景", "3"),
+		mustNote(t, "bj", "4")})
+	if err != nil {
+		t.Fatalf("write error: %v", err)
+	}
+	tests := []struct {
+		key  string
+		want string
+	}{
+		{"bjcp", "北京昌平"},
+		{"BeiJingChangPing", "北京昌平"},
+		{"bj", "bj"},
+		{"bjc", "bjc"},
+		{"new key", "new key"},
+	}
+	for _, tt := range tests {
+		if got, err := b.Resolve(tt.key); err != nil || got != tt.want {
+			t.Errorf("resolve %q got %q and error %v, want %q", tt.key, got, err, tt.want)
+		}
+	}
+	if got, err := b.Resolve("beijing"); err == nil {
+		t.Errorf("resolve of a key meaning two got %q, want error", got)
+	}
+}
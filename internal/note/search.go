@@ -0,0 +1,370 @@
+package note
+
+import (
+	"find/internal/query"
+	"find/internal/suggest"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// NeedRemind marks the time of a reminder in the value of a to-do note like 'remind@18:00',
+// which is replaced by Reminded after the reminder is sent.
+const NeedRemind = "remind@"
+
+// Reminded marks the time of a reminder which has been sent.
+const Reminded = "reminded@"
+
+// Scope is the fields of notes which keywords are searched in.
+type Scope int
+
+const (
+	// ScopeKey searches keywords in keys only.
+	ScopeKey Scope = iota
+	// ScopeVal searches keywords in values only.
+	ScopeVal
+	// ScopeEverywhere searches each keyword in either the key or the value.
+	ScopeEverywhere
+)
+
+// Match is a note found by Search, along with where keywords were found in it.
+type Match struct {
+	Note
+	// InKey and InVal report whether any keyword was found in the key or the value.
+	InKey bool
+	InVal bool
+	// Line and Column are where the first keyword falls in the value, counted from 1,
+	// which are 0 if no keyword was found in the value.
+	Line   int
+	Column int
+	// Score is how well keywords matched from 0 to 1, which is below 1 for fuzzy matches.
+	Score float64
+	// tier ranks the match by the key, which is 0 if a keyword is the whole key,
+	// 1 if the key starts with a keyword, and 2 otherwise.
+	tier int
+	// keyHits and valHits are indexes of matched runes of the key and the value.
+	keyHits []int
+	valHits []int
+}
+
+// Tiers of matches by the key, see Match.
+const (
+	tierExact = iota
+	tierPrefix
+	tierOther
+)
+
+// searcher checks notes against the query from user's input.
+type searcher struct {
+	node  query.Node
+	scope Scope
+	mode  Mode
+	// patterns are made from text of terms of query.
+	patterns map[string]pattern
+}
+
+// newSearcher is used to parse keyword into a query, where text without a field is searched in scope
+// and matched in mode, returning the searcher and error.
+// In ModeRegex, keyword is a single regex, which may be quoted in double quotes.
+func newSearcher(keyword string, scope Scope, mode Mode) (*searcher, error) {
+	s := &searcher{scope: scope, mode: mode, patterns: make(map[string]pattern)}
+	if mode == ModeRegex {
+		if len(keyword) > 1 && strings.HasPrefix(keyword, `"`) && strings.HasSuffix(keyword, `"`) {
+			keyword = keyword[1 : len(keyword)-1]
+		}
+		if keyword != "" {
+			s.node = query.Term{Field: query.FieldText, Value: keyword}
+		}
+	} else {
+		node, err := query.Parse(keyword)
+		if err != nil {
+			return nil, err
+		}
+		s.node = node
+	}
+	for _, t := range query.Terms(s.node) {
+		if t.Field != query.FieldText && t.Field != query.FieldKey && t.Field != query.FieldVal {
+			continue
+		}
+		if _, ok := s.patterns[t.Value]; ok {
+			continue
+		}
+		p, err := newPattern(t.Value, mode)
+		if err != nil {
+			return nil, err
+		}
+		s.patterns[t.Value] = p
+	}
+	return s, nil
+}
+
+// isSubtree is used to check if text of query means a subtree, which isn't in ModeRegex.
+func (s *searcher) isSubtree(text string) bool {
+	return s.mode != ModeRegex && isSubtree(text)
+}
+
+// inKey and inVal report whether the key or the value of note should be searched for the term.
+func (s *searcher) inKey(t query.Term) bool {
+	return t.Field == query.FieldKey || t.Field == query.FieldText && s.scope != ScopeVal
+}
+
+func (s *searcher) inVal(t query.Term) bool {
+	return t.Field == query.FieldVal || t.Field == query.FieldText && s.scope != ScopeKey
+}
+
+// matcher tells whether a note meets terms of query.
+type matcher struct {
+	n Note
+	s *searcher
+}
+
+func (m matcher) Contains(field, text string) bool {
+	t := query.Term{Field: field, Value: text}
+	if m.s.inKey(t) {
+		if m.s.isSubtree(text) {
+			if inSubtree(m.n.Key, segments(strings.TrimSuffix(text, subtreeSuffix))) {
+				return true
+			}
+		} else if _, ok := m.s.patterns[text](m.n.Key, true); ok {
+			return true
+		}
+	}
+	if m.s.inVal(t) {
+		_, ok := m.s.patterns[text](m.n.Val, false)
+		return ok
+	}
+	return false
+}
+
+func (m matcher) HasTag(tag string) bool {
+	tags := normalizeTags([]string{tag})
+	return len(tags) == 1 && containsTag(m.n.Tags, tags[0])
+}
+
+func (m matcher) Has(thing string) bool {
+	switch thing {
+	case query.HasRemind:
+		return strings.Contains(m.n.Val, NeedRemind)
+	case query.HasReminded:
+		return strings.Contains(m.n.Val, Reminded)
+	case query.HasTags:
+		return len(m.n.Tags) > 0
+	}
+	return false
+}
+
+func (m matcher) Time(field string) time.Time {
+	if field == query.FieldUpdated {
+		return m.n.Updated
+	}
+	return m.n.Created
+}
+
+// search is used to lookup notes matching the query from user's input, where text without a field is
+// searched in scope and matched in mode, returning notes found in order of insertion, the searcher, and error.
+// In ModeSubstring, candidates are narrowed by the index with the text and tags which every note found must have.
+func (b *Notebook) search(keyword string, scope Scope, mode Mode) ([]Note, *searcher, error) {
+	s, err := newSearcher(keyword, scope, mode)
+	if err != nil {
+		return nil, nil, err
+	}
+	keywords := make([]string, 0)
+	tags := make([]string, 0)
+	for _, t := range query.Required(s.node) {
+		switch {
+		case t.Field == query.FieldText && mode == ModeSubstring && !isSubtree(t.Value):
+			keywords = append(keywords, t.Value)
+		case t.Field == query.FieldTag:
+			tags = append(tags, t.Value)
+		}
+	}
+	notes := b.store.Search(keywords, normalizeTags(tags), scope)
+
+	results := make([]Note, 0, len(notes))
+	for _, n := range notes {
+		if query.Match(s.node, matcher{n: n, s: s}) {
+			results = append(results, n)
+		}
+	}
+	return results, s, nil
+}
+
+// Search is used to lookup notes matching the query from user's input, where text without a field is
+// searched in scope and matched in mode, returning notes found along with where the text was found,
+// and error. Notes are ranked by the key matching the text exactly, then by the key starting with it,
+// then by the score of match, and then the latest updated first.
+// See query.Parse for the syntax of query.
+func (b *Notebook) Search(keyword string, scope Scope, mode Mode) ([]Match, error) {
+	if err := b.available(); err != nil {
+		return nil, err
+	}
+	notes, s, err := b.search(keyword, scope, mode)
+	if err != nil {
+		return nil, err
+	}
+	results := make([]Match, 0, len(notes))
+	for _, n := range notes {
+		results = append(results, s.locate(n))
+	}
+	sort.SliceStable(results, func(i, j int) bool {
+		return ranksBefore(results[i], results[j])
+	})
+	return results, nil
+}
+
+// ranksBefore is used to judge if match a should be shown before match b.
+func ranksBefore(a, b Match) bool {
+	if a.tier != b.tier {
+		return a.tier < b.tier
+	}
+	if a.Score != b.Score {
+		return a.Score > b.Score
+	}
+	return a.Updated.After(b.Updated)
+}
+
+// locate is used to find where the text of query which isn't negated is in the note, returning the match.
+func (s *searcher) locate(n Note) Match {
+	m := Match{Note: n, tier: tierOther}
+	keyLength := utf8.RuneCountInString(n.Key)
+	first := -1
+	for _, t := range query.Positive(s.node) {
+		p, ok := s.patterns[t.Value]
+		if !ok || s.isSubtree(t.Value) {
+			continue
+		}
+		if s.inKey(t) {
+			if h, ok := p(n.Key, true); ok {
+				m.InKey = true
+				m.keyHits = append(m.keyHits, h.positions...)
+				m.Score = math.Max(m.Score, h.score)
+				switch {
+				case h.score == 1 && len(h.positions) == keyLength:
+					m.tier = tierExact
+				case len(h.positions) > 0 && h.positions[0] == 0 && m.tier > tierPrefix:
+					m.tier = tierPrefix
+				}
+			}
+		}
+		if s.inVal(t) {
+			if h, ok := p(n.Val, false); ok {
+				m.InVal = true
+				m.valHits = append(m.valHits, h.positions...)
+				m.Score = math.Max(m.Score, h.score)
+				if len(h.positions) > 0 && (first == -1 || h.positions[0] < first) {
+					first = h.positions[0]
+				}
+			}
+		}
+	}
+	sort.Ints(m.keyHits)
+	sort.Ints(m.valHits)
+	if first != -1 {
+		before := string([]rune(n.Val)[:first])
+		m.Line = strings.Count(before, "\n") + 1
+		m.Column = utf8.RuneCountInString(before[strings.LastIndex(before, "\n")+1:]) + 1
+	}
+	return m
+}
+
+// PrintMatches is used to show notes found by Search to the user like Print, with matched characters
+// highlighted in the terminal, along with which fields matched and where the match falls in the value
+// if fields is true.
+func PrintMatches(matches []Match, long bool, fields bool) {
+	if len(matches) == 0 {
+		fmt.Println("Empty result.")
+		return
+	}
+	for _, m := range matches {
+		printMatch(m, long, fields)
+	}
+}
+
+// printMatch is used to show a note found by Search to the user.
+func printMatch(m Match, long bool, fields bool) {
+	n := m.Note
+	n.Key = highlight(m.Key, m.keyHits)
+	val := secretMask
+	if !n.Secret() {
+		val = highlight(m.Val, m.valHits)
+	}
+	fmt.Printf("%s: %s\n", title(n), val)
+	if long {
+		printMeta(m.Note)
+	}
+	if !fields {
+		return
+	}
+	switch {
+	case m.InKey && m.InVal:
+		fmt.Printf("    matched key and value at line %d, column %d\n", m.Line, m.Column)
+	case m.InVal:
+		fmt.Printf("    matched value at line %d, column %d\n", m.Line, m.Column)
+	case m.InKey:
+		fmt.Println("    matched key")
+	}
+}
+
+// suggestions is how many keys are suggested at most when nothing is found.
+const suggestions = 3
+
+// Suggest is used to find keys which user may mean by keyword when nothing is found by it,
+// comparing the text of query which isn't negated with all keys, see suggest.Closest,
+// returning keys from the closest one, and error.
+func (b *Notebook) Suggest(keyword string) ([]string, error) {
+	if err := b.available(); err != nil {
+		return nil, err
+	}
+	texts := []string{keyword}
+	if node, err := query.Parse(keyword); err == nil {
+		texts = texts[:0]
+		for _, t := range query.Positive(node) {
+			if (t.Field == query.FieldText || t.Field == query.FieldKey) && !isSubtree(t.Value) {
+				texts = append(texts, t.Value)
+			}
+		}
+	}
+	if len(texts) == 0 {
+		return nil, nil
+	}
+	notes, err := b.store.Query(func(Note) bool { return true })
+	if err != nil {
+		return nil, err
+	}
+	keys := make([]string, 0, len(notes))
+	for _, n := range notes {
+		keys = append(keys, n.Key)
+	}
+	return suggest.Closest(strings.Join(texts, " "), keys, suggestions), nil
+}
+
+// PrintSuggestions is used to show keys which user may mean, showing nothing if there is none.
+func PrintSuggestions(keys []string) {
+	if len(keys) == 0 {
+		return
+	}
+	fmt.Printf("Did you mean: %s?\n", strings.Join(keys, ", "))
+}
+
+// Keys is used to get keys starting with prefix regardless of the case, returning them sorted, and error.
+func (b *Notebook) Keys(prefix string) ([]string, error) {
+	if err := b.available(); err != nil {
+		return nil, err
+	}
+	prefix = strings.ToLower(prefix)
+	var keys []string
+	err := b.store.Iterate(func(n Note) bool {
+		if strings.HasPrefix(strings.ToLower(n.Key), prefix) {
+			keys = append(keys, n.Key)
+		}
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(keys)
+	return keys, nil
+}
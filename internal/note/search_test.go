@@ -0,0 +1,205 @@
+package note
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+)
+
+// searchNotebook is used to make a notebook with notes for search tests, each updated later than the one before.
+func searchNotebook(t *testing.T) *Notebook {
+	b := testNotebook(t)
+	notes := []Note{
+		mustNote(t, "mysql", "host: db.local\nuser: root"),
+		mustNote(t, "redis", "host: cache.local"),
+		mustNote(t, "北京", "昌平区 回龙观\n海淀区 ROOT"),
+	}
+	for i := range notes {
+		notes[i].Updated = time.Unix(1700000000+int64(i), 0)
+	}
+	if err := b.Write(notes); err != nil {
+		t.Fatalf("write error: %v", err)
+	}
+	return b
+}
+
+// describeMatches is used to describe matches like 'mysql key val 2:7'.
+func describeMatches(matches []Match) []string {
+	got := make([]string, 0, len(matches))
+	for _, m := range matches {
+		s := m.Key
+		if m.InKey {
+			s += " key"
+		}
+		if m.InVal {
+			s += fmt.Sprintf(" val %d:%d", m.Line, m.Column)
+		}
+		got = append(got, s)
+	}
+	return got
+}
+
+func TestSearch(t *testing.T) {
+	b := searchNotebook(t)
+	tests := []struct {
+		keyword string
+		scope   Scope
+		want    []string
+	}{
+		{"sql", ScopeKey, []string{"mysql key"}},
+		{"local", ScopeKey, []string{}},
+		{"local", ScopeVal, []string{"redis val 1:13", "mysql val 1:10"}},
+		{"ROOT host", ScopeVal, []string{"mysql val 1:1"}},
+		{"root", ScopeVal, []string{"北京 val 2:5", "mysql val 2:7"}},
+		{"回龙观", ScopeVal, []string{"北京 val 1:5"}},
+		{"redis cache", ScopeVal, []string{}},
+		{"redis cache", ScopeEverywhere, []string{"redis key val 1:7"}},
+		{"my", ScopeEverywhere, []string{"mysql key"}},
+	}
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("%s in %d", tt.keyword, tt.scope), func(t *testing.T) {
+			matches, err := b.Search(tt.keyword, tt.scope, ModeSubstring)
+			if err != nil {
+				t.Fatalf("search error: %v", err)
+			}
+			if got := describeMatches(matches); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("search got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFindQuery(t *testing.T) {
+	b := searchNotebook(t)
+	tests := []struct {
+		keyword string
+		want    []string
+	}{
+		{"mysql OR redis", []string{"mysql", "redis"}},
+		{"s -redis", []string{"mysql"}},
+		{"val:root", []string{"mysql", "北京"}},
+		{"val:root -key:my", []string{"北京"}},
+		{`val:"cache.local"`, []string{"redis"}},
+		{"(my OR 北) val:root", []string{"mysql", "北京"}},
+		{"NOT key:s", []string{"北京"}},
+	}
+	for _, tt := range tests {
+		if got := foundKeys(t, b, tt.keyword); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("find %q got %q, want %q", tt.keyword, got, tt.want)
+		}
+	}
+	if notes, err := b.Find("a OR", false); err == nil {
+		t.Errorf("find of an invalid query got %+v, want error", notes)
+	}
+
+	if err := b.Delete("mysql OR redis", false, false); err != nil {
+		t.Fatalf("delete error: %v", err)
+	}
+	if got, want := currentValues(t, b), map[string]string{"北京": "昌平区 回龙观\n海淀区 ROOT"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("notes after delete are %v, want %v", got, want)
+	}
+}
+
+func TestFindPhraseLikeSearch(t *testing.T) {
+	b := testNotebook(t)
+	if err := b.Write([]Note{mustNote(t, "my key", "1"), mustNote(t, "key of my", "2")}); err != nil {
+		t.Fatalf("write error: %v", err)
+	}
+	tests := []struct {
+		keyword string
+		want    []string
+	}{
+		{`"my key"`, []string{"my key"}},
+		{"my key", []string{"key of my", "my key"}},
+	}
+	for _, tt := range tests {
+		matches, err := b.Search(tt.keyword, ScopeKey, ModeSubstring)
+		if err != nil {
+			t.Fatalf("search %q error: %v", tt.keyword, err)
+		}
+		searched := make([]string, 0, len(matches))
+		for _, m := range matches {
+			searched = append(searched, m.Key)
+		}
+		sort.Strings(searched)
+		found := foundKeys(t, b, tt.keyword)
+		sort.Strings(found)
+		if !reflect.DeepEqual(searched, tt.want) || !reflect.DeepEqual(found, tt.want) {
+			t.Errorf("%q got %q by search and %q by find, want %q", tt.keyword, searched, found, tt.want)
+		}
+	}
+}
+
+func TestSearchModes(t *testing.T) {
+	b := testNotebook(t)
+	notes := []Note{
+		mustNote(t, "mysql", "3306"),
+		mustNote(t, "old mysql", "3307"),
+		mustNote(t, "my", "me"),
+		mustNote(t, "mysql-backup", "daily"),
+		mustNote(t, "postgres", "5432"),
+	}
+	for i := range notes {
+		notes[i].Updated = time.Unix(1700000000+int64(i), 0)
+	}
+	if err := b.Write(notes); err != nil {
+		t.Fatalf("write error: %v", err)
+	}
+
+	tests := []struct {
+		keyword string
+		mode    Mode
+		want    []string
+	}{
+		// The whole key first, then keys starting with it, then the latest updated.
+		{"my", ModeSubstring, []string{"my", "mysql-backup", "mysql", "old mysql"}},
+		{"^my.*p$", ModeRegex, []string{"mysql-backup"}},
+		{`"SQL$"`, ModeRegex, []string{"old mysql", "mysql"}},
+		{"msql", ModeFuzzy, []string{"mysql-backup", "mysql", "old mysql"}},
+		{"postgers", ModeFuzzy, []string{"postgres"}},
+		{"mysql -backup", ModeFuzzy, []string{"mysql", "old mysql"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.keyword, func(t *testing.T) {
+			matches, err := b.Search(tt.keyword, ScopeKey, tt.mode)
+			if err != nil {
+				t.Fatalf("search error: %v", err)
+			}
+			got := make([]string, 0, len(matches))
+			for _, m := range matches {
+				got = append(got, m.Key)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("search got %q, want %q", got, tt.want)
+			}
+		})
+	}
+	if matches, err := b.Search("(", ScopeKey, ModeRegex); err == nil {
+		t.Errorf("search of an invalid regex got %+v, want error", matches)
+	}
+}
+
+func TestSuggest(t *testing.T) {
+	b := searchNotebook(t)
+	tests := []struct {
+		keyword string
+		want    []string
+	}{
+		{"mysq1", []string{"mysql"}},
+		{"key:mysq1 -redis", []string{"mysql"}},
+		{"prod.*", nil},
+		{"mongodb", nil},
+		{"a OR", nil},
+	}
+	for _, tt := range tests {
+		got, err := b.Suggest(tt.keyword)
+		if err != nil {
+			t.Fatalf("suggest %q error: %v", tt.keyword, err)
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("suggest %q got %q, want %q", tt.keyword, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,112 @@
+package note
+
+import (
+	"find/internal/logs"
+	"find/internal/otp"
+	"fmt"
+	"time"
+
+	"github.com/atotto/clipboard"
+)
+
+// SecretTag marks a note whose value is a secret like a password or token, as '#secret' in user's input.
+const SecretTag = "secret"
+
+// secretMask is shown instead of values of secret notes.
+const secretMask = "******"
+
+// clipboardTimeout is how long a value copied by Copy stays in the clipboard.
+const clipboardTimeout = 30 * time.Second
+
+// Secret is used to check if the value of note is a secret, which is masked in output and logs.
+// Besides notes tagged 'secret', 2FA seeds kept as otpauth URIs are secrets too.
+func (n Note) Secret() bool {
+	return containsTag(n.Tags, SecretTag) || otp.IsURI(n.Val)
+}
+
+// shown is used to get the value of note which can be shown to the user, which is masked if it's a secret.
+func shown(n Note) string {
+	if n.Secret() {
+		return secretMask
+	}
+	return n.Val
+}
+
+// redact is used to keep the value of a secret note out of logs.
+func redact(n Note) {
+	if n.Secret() {
+		logs.Redact(n.Val)
+	}
+}
+
+// Reveal is used to get the note of key whatever it's a secret or not,
+// returning the note or nil if there is no note of key, and error.
+func (b *Notebook) Reveal(key string) (*Note, error) {
+	if err := b.available(); err != nil {
+		return nil, err
+	}
+	n, err := b.store.Get(key)
+	if err != nil {
+		return nil, fmt.Errorf("get %s error: %v", key, err)
+	}
+	return n, nil
+}
+
+// PrintRevealed is used to show a note to the user with its value even if it's a secret.
+func PrintRevealed(n Note) {
+	fmt.Printf("%s: %s\n", title(n), n.Val)
+}
+
+// Copy is used to put the value of note into the clipboard without showing it,
+// which will be cleared after 30 seconds if it's still there, returning error.
+func Copy(n Note) error {
+	err := clipboard.WriteAll(n.Val)
+	if err != nil {
+		return fmt.Errorf("write clipboard error: %v", err)
+	}
+	time.AfterFunc(clipboardTimeout, func() {
+		current, err := clipboard.ReadAll()
+		if err == nil && current == n.Val {
+			_ = clipboard.WriteAll("")
+		}
+	})
+	return nil
+}
+
+// MarkSecret is used to tag note 'secret' along with tags, so that its value is masked from now on,
+// which is done when the value turns out to be a secret like a 2FA seed read by otp.
+// It will asynchronously update the backup if the redis config is available.
+func (b *Notebook) MarkSecret(n Note, tags ...string) error {
+	if err := b.available(); err != nil {
+		return err
+	}
+	logs.Redact(n.Val)
+	_, err := b.retag("mark", []Note{n}, append(tags, SecretTag), nil)
+	return err
+}
+
+// WriteSecret is used to persist a generated secret as note, which is tagged 'secret' along with tags of the old note
+// of the same key, whose value is replaced after optional confirming, returning whether it's written and error.
+// It will asynchronously update the backup if the redis config is available.
+func (b *Notebook) WriteSecret(n Note, confirm bool) (bool, error) {
+	if err := b.available(); err != nil {
+		return false, err
+	}
+	old, err := b.store.Get(n.Key)
+	if err != nil {
+		return false, fmt.Errorf("get %s error: %v", n.Key, err)
+	}
+	n.Tags = append(n.Tags, SecretTag)
+	if old != nil {
+		if confirm {
+			sure, err := Confirmed(fmt.Sprintf("Sure replace the value of %s?", n.Key))
+			if err != nil || !sure {
+				return false, err
+			}
+		}
+		n.Tags = append(n.Tags, old.Tags...)
+	}
+	n.Tags = normalizeTags(n.Tags)
+	redact(n)
+	return true, b.modify(n, SourceUser, describe("gen", n.Key), false)
+}
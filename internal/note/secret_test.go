@@ -0,0 +1,56 @@
+package note
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSecretShown(t *testing.T) {
+	b := testNotebook(t)
+	n, err := Parse("token #secret:abc123")
+	if err != nil {
+		t.Fatalf("parse error: %v", err)
+	}
+	if err = b.Write([]Note{n}); err != nil {
+		t.Fatalf("write error: %v", err)
+	}
+	revealed, err := b.Reveal("token")
+	if err != nil || revealed == nil {
+		t.Fatalf("reveal got %+v and error %v", revealed, err)
+	}
+	if !revealed.Secret() || revealed.Val != "abc123" {
+		t.Errorf("reveal got %+v, want the secret value abc123", *revealed)
+	}
+	if got := shown(*revealed); got != secretMask {
+		t.Errorf("shown got %q, want %q", got, secretMask)
+	}
+	if got := shown(Note{Key: "plain", Val: "abc123"}); got != "abc123" {
+		t.Errorf("shown of a plain note got %q, want abc123", got)
+	}
+}
+
+func TestBackupErrorsWithoutValues(t *testing.T) {
+	b := testNotebook(t)
+	const secret = "hunter2"
+	tests := []struct {
+		name string
+		run  func() error
+	}{
+		{"load", func() error { return local{b}.Load([]byte(`{"val":"` + secret + `"`)) }},
+		{"apply broken json", func() error { return local{b}.Apply([]string{`{"op":"put","note":"` + secret + `"`}) }},
+		{"apply invalid change", func() error {
+			return local{b}.Apply([]string{`{"op":"` + secret + `","note":{"val":"` + secret + `"}}`})
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.run()
+			if err == nil {
+				t.Fatalf("%s got no error", tt.name)
+			}
+			if strings.Contains(err.Error(), secret) {
+				t.Errorf("%s got error %q, which tells the value", tt.name, err)
+			}
+		})
+	}
+}
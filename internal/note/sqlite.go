@@ -0,0 +1,244 @@
+package note
+
+import (
+	"database/sql"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	// sqlite driver in pure go
+	_ "modernc.org/sqlite"
+)
+
+// sqliteSchema creates tables of notes and store info.
+// Notes are ordered by rowid, which grows when a note is put again, just like appending to text file.
+const sqliteSchema = `
+CREATE TABLE IF NOT EXISTS notes (
+	key     TEXT PRIMARY KEY,
+	id      TEXT NOT NULL,
+	val     TEXT NOT NULL,
+	created INTEGER NOT NULL,
+	updated INTEGER NOT NULL,
+	host    TEXT NOT NULL
+);
+CREATE TABLE IF NOT EXISTS info (
+	name  TEXT PRIMARY KEY,
+	value TEXT NOT NULL
+);`
+
+// sqliteMigrations upgrade tables created by older versions, and are applied in order.
+// The number of migrations applied is kept as user_version of the database.
+var sqliteMigrations = []string{
+	"ALTER TABLE notes ADD COLUMN rev INTEGER NOT NULL DEFAULT 1",
+	"ALTER TABLE notes ADD COLUMN tags TEXT NOT NULL DEFAULT ''",
+}
+
+// sqliteColumns are columns of notes in order of scanning.
+// Tags are kept as a comma separated string.
+const sqliteColumns = "key, id, val, created, updated, host, rev, tags"
+
+// sqliteStore keeps notes in an embedded sqlite database, where every change is a transaction.
+type sqliteStore struct {
+	db *sql.DB
+}
+
+// openSqliteStore is used to open the database of specified path, creating it if not exists,
+// returning the store, whether it's newly created, and error.
+func openSqliteStore(path string) (*sqliteStore, bool, error) {
+	_, err := os.Stat(path)
+	isNew := err != nil
+
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		return nil, false, fmt.Errorf("open %s error: %v", path, err)
+	}
+	// A single connection serializes transactions, and sqlite gains nothing from more.
+	db.SetMaxOpenConns(1)
+
+	_, err = db.Exec(sqliteSchema)
+	if err != nil {
+		_ = db.Close()
+		return nil, false, fmt.Errorf("create tables in %s error: %v", path, err)
+	}
+	err = migrateSqlite(db)
+	if err != nil {
+		_ = db.Close()
+		return nil, false, fmt.Errorf("migrate %s error: %v", path, err)
+	}
+	return &sqliteStore{db: db}, isNew, nil
+}
+
+// migrateSqlite is used to apply migrations which haven't been applied to the database.
+func migrateSqlite(db *sql.DB) error {
+	var applied int
+	err := db.QueryRow("PRAGMA user_version").Scan(&applied)
+	if err != nil {
+		return fmt.Errorf("get user_version error: %v", err)
+	}
+	for i := applied; i < len(sqliteMigrations); i++ {
+		_, err = db.Exec(sqliteMigrations[i])
+		if err != nil {
+			return fmt.Errorf("apply migration %d error: %v", i+1, err)
+		}
+		_, err = db.Exec(fmt.Sprintf("PRAGMA user_version = %d", i+1))
+		if err != nil {
+			return fmt.Errorf("set user_version error: %v", err)
+		}
+	}
+	return nil
+}
+
+func (s *sqliteStore) Get(key string) (*Note, error) {
+	row := s.db.QueryRow("SELECT "+sqliteColumns+" FROM notes WHERE key = ?", key)
+	n, err := scanNote(row)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("get %s error: %v", key, err)
+	}
+	return &n, nil
+}
+
+func (s *sqliteStore) Put(notes ...Note) error {
+	return s.transact(func(tx *sql.Tx) error {
+		return putNotes(tx, notes)
+	})
+}
+
+func (s *sqliteStore) Delete(keys ...string) error {
+	return s.transact(func(tx *sql.Tx) error {
+		for _, key := range keys {
+			_, err := tx.Exec("DELETE FROM notes WHERE key = ?", key)
+			if err != nil {
+				return fmt.Errorf("delete %s error: %v", key, err)
+			}
+		}
+		return nil
+	})
+}
+
+func (s *sqliteStore) Query(match func(Note) bool) ([]Note, error) {
+	results := make([]Note, 0)
+	err := s.Iterate(func(n Note) bool {
+		if match(n) {
+			results = append(results, n)
+		}
+		return true
+	})
+	return results, err
+}
+
+func (s *sqliteStore) Iterate(fn func(Note) bool) error {
+	rows, err := s.db.Query("SELECT " + sqliteColumns + " FROM notes ORDER BY rowid")
+	if err != nil {
+		return fmt.Errorf("query notes error: %v", err)
+	}
+	defer func() {
+		_ = rows.Close()
+	}()
+
+	for rows.Next() {
+		n, err := scanNote(rows)
+		if err != nil {
+			return fmt.Errorf("scan note error: %v", err)
+		}
+		if !fn(n) {
+			break
+		}
+	}
+	return rows.Err()
+}
+
+func (s *sqliteStore) Replace(notes []Note) error {
+	return s.transact(func(tx *sql.Tx) error {
+		_, err := tx.Exec("DELETE FROM notes")
+		if err != nil {
+			return fmt.Errorf("clear notes error: %v", err)
+		}
+		return putNotes(tx, notes)
+	})
+}
+
+func (s *sqliteStore) ModTime() (time.Time, error) {
+	var value string
+	err := s.db.QueryRow("SELECT value FROM info WHERE name = 'modified'").Scan(&value)
+	if err == sql.ErrNoRows {
+		return time.Time{}, nil
+	}
+	if err != nil {
+		return time.Time{}, fmt.Errorf("get modified time error: %v", err)
+	}
+	nano, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("parse modified time %s error: %v", value, err)
+	}
+	return time.Unix(0, nano), nil
+}
+
+func (s *sqliteStore) Close() error {
+	return s.db.Close()
+}
+
+// transact is used to run fn in a transaction which also records the modified time,
+// committing if fn succeeds and rolling back otherwise.
+func (s *sqliteStore) transact(fn func(tx *sql.Tx) error) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("begin transaction error: %v", err)
+	}
+
+	err = fn(tx)
+	if err == nil {
+		_, err = tx.Exec("INSERT OR REPLACE INTO info (name, value) VALUES ('modified', ?)",
+			strconv.FormatInt(time.Now().UnixNano(), 10))
+	}
+	if err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+// putNotes is used to insert or replace notes in a transaction.
+func putNotes(tx *sql.Tx, notes []Note) error {
+	stmt, err := tx.Prepare("INSERT OR REPLACE INTO notes (" + sqliteColumns + ") VALUES (?, ?, ?, ?, ?, ?, ?, ?)")
+	if err != nil {
+		return fmt.Errorf("prepare insert error: %v", err)
+	}
+	defer func() {
+		_ = stmt.Close()
+	}()
+
+	for _, n := range notes {
+		_, err = stmt.Exec(n.Key, n.ID, n.Val, n.Created.Unix(), n.Updated.Unix(), n.Host, n.Rev, strings.Join(n.Tags, ","))
+		if err != nil {
+			return fmt.Errorf("put %s error: %v", n.Key, err)
+		}
+	}
+	return nil
+}
+
+// scanner is implemented by both sql.Row and sql.Rows.
+type scanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// scanNote is used to read a note from a row of sqliteColumns.
+func scanNote(row scanner) (Note, error) {
+	var n Note
+	var created, updated int64
+	var tags string
+	err := row.Scan(&n.Key, &n.ID, &n.Val, &created, &updated, &n.Host, &n.Rev, &tags)
+	if err != nil {
+		return Note{}, err
+	}
+	if tags != "" {
+		n.Tags = strings.Split(tags, ",")
+	}
+	n.Created = time.Unix(created, 0)
+	n.Updated = time.Unix(updated, 0)
+	return n, nil
+}
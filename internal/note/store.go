@@ -0,0 +1,124 @@
+package note
+
+import (
+	"find/internal/logs"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const (
+	storeTypeText    = "text"
+	storeTypeSqlite  = "sqlite"
+	storeTypeJournal = "journal"
+)
+
+// Store is where notes are kept. Every method is safe to call from multiple goroutines,
+// and methods changing notes take effect all or nothing.
+type Store interface {
+	// Get is used to fetch the note of specified key, returning nil if not found.
+	Get(key string) (*Note, error)
+	// Put is used to add notes, replacing the ones with same keys.
+	Put(notes ...Note) error
+	// Delete is used to remove notes of specified keys, ignoring the keys not found.
+	Delete(keys ...string) error
+	// Query is used to fetch notes which match in order of insertion.
+	Query(match func(Note) bool) ([]Note, error)
+	// Iterate is used to visit notes in order of insertion until fn returns false.
+	// fn shouldn't call methods of the store.
+	Iterate(fn func(Note) bool) error
+	// Replace is used to replace all notes, e.g. when pulling backup.
+	Replace(notes []Note) error
+	// ModTime is used to get the last time when notes changed.
+	ModTime() (time.Time, error)
+	// Close is used to release the store.
+	Close() error
+}
+
+// openStore is used to open the store configured by 'store' of notebook,
+// returning the store, whether it's newly created, and error.
+// A new store other than text imports notes from the text file at notePath if there is one.
+func (b *Notebook) openStore() (Store, bool, error) {
+	var s Store
+	var isNew bool
+	var err error
+	switch b.conf.Store {
+	case "", storeTypeText:
+		if b.Locked() {
+			return nil, false, errLocked
+		}
+		return openTextStore(b.conf.NotePath, b.sealer)
+	case storeTypeSqlite:
+		s, isNew, err = openSqliteStore(b.storePath())
+	case storeTypeJournal:
+		var j *journalStore
+		j, isNew, err = openJournalStore(b.storePath())
+		if j != nil {
+			j.lock = b.lock
+			s = j
+		}
+	default:
+		return nil, false, fmt.Errorf("invalid store: %s", b.conf.Store)
+	}
+	if err != nil || !isNew {
+		return s, isNew, err
+	}
+
+	imported, err := b.importText(s)
+	if err != nil {
+		_ = s.Close()
+		return nil, false, fmt.Errorf("import notes from %s error: %v", b.conf.NotePath, err)
+	}
+	return s, !imported, nil
+}
+
+// storePath is used to get the path of the file where the configured store of notebook keeps notes.
+// Files other than text are beside notePath by default.
+func (b *Notebook) storePath() string {
+	path := b.conf.NotePath
+	switch b.conf.Store {
+	case storeTypeSqlite:
+		if b.conf.DbPath != "" {
+			return b.conf.DbPath
+		}
+		return strings.TrimSuffix(path, filepath.Ext(path)) + ".db"
+	case storeTypeJournal:
+		if b.conf.JournalPath != "" {
+			return b.conf.JournalPath
+		}
+		return strings.TrimSuffix(path, filepath.Ext(path)) + ".journal"
+	}
+	return path
+}
+
+// importText is used to copy notes from the text file at notePath into specified store,
+// returning whether there are notes imported and error.
+func (b *Notebook) importText(s Store) (bool, error) {
+	path := b.conf.NotePath
+	if _, err := os.Stat(path); err != nil {
+		return false, nil
+	}
+	text, _, err := openTextStore(path, b.sealer)
+	if err != nil {
+		return false, err
+	}
+	defer func() {
+		_ = text.Close()
+	}()
+
+	notes, err := text.Query(func(Note) bool { return true })
+	if err != nil {
+		return false, err
+	}
+	if len(notes) == 0 {
+		return false, nil
+	}
+	err = s.Replace(notes)
+	if err != nil {
+		return false, err
+	}
+	logs.Info("note: imported %d notes from %s", len(notes), path)
+	return true, nil
+}
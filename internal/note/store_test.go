@@ -0,0 +1,113 @@
+package note
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// testStores are ways to open every store in a directory, by type of store.
+var testStores = map[string]func(dir string) (Store, bool, error){
+	storeTypeText: func(dir string) (Store, bool, error) {
+		return openTextStore(filepath.Join(dir, "FIND.txt"), nil)
+	},
+	storeTypeSqlite: func(dir string) (Store, bool, error) {
+		return openSqliteStore(filepath.Join(dir, "FIND.db"))
+	},
+	storeTypeJournal: func(dir string) (Store, bool, error) {
+		return openJournalStore(filepath.Join(dir, "FIND.journal"))
+	},
+}
+
+// storeNote is used to make a note of key and val for store tests.
+func storeNote(key, val string) Note {
+	at := time.Unix(1700000000, 0)
+	return Note{ID: "id-" + key, Key: key, Val: val, Created: at, Updated: at, Host: "vm", Rev: 1}
+}
+
+// storeNotes is used to get all notes of s in order.
+func storeNotes(t *testing.T, s Store) []Note {
+	notes, err := s.Query(func(Note) bool { return true })
+	if err != nil {
+		t.Fatalf("query error: %v", err)
+	}
+	return notes
+}
+
+func TestStore(t *testing.T) {
+	a, b, c := storeNote("a", "1"), storeNote("北京:昌平", "2"), storeNote("c", "")
+	a2 := storeNote("a", "3")
+	for name, open := range testStores {
+		t.Run(name, func(t *testing.T) {
+			dir := t.TempDir()
+			s, isNew, err := open(dir)
+			if err != nil {
+				t.Fatalf("open error: %v", err)
+			}
+			if !isNew {
+				t.Errorf("open got an existing store, want a new one")
+			}
+			if got := storeNotes(t, s); len(got) != 0 {
+				t.Errorf("new store has %+v, want nothing", got)
+			}
+
+			if err = s.Put(a, b); err != nil {
+				t.Fatalf("put error: %v", err)
+			}
+			if err = s.Put(c, a2); err != nil {
+				t.Fatalf("put again error: %v", err)
+			}
+			if got, want := storeNotes(t, s), []Note{b, c, a2}; !reflect.DeepEqual(got, want) {
+				t.Errorf("notes after put are %+v, want %+v", got, want)
+			}
+			got, err := s.Get(a.Key)
+			if err != nil || got == nil || !reflect.DeepEqual(*got, a2) {
+				t.Errorf("get %s got %+v and error %v, want %+v", a.Key, got, err, a2)
+			}
+			got, err = s.Get("missing")
+			if err != nil || got != nil {
+				t.Errorf("get missing got %+v and error %v, want nothing", got, err)
+			}
+
+			if err = s.Delete(b.Key, "missing"); err != nil {
+				t.Fatalf("delete error: %v", err)
+			}
+			visited := 0
+			err = s.Iterate(func(n Note) bool {
+				visited++
+				return false
+			})
+			if err != nil || visited != 1 {
+				t.Errorf("iterate visited %d notes with error %v, want 1 note", visited, err)
+			}
+			modTime, err := s.ModTime()
+			if err != nil || modTime.IsZero() {
+				t.Errorf("modified time is %v with error %v, want a time", modTime, err)
+			}
+			if err = s.Close(); err != nil {
+				t.Fatalf("close error: %v", err)
+			}
+
+			s, isNew, err = open(dir)
+			if err != nil {
+				t.Fatalf("reopen error: %v", err)
+			}
+			defer func() {
+				_ = s.Close()
+			}()
+			if isNew {
+				t.Errorf("reopen got a new store, want the existing one")
+			}
+			if got, want := storeNotes(t, s), []Note{c, a2}; !reflect.DeepEqual(got, want) {
+				t.Errorf("notes after reopen are %+v, want %+v", got, want)
+			}
+			if err = s.Replace([]Note{b}); err != nil {
+				t.Fatalf("replace error: %v", err)
+			}
+			if got, want := storeNotes(t, s), []Note{b}; !reflect.DeepEqual(got, want) {
+				t.Errorf("notes after replace are %+v, want %+v", got, want)
+			}
+		})
+	}
+}
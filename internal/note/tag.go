@@ -0,0 +1,208 @@
+package note
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// tagPrefix marks a tag in user's input like '#db', which is kept without the prefix.
+const tagPrefix = "#"
+
+// TagCount is a tag along with how many notes have it.
+type TagCount struct {
+	Tag   string
+	Count int
+}
+
+// normalizeTags is used to clean tags up, which are lower-case without prefix,
+// separators or duplicates, returning sorted tags or nil if there is none.
+func normalizeTags(tags []string) []string {
+	set := make(map[string]bool, len(tags))
+	for _, tag := range tags {
+		tag = strings.ToLower(strings.TrimPrefix(strings.TrimSpace(tag), tagPrefix))
+		tag = strings.Map(func(r rune) rune {
+			if r == ',' || r == ':' || r == '\\' || r == ' ' || r == '\t' || r == '\n' || r == '\r' {
+				return -1
+			}
+			return r
+		}, tag)
+		if tag != "" {
+			set[tag] = true
+		}
+	}
+	if len(set) == 0 {
+		return nil
+	}
+	results := make([]string, 0, len(set))
+	for tag := range set {
+		results = append(results, tag)
+	}
+	sort.Strings(results)
+	return results
+}
+
+// isTag is used to check if a word of user's input is a tag like '#db'.
+func isTag(word string) bool {
+	return len(word) > len(tagPrefix) && strings.HasPrefix(word, tagPrefix)
+}
+
+// splitTags is used to pick tags like '#db' out of words of user's input,
+// returning the other words and tags. A word starting with '\#' is taken as a word starting with '#'.
+func splitTags(words []string) ([]string, []string) {
+	others := make([]string, 0, len(words))
+	tags := make([]string, 0)
+	for _, word := range words {
+		switch {
+		case isTag(word):
+			tags = append(tags, word)
+		case strings.HasPrefix(word, `\`+tagPrefix):
+			others = append(others, word[1:])
+		default:
+			others = append(others, word)
+		}
+	}
+	return others, normalizeTags(tags)
+}
+
+// containsTag is used to judge if tags contain specified tag.
+func containsTag(tags []string, tag string) bool {
+	for _, t := range tags {
+		if t == tag {
+			return true
+		}
+	}
+	return false
+}
+
+// sameTags is used to judge if two sorted tag lists are equal.
+func sameTags(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// title is used to get the key of note followed by its tags like 'key #db #prod'.
+func title(n Note) string {
+	if len(n.Tags) == 0 {
+		return n.Key
+	}
+	return n.Key + " " + tagPrefix + strings.Join(n.Tags, " "+tagPrefix)
+}
+
+// Tags is used to count notes of every tag, returning tags sorted by count and then name, and error.
+func (b *Notebook) Tags() ([]TagCount, error) {
+	if err := b.available(); err != nil {
+		return nil, err
+	}
+	counts := b.store.TagCounts()
+	results := make([]TagCount, 0, len(counts))
+	for tag, count := range counts {
+		results = append(results, TagCount{Tag: tag, Count: count})
+	}
+	sort.Slice(results, func(i, j int) bool {
+		if results[i].Count != results[j].Count {
+			return results[i].Count > results[j].Count
+		}
+		return results[i].Tag < results[j].Tag
+	})
+	return results, nil
+}
+
+// PrintTags is used to show tags with counts to the user.
+func PrintTags(tags []TagCount) {
+	if len(tags) == 0 {
+		fmt.Println("Empty result.")
+		return
+	}
+	for _, t := range tags {
+		fmt.Printf("%s%s %d\n", tagPrefix, t.Tag, t.Count)
+	}
+}
+
+// Retag is used to add and remove tags of the notes found by keyword like Find after optional confirming,
+// returning the number of notes changed and error,
+// and will asynchronously update the backup if the redis config is available.
+func (b *Notebook) Retag(keyword string, added, removed []string, confirm bool) (int, error) {
+	notes, err := b.Find(keyword, false)
+	if err != nil {
+		return 0, fmt.Errorf("find %s error: %v", keyword, err)
+	}
+	if len(notes) == 0 {
+		return 0, nil
+	}
+	if confirm {
+		fmt.Println("Will tag:")
+		Print(notes, false)
+		ok, err := Confirmed("Sure tag?")
+		if err != nil || !ok {
+			return 0, err
+		}
+	}
+	return b.retag("tag", notes, added, removed)
+}
+
+// retag is used to add and remove tags of notes for an order of action,
+// returning the number of notes changed and error.
+func (b *Notebook) retag(action string, notes []Note, added, removed []string) (int, error) {
+	added = normalizeTags(added)
+	removed = normalizeTags(removed)
+
+	unlock, err := b.lock()
+	if err != nil {
+		return 0, err
+	}
+	defer unlock()
+
+	olds, err := b.currents(notes)
+	if err != nil {
+		return 0, err
+	}
+	now := time.Now()
+	changed := make([]Note, 0, len(olds))
+	befores := make(map[string]*Note, len(olds))
+	keys := make([]string, 0, len(olds))
+	for key, old := range olds {
+		if old == nil {
+			continue
+		}
+		tags := make([]string, 0, len(old.Tags)+len(added))
+		for _, tag := range old.Tags {
+			if !containsTag(removed, tag) {
+				tags = append(tags, tag)
+			}
+		}
+		tags = normalizeTags(append(tags, added...))
+		if sameTags(tags, old.Tags) {
+			continue
+		}
+
+		n := *old
+		n.Tags = tags
+		n.Rev, err = b.nextRev(key, old)
+		if err != nil {
+			return 0, err
+		}
+		n.Updated = now
+		n.Host = host
+		changed = append(changed, n)
+		befores[key] = old
+		keys = append(keys, key)
+	}
+	if len(changed) == 0 {
+		return 0, nil
+	}
+	err = b.put(changed, befores, SourceUser)
+	if err != nil {
+		return 0, err
+	}
+	b.pushUndo(describe(action, keys...), befores, changed)
+	return len(changed), nil
+}
@@ -0,0 +1,117 @@
+package note
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestNormalizeTags(t *testing.T) {
+	tests := []struct {
+		tags []string
+		want []string
+	}{
+		{nil, nil},
+		{[]string{"#", " ", "::"}, nil},
+		{[]string{"#DB", "db", " prod "}, []string{"db", "prod"}},
+		{[]string{"a,b", `c\d:e`, "北京"}, []string{"ab", "cde", "北京"}},
+	}
+	for _, tt := range tests {
+		if got := normalizeTags(tt.tags); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("normalize %q got %q, want %q", tt.tags, got, tt.want)
+		}
+	}
+}
+
+func TestParseTags(t *testing.T) {
+	tests := []struct {
+		input string
+		key   string
+		tags  []string
+		val   string
+	}{
+		{"mysql #DB #prod:root@host", "mysql", []string{"db", "prod"}, "root@host"},
+		{"#db mysql:x #y", "mysql", []string{"db"}, "x #y"},
+		{`issue \#1:fixed`, "issue #1", nil, "fixed"},
+		{"a # b:c", "a # b", nil, "c"},
+	}
+	for _, tt := range tests {
+		n, err := Parse(tt.input)
+		if err != nil {
+			t.Errorf("parse %q error: %v", tt.input, err)
+			continue
+		}
+		if n.Key != tt.key || !reflect.DeepEqual(n.Tags, tt.tags) || n.Val != tt.val {
+			t.Errorf("parse %q got %q %q %q, want %q %q %q", tt.input, n.Key, n.Tags, n.Val, tt.key, tt.tags, tt.val)
+		}
+	}
+	if n, err := Parse("#db:x"); err == nil {
+		t.Errorf("parse of tags without key got %+v, want error", n)
+	}
+}
+
+// foundKeys is used to get keys of notes found by keyword.
+func foundKeys(t *testing.T, b *Notebook, keyword string) []string {
+	notes, err := b.Find(keyword, false)
+	if err != nil {
+		t.Fatalf("find %s error: %v", keyword, err)
+	}
+	keys := make([]string, 0, len(notes))
+	for _, n := range notes {
+		keys = append(keys, n.Key)
+	}
+	return keys
+}
+
+func TestTags(t *testing.T) {
+	b := testNotebook(t)
+	notes := make([]Note, 0)
+	for _, input := range []string{"mysql #db #prod:1", "redis #db:2", "todo:3"} {
+		n, err := Parse(input)
+		if err != nil {
+			t.Fatalf("parse %q error: %v", input, err)
+		}
+		notes = append(notes, n)
+	}
+	if err := b.Write(notes); err != nil {
+		t.Fatalf("write error: %v", err)
+	}
+
+	searches := []struct {
+		keyword string
+		want    []string
+	}{
+		{"#db", []string{"mysql", "redis"}},
+		{"#DB #prod", []string{"mysql"}},
+		{"re #db", []string{"redis"}},
+		{"#none", []string{}},
+	}
+	for _, s := range searches {
+		if got := foundKeys(t, b, s.keyword); !reflect.DeepEqual(got, s.want) {
+			t.Errorf("find %q got %q, want %q", s.keyword, got, s.want)
+		}
+	}
+	tags, err := b.Tags()
+	if want := []TagCount{{"db", 2}, {"prod", 1}}; err != nil || !reflect.DeepEqual(tags, want) {
+		t.Errorf("tags got %v and error %v, want %v", tags, err, want)
+	}
+
+	changed, err := b.Retag("#db", []string{"#cache"}, []string{"prod"}, false)
+	if err != nil || changed != 2 {
+		t.Fatalf("retag got %d and error %v, want 2 notes changed", changed, err)
+	}
+	tags, err = b.Tags()
+	if want := []TagCount{{"cache", 2}, {"db", 2}}; err != nil || !reflect.DeepEqual(tags, want) {
+		t.Errorf("tags after retag got %v and error %v, want %v", tags, err, want)
+	}
+	changed, err = b.Retag("#db", []string{"cache"}, nil, false)
+	if err != nil || changed != 0 {
+		t.Errorf("retag with nothing new got %d and error %v, want nothing changed", changed, err)
+	}
+
+	if _, err = b.Undo(); err != nil {
+		t.Fatalf("undo error: %v", err)
+	}
+	if got, want := foundKeys(t, b, "#prod"), []string{"mysql"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("find #prod after undo got %q, want %q", got, want)
+	}
+}
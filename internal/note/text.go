@@ -0,0 +1,209 @@
+package note
+
+import (
+	"find/internal/logs"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+// textStore keeps notes in a text file, one note per line, which users can also edit by hand.
+// Every change rewrites the file atomically.
+// If the notebook is encrypted, every line is sealed, see sealer.
+type textStore struct {
+	path  string
+	mutex sync.Mutex
+	// sealer encrypts the file, which is nil if it isn't encrypted.
+	sealer *sealer
+}
+
+// openTextStore is used to open the text file of specified path, creating it if not exists
+// and migrating it to current version or sealing it by sealer if necessary,
+// returning the store, whether it's newly created, and error.
+func openTextStore(path string, sealer *sealer) (*textStore, bool, error) {
+	s := &textStore{path: path, sealer: sealer}
+	if _, err := os.Stat(path); err != nil {
+		// If note not exists, then create.
+		err = s.save(nil)
+		if err != nil {
+			return nil, false, fmt.Errorf("create %s error: %v", path, err)
+		}
+		return s, true, nil
+	}
+
+	// If note exists, then migrate if necessary.
+	err := s.migrate()
+	if err != nil {
+		return nil, false, fmt.Errorf("migrate %s error: %v", path, err)
+	}
+	return s, false, nil
+}
+
+// migrate is used to rewrite note in current version if it's written in an older version
+// or contains lines without metadata. A copy of note in older version is kept beside it.
+func (s *textStore) migrate() error {
+	lines, sealStale, err := readSealedLines(s.path, sealedNote, s.sealer)
+	if err != nil {
+		return err
+	}
+	notes, version, stale, err := decode(lines)
+	if err != nil {
+		return fmt.Errorf("decode %s error: %v", s.path, err)
+	}
+	if !stale && !sealStale {
+		return nil
+	}
+
+	if version != currentVersion {
+		bakPath := fmt.Sprintf("%s.v%d.bak", s.path, version)
+		err = writeSealedLines(bakPath, sealedNote, lines, s.sealer)
+		if err != nil {
+			return fmt.Errorf("copy %s to %s error: %v", s.path, bakPath, err)
+		}
+		logs.Info("note: migrate from v%d to v%d, old note is kept in %s", version, currentVersion, bakPath)
+	}
+	return s.save(notes)
+}
+
+// load is used to read all notes from the file, returning notes and error.
+func (s *textStore) load() ([]Note, error) {
+	lines, _, err := readSealedLines(s.path, sealedNote, s.sealer)
+	if err != nil {
+		return nil, err
+	}
+	notes, _, _, err := decode(lines)
+	if err != nil {
+		return nil, fmt.Errorf("decode %s error: %v", s.path, err)
+	}
+	return notes, nil
+}
+
+// save is used to persist notes into the file, replacing all of its data atomically.
+func (s *textStore) save(notes []Note) error {
+	err := writeSealedLines(s.path, sealedNote, encode(notes), s.sealer)
+	if err != nil {
+		return fmt.Errorf("write %d notes to %s error: %v", len(notes), s.path, err)
+	}
+	return nil
+}
+
+// reseal is used to rewrite the file sealed by next, or in plain text if next is nil.
+func (s *textStore) reseal(next *sealer) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	notes, err := s.load()
+	if err != nil {
+		return err
+	}
+	s.sealer = next
+	return s.save(notes)
+}
+
+func (s *textStore) Get(key string) (*Note, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	notes, err := s.load()
+	if err != nil {
+		return nil, err
+	}
+	for _, n := range notes {
+		if n.Key == key {
+			return &n, nil
+		}
+	}
+	return nil, nil
+}
+
+func (s *textStore) Put(notes ...Note) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	olds, err := s.load()
+	if err != nil {
+		return err
+	}
+	keys := keySet(notes)
+	kept := make([]Note, 0, len(olds)+len(notes))
+	for _, n := range olds {
+		if !keys[n.Key] {
+			kept = append(kept, n)
+		}
+	}
+	return s.save(append(kept, notes...))
+}
+
+func (s *textStore) Delete(keys ...string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	olds, err := s.load()
+	if err != nil {
+		return err
+	}
+	deleted := make(map[string]bool, len(keys))
+	for _, key := range keys {
+		deleted[key] = true
+	}
+	kept := make([]Note, 0, len(olds))
+	for _, n := range olds {
+		if !deleted[n.Key] {
+			kept = append(kept, n)
+		}
+	}
+	return s.save(kept)
+}
+
+func (s *textStore) Query(match func(Note) bool) ([]Note, error) {
+	results := make([]Note, 0)
+	err := s.Iterate(func(n Note) bool {
+		if match(n) {
+			results = append(results, n)
+		}
+		return true
+	})
+	return results, err
+}
+
+func (s *textStore) Iterate(fn func(Note) bool) error {
+	s.mutex.Lock()
+	notes, err := s.load()
+	s.mutex.Unlock()
+	if err != nil {
+		return err
+	}
+	for _, n := range notes {
+		if !fn(n) {
+			break
+		}
+	}
+	return nil
+}
+
+func (s *textStore) Replace(notes []Note) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.save(notes)
+}
+
+func (s *textStore) ModTime() (time.Time, error) {
+	fileInfo, err := os.Stat(s.path)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("stat %s error: %v", s.path, err)
+	}
+	return fileInfo.ModTime(), nil
+}
+
+func (s *textStore) Close() error {
+	return nil
+}
+
+// keySet is used to collect keys of notes, returning a set of keys.
+func keySet(notes []Note) map[string]bool {
+	keys := make(map[string]bool, len(notes))
+	for _, n := range notes {
+		keys[n.Key] = true
+	}
+	return keys
+}
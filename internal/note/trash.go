@@ -0,0 +1,273 @@
+package note
+
+import (
+	"find/internal/config"
+	"find/internal/logs"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// trashHeaderPrefix starts the first line of trash, followed by the version of notes in it.
+const trashHeaderPrefix = "#FIND trash v"
+
+// defaultTrashDays is how many days deleted notes are kept if it's not configured.
+const defaultTrashDays = 30
+
+// Trashed is a deleted note kept in trash, along with when it was deleted.
+type Trashed struct {
+	Note
+	Deleted time.Time `json:"deleted"`
+}
+
+// trashPath is used to get the path of trash, which is a file beside the store
+// where each deleted note is a line of deletion time and encoded note separated by a tab.
+func (b *Notebook) trashPath() string {
+	return b.storePath() + ".trash"
+}
+
+// trashRetention is used to get how long deleted notes are kept in trash.
+func trashRetention() time.Duration {
+	days := config.Conf.Find.TrashDays
+	if days <= 0 {
+		days = defaultTrashDays
+	}
+	return time.Duration(days) * 24 * time.Hour
+}
+
+// loadTrash is used to read deleted notes from trash, from the earliest deleted to the latest,
+// dropping the ones deleted before the retention period. It must be called with the lock taken.
+func (b *Notebook) loadTrash() ([]Trashed, error) {
+	path := b.trashPath()
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		return nil, nil
+	}
+	lines, stale, err := readSealedLines(path, sealedTrash, b.sealer)
+	if err != nil {
+		return nil, err
+	}
+	version := currentVersion
+	if len(lines) > 0 && strings.HasPrefix(lines[0], trashHeaderPrefix) {
+		version, err = strconv.Atoi(strings.TrimPrefix(lines[0], trashHeaderPrefix))
+		if err != nil || version > currentVersion {
+			return nil, fmt.Errorf("unsupported header %s, please upgrade FIND", lines[0])
+		}
+		lines = lines[1:]
+	}
+
+	expiry := time.Now().Add(-trashRetention())
+	trashed := make([]Trashed, 0, len(lines))
+	expired := 0
+	for i, line := range lines {
+		t, err := parseTrashed(line, version)
+		if err != nil {
+			logs.Warn("note: skip line %d of %s: %s", i+2, path, err.Error())
+			continue
+		}
+		if t.Deleted.Before(expiry) {
+			expired++
+			continue
+		}
+		trashed = append(trashed, t)
+	}
+	if expired > 0 || version != currentVersion || stale {
+		logs.Info("note: %d notes in trash expired", expired)
+		err = b.saveTrash(trashed)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return trashed, nil
+}
+
+// saveTrash is used to replace all of trash by deleted notes atomically.
+// It must be called with the lock taken.
+func (b *Notebook) saveTrash(trashed []Trashed) error {
+	lines := make([]string, 0, len(trashed)+1)
+	lines = append(lines, trashHeaderPrefix+strconv.Itoa(currentVersion))
+	for _, t := range trashed {
+		lines = append(lines, formatTrashed(t))
+	}
+	err := writeSealedLines(b.trashPath(), sealedTrash, lines, b.sealer)
+	if err != nil {
+		return fmt.Errorf("write %d notes to %s error: %v", len(trashed), b.trashPath(), err)
+	}
+	return nil
+}
+
+// trash is used to move notes into trash as deleted now. It must be called with the lock taken.
+func (b *Notebook) trash(notes []Note) error {
+	if len(notes) == 0 {
+		return nil
+	}
+	trashed, err := b.loadTrash()
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	for _, n := range notes {
+		trashed = append(trashed, Trashed{Note: n, Deleted: now})
+	}
+	return b.saveTrash(trashed)
+}
+
+// untrash is used to take the latest deleted notes of specified keys out of trash,
+// returning the notes taken and error. It must be called with the lock taken.
+func (b *Notebook) untrash(keys ...string) ([]Note, error) {
+	trashed, err := b.loadTrash()
+	if err != nil {
+		return nil, err
+	}
+	taken := make([]Note, 0, len(keys))
+	for _, key := range keys {
+		for i := len(trashed) - 1; i >= 0; i-- {
+			if trashed[i].Key == key {
+				taken = append(taken, trashed[i].Note)
+				trashed = append(trashed[:i], trashed[i+1:]...)
+				break
+			}
+		}
+	}
+	if len(taken) == 0 {
+		return taken, nil
+	}
+	return taken, b.saveTrash(trashed)
+}
+
+// formatTrashed is used to format a deleted note into a line of trash.
+func formatTrashed(t Trashed) string {
+	return strconv.FormatInt(t.Deleted.Unix(), 10) + "\t" + encodeNote(t.Note)
+}
+
+// parseTrashed is used to parse a line of trash of specified version, returning the deleted note and error.
+func parseTrashed(line string, version int) (Trashed, error) {
+	i := strings.Index(line, "\t")
+	if i == -1 {
+		return Trashed{}, fmt.Errorf("missing tab after deletion time")
+	}
+	deleted, ok := parseUnix(line[:i])
+	if !ok {
+		return Trashed{}, fmt.Errorf("invalid deletion time %s", line[:i])
+	}
+	n, complete, err := decodeNote(line[i+1:], version)
+	if err != nil {
+		return Trashed{}, err
+	}
+	if !complete {
+		return Trashed{}, fmt.Errorf("incomplete metadata")
+	}
+	return Trashed{Note: n, Deleted: deleted}, nil
+}
+
+// Trash is used to get deleted notes kept in trash, from the earliest deleted to the latest,
+// returning deleted notes and error.
+func (b *Notebook) Trash() ([]Trashed, error) {
+	if err := b.available(); err != nil {
+		return nil, err
+	}
+	unlock, err := b.lock()
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+	return b.loadTrash()
+}
+
+// Restore is used to bring the latest deleted note of specified key back from trash,
+// and will asynchronously update the backup if the redis config is available.
+func (b *Notebook) Restore(key string) error {
+	if err := b.available(); err != nil {
+		return err
+	}
+	unlock, err := b.lock()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	old, err := b.store.Get(key)
+	if err != nil {
+		return fmt.Errorf("get %s error: %v", key, err)
+	}
+	if old != nil {
+		return fmt.Errorf("%s already exists, modify or delete it first", key)
+	}
+	taken, err := b.untrash(key)
+	if err != nil {
+		return err
+	}
+	if len(taken) == 0 {
+		return fmt.Errorf("%s not found in trash", key)
+	}
+	err = b.putBack(taken)
+	if err != nil {
+		return err
+	}
+	b.pushUndo(describe("trash restore", key), map[string]*Note{key: nil}, taken)
+	return nil
+}
+
+// putBack is used to put old values of notes back as new revisions, keeping their id and creation time.
+// It must be called with the lock taken.
+func (b *Notebook) putBack(notes []Note) error {
+	olds, err := b.currents(notes)
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	for i := range notes {
+		notes[i].Rev, err = b.nextRev(notes[i].Key, olds[notes[i].Key])
+		if err != nil {
+			return err
+		}
+		notes[i].Updated = now
+		notes[i].Host = host
+	}
+	return b.put(notes, olds, SourceUser)
+}
+
+// Purge is used to remove all deleted notes from trash after optional confirming,
+// returning the number of notes removed and error.
+func (b *Notebook) Purge(confirm bool) (int, error) {
+	trashed, err := b.Trash()
+	if err != nil {
+		return 0, err
+	}
+	if len(trashed) == 0 {
+		return 0, nil
+	}
+	if confirm {
+		fmt.Printf("Will purge %d notes in trash forever.\n", len(trashed))
+		ok, err := Confirmed("Sure purge?")
+		if err != nil || !ok {
+			return 0, err
+		}
+	}
+
+	unlock, err := b.lock()
+	if err != nil {
+		return 0, err
+	}
+	defer unlock()
+	trashed, err = b.loadTrash()
+	if err != nil {
+		return 0, err
+	}
+	return len(trashed), b.saveTrash(nil)
+}
+
+// PrintTrash is used to show deleted notes to the user, the latest deleted first.
+func PrintTrash(trashed []Trashed) {
+	if len(trashed) == 0 {
+		fmt.Println("Empty result.")
+		return
+	}
+	for i := len(trashed) - 1; i >= 0; i-- {
+		t := trashed[i]
+		fmt.Printf("%s: %s\n", title(t.Note), shown(t.Note))
+		fmt.Printf("    deleted %s, expires %s\n",
+			t.Deleted.Format(timeLayout), t.Deleted.Add(trashRetention()).Format(timeLayout))
+	}
+}
@@ -0,0 +1,62 @@
+package note
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestTrashRestore(t *testing.T) {
+	b := testNotebook(t)
+	if err := b.Write([]Note{mustNote(t, "a", "1"), mustNote(t, "b", "2")}); err != nil {
+		t.Fatalf("write error: %v", err)
+	}
+	if err := b.Delete("a", false, true); err != nil {
+		t.Fatalf("delete error: %v", err)
+	}
+	if err := b.Delete("b", false, true); err != nil {
+		t.Fatalf("delete error: %v", err)
+	}
+	if got, want := trashedKeys(t, b), []string{"a", "b"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("trash got %q, want %q", got, want)
+	}
+
+	if err := b.Restore("a"); err != nil {
+		t.Fatalf("restore error: %v", err)
+	}
+	if got, want := currentValues(t, b), map[string]string{"a": "1"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("notes after restore are %v, want %v", got, want)
+	}
+	if got, want := trashedKeys(t, b), []string{"b"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("trash after restore got %q, want %q", got, want)
+	}
+	if err := b.Restore("a"); err == nil {
+		t.Errorf("restore of an existing key got no error")
+	}
+	if err := b.Restore("c"); err == nil {
+		t.Errorf("restore of a key not in trash got no error")
+	}
+
+	purged, err := b.Purge(false)
+	if err != nil || purged != 1 {
+		t.Errorf("purge got %d and error %v, want 1", purged, err)
+	}
+	if got := trashedKeys(t, b); len(got) != 0 {
+		t.Errorf("trash after purge got %q, want nothing", got)
+	}
+}
+
+func TestTrashRetention(t *testing.T) {
+	b := testNotebook(t)
+	now := time.Now()
+	err := b.saveTrash([]Trashed{
+		{Note: mustNote(t, "old", "1"), Deleted: now.Add(-trashRetention() - time.Hour)},
+		{Note: mustNote(t, "new", "2"), Deleted: now.Add(-time.Hour)},
+	})
+	if err != nil {
+		t.Fatalf("save trash error: %v", err)
+	}
+	if got, want := trashedKeys(t, b), []string{"new"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("trash got %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,136 @@
+package note
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// separators split a key into a path of segments, like 'prod.db.mysql' or 'prod/db/mysql'.
+const separators = "./"
+
+// subtreeSuffix ends a keyword which means a whole subtree, like 'prod.db.*' or 'prod/db/*'.
+const subtreeSuffix = "*"
+
+// Branch is a node in the tree of keys, which may be a note, a branch of other nodes, or both.
+type Branch struct {
+	Name string
+	// Count is the number of notes in the subtree, including the node itself.
+	Count int
+	// IsNote reports whether the path to the node is the key of a note.
+	IsNote   bool
+	Children []*Branch
+}
+
+// segments is used to split a key into lower-case segments of its path, ignoring empty ones.
+func segments(key string) []string {
+	return strings.FieldsFunc(strings.ToLower(key), func(r rune) bool {
+		return strings.ContainsRune(separators, r)
+	})
+}
+
+// isSubtree is used to check if a word of user's input means a subtree like 'prod.db.*'.
+func isSubtree(word string) bool {
+	if !strings.HasSuffix(word, subtreeSuffix) {
+		return false
+	}
+	rest := strings.TrimSuffix(word, subtreeSuffix)
+	return rest != "" && strings.ContainsAny(rest[len(rest)-1:], separators)
+}
+
+// inSubtree is used to judge if the key is the root or a descendant of the subtree of specified path.
+func inSubtree(key string, path []string) bool {
+	keyPath := segments(key)
+	if len(keyPath) < len(path) {
+		return false
+	}
+	for i := range path {
+		if keyPath[i] != path[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// Tree is used to build the tree of keys under specified prefix like 'prod.db',
+// returning the root whose name is the prefix, and error.
+func (b *Notebook) Tree(prefix string) (*Branch, error) {
+	if err := b.available(); err != nil {
+		return nil, err
+	}
+	path := segments(strings.TrimSuffix(prefix, subtreeSuffix))
+	notes, err := b.store.Query(func(n Note) bool {
+		return inSubtree(n.Key, path)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	root := &Branch{Name: strings.Join(path, ".")}
+	for _, n := range notes {
+		node := root
+		node.Count++
+		for _, segment := range segments(n.Key)[len(path):] {
+			node = node.child(segment)
+			node.Count++
+		}
+		node.IsNote = true
+	}
+	root.sort()
+	return root, nil
+}
+
+// child is used to get the child of specified name, adding it if not exists.
+func (br *Branch) child(name string) *Branch {
+	for _, c := range br.Children {
+		if c.Name == name {
+			return c
+		}
+	}
+	c := &Branch{Name: name}
+	br.Children = append(br.Children, c)
+	return c
+}
+
+// sort is used to sort children of the subtree by name.
+func (br *Branch) sort() {
+	sort.Slice(br.Children, func(i, j int) bool {
+		return br.Children[i].Name < br.Children[j].Name
+	})
+	for _, c := range br.Children {
+		c.sort()
+	}
+}
+
+// PrintTree is used to show the tree of keys to the user, one node per line indented by depth.
+// A branch is shown like 'db/ (3)' with the number of notes in it,
+// and marked with '*' if it's also a note itself.
+func PrintTree(root *Branch) {
+	if root.Count == 0 {
+		fmt.Println("Empty result.")
+		return
+	}
+	if root.Name != "" {
+		printBranch(root, 0)
+		return
+	}
+	for _, c := range root.Children {
+		printBranch(c, 0)
+	}
+}
+
+// printBranch is used to show a node and its children with specified depth.
+func printBranch(br *Branch, depth int) {
+	indent := strings.Repeat("  ", depth)
+	switch {
+	case len(br.Children) == 0:
+		fmt.Printf("%s%s\n", indent, br.Name)
+	case br.IsNote:
+		fmt.Printf("%s%s/ (%d) *\n", indent, br.Name, br.Count)
+	default:
+		fmt.Printf("%s%s/ (%d)\n", indent, br.Name, br.Count)
+	}
+	for _, c := range br.Children {
+		printBranch(c, depth+1)
+	}
+}
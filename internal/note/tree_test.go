@@ -0,0 +1,92 @@
+package note
+
+import (
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestIsSubtree(t *testing.T) {
+	tests := []struct {
+		word string
+		want bool
+	}{
+		{"prod.db.*", true},
+		{"prod/db/*", true},
+		{"*", false},
+		{"./*", true},
+		{"prod*", false},
+		{"prod.db", false},
+	}
+	for _, tt := range tests {
+		if got := isSubtree(tt.word); got != tt.want {
+			t.Errorf("isSubtree(%q) = %v, want %v", tt.word, got, tt.want)
+		}
+	}
+}
+
+// flatten is used to list nodes of the tree like 'prod/db 2' in depth-first order,
+// where a node which is also a note ends with '*'.
+func flatten(br *Branch, path string, lines []string) []string {
+	for _, c := range br.Children {
+		line := strings.TrimPrefix(path+"/"+c.Name, "/") + " " + strconv.Itoa(c.Count)
+		if c.IsNote {
+			line += " *"
+		}
+		lines = flatten(c, strings.TrimPrefix(path+"/"+c.Name, "/"), append(lines, line))
+	}
+	return lines
+}
+
+func TestTree(t *testing.T) {
+	b := testNotebook(t)
+	notes := make([]Note, 0)
+	for _, key := range []string{"prod.db.mysql", "Prod/DB/redis", "prod.web", "prod", "dev..x"} {
+		notes = append(notes, mustNote(t, key, "v"))
+	}
+	if err := b.Write(notes); err != nil {
+		t.Fatalf("write error: %v", err)
+	}
+
+	tests := []struct {
+		prefix string
+		name   string
+		count  int
+		want   []string
+	}{
+		{"", "", 5, []string{"dev 1", "dev/x 1 *", "prod 4 *", "prod/db 2", "prod/db/mysql 1 *",
+			"prod/db/redis 1 *", "prod/web 1 *"}},
+		{"prod.db", "prod.db", 2, []string{"mysql 1 *", "redis 1 *"}},
+		{"prod/db/*", "prod.db", 2, []string{"mysql 1 *", "redis 1 *"}},
+		{"test", "test", 0, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.prefix, func(t *testing.T) {
+			root, err := b.Tree(tt.prefix)
+			if err != nil {
+				t.Fatalf("tree error: %v", err)
+			}
+			if root.Name != tt.name || root.Count != tt.count {
+				t.Errorf("root got %q %d, want %q %d", root.Name, root.Count, tt.name, tt.count)
+			}
+			if got := flatten(root, "", nil); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("tree got %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+	searches := []struct {
+		keyword string
+		want    []string
+	}{
+		{"prod.db.*", []string{"prod.db.mysql", "Prod/DB/redis"}},
+		{"my prod/*", []string{"prod.db.mysql"}},
+		{"prod.* dev.*", []string{}},
+	}
+	for _, s := range searches {
+		if got := foundKeys(t, b, s.keyword); !reflect.DeepEqual(got, s.want) {
+			t.Errorf("find %q got %q, want %q", s.keyword, got, s.want)
+		}
+	}
+}
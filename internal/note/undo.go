@@ -0,0 +1,200 @@
+package note
+
+import (
+	"encoding/json"
+	"find/internal/logs"
+	"fmt"
+	"os"
+	"sort"
+	"time"
+)
+
+// undoSize is how many orders can be undone one by one.
+const undoSize = 20
+
+// step is an order of user which changed notes, along with notes before and after it,
+// so that it can be undone.
+type step struct {
+	Action  string       `json:"action"`
+	Time    time.Time    `json:"time"`
+	Changes []stepChange `json:"changes"`
+}
+
+// stepChange is how a note was changed by a step, where a nil before means the note was added,
+// and a nil after means the note was deleted.
+type stepChange struct {
+	Key    string `json:"key"`
+	Before *Note  `json:"before,omitempty"`
+	After  *Note  `json:"after,omitempty"`
+}
+
+// undoPath is used to get the path of undo steps, which is a file beside the store
+// where each step is a line of json, the latest last.
+func (b *Notebook) undoPath() string {
+	return b.storePath() + ".undo"
+}
+
+// loadUndo is used to read steps which can be undone, the latest last.
+// It must be called with the lock taken.
+func (b *Notebook) loadUndo() ([]step, error) {
+	path := b.undoPath()
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		return nil, nil
+	}
+	lines, _, err := readSealedLines(path, sealedUndo, b.sealer)
+	if err != nil {
+		return nil, err
+	}
+	steps := make([]step, 0, len(lines))
+	for i, line := range lines {
+		var s step
+		err = json.Unmarshal([]byte(line), &s)
+		if err != nil {
+			logs.Warn("note: skip line %d of %s: %s", i+1, path, err.Error())
+			continue
+		}
+		steps = append(steps, s)
+	}
+	return steps, nil
+}
+
+// saveUndo is used to replace all steps which can be undone atomically, keeping undoSize at most.
+// It must be called with the lock taken.
+func (b *Notebook) saveUndo(steps []step) error {
+	if len(steps) > undoSize {
+		steps = steps[len(steps)-undoSize:]
+	}
+	lines := make([]string, 0, len(steps))
+	for _, s := range steps {
+		data, err := json.Marshal(s)
+		if err != nil {
+			return fmt.Errorf("json marshal of %s error: %v", s.Action, err)
+		}
+		lines = append(lines, string(data))
+	}
+	err := writeSealedLines(b.undoPath(), sealedUndo, lines, b.sealer)
+	if err != nil {
+		return fmt.Errorf("write %d steps to %s error: %v", len(steps), b.undoPath(), err)
+	}
+	return nil
+}
+
+// pushUndo is used to record an order of user which changed notes from befores to afters,
+// where befores are keyed by every key changed, and deleted notes are absent from afters.
+// It only logs the error since notes are changed anyway. It must be called with the lock taken.
+func (b *Notebook) pushUndo(action string, befores map[string]*Note, afters []Note) {
+	s := step{Action: action, Time: time.Now(), Changes: make([]stepChange, 0, len(befores))}
+	for key, before := range befores {
+		c := stepChange{Key: key, Before: before}
+		for i := range afters {
+			if afters[i].Key == key {
+				c.After = &afters[i]
+			}
+		}
+		s.Changes = append(s.Changes, c)
+	}
+	if len(s.Changes) == 0 {
+		return
+	}
+	sort.Slice(s.Changes, func(i, j int) bool {
+		return s.Changes[i].Key < s.Changes[j].Key
+	})
+
+	steps, err := b.loadUndo()
+	if err == nil {
+		err = b.saveUndo(append(steps, s))
+	}
+	if err != nil {
+		logs.Warn("note: record %s for undo error: %s", action, err.Error())
+	}
+}
+
+// sameNote is used to judge if a note is still what a step left,
+// returning true if both are nil or they have the same content and tags.
+// Revisions are not compared, since undoing a later step puts the content back as a new revision.
+func sameNote(current, after *Note) bool {
+	if current == nil || after == nil {
+		return current == nil && after == nil
+	}
+	return current.ID == after.ID && current.Val == after.Val && sameTags(current.Tags, after.Tags)
+}
+
+// Undo is used to reverse the latest order of user which changed notes and hasn't been undone,
+// which is refused if any of the notes was changed again after it,
+// returning the order undone and error.
+// Added notes are moved into trash, and deleted notes are taken out of trash.
+func (b *Notebook) Undo() (string, error) {
+	if err := b.available(); err != nil {
+		return "", err
+	}
+	unlock, err := b.lock()
+	if err != nil {
+		return "", err
+	}
+	defer unlock()
+
+	steps, err := b.loadUndo()
+	if err != nil {
+		return "", err
+	}
+	if len(steps) == 0 {
+		return "", fmt.Errorf("nothing to undo")
+	}
+	last := steps[len(steps)-1]
+
+	added := make([]Note, 0)
+	restored := make([]Note, 0)
+	deleted := make([]string, 0)
+	for _, c := range last.Changes {
+		current, err := b.store.Get(c.Key)
+		if err != nil {
+			return "", fmt.Errorf("get %s error: %v", c.Key, err)
+		}
+		if !sameNote(current, c.After) {
+			return "", fmt.Errorf("%s was changed again after '%s', please fix it by mod or revert", c.Key, last.Action)
+		}
+		switch {
+		case c.Before == nil:
+			added = append(added, *current)
+		case c.After == nil:
+			restored = append(restored, *c.Before)
+			deleted = append(deleted, c.Key)
+		default:
+			restored = append(restored, *c.Before)
+		}
+	}
+
+	if len(added) > 0 {
+		keys := make([]string, 0, len(added))
+		for _, n := range added {
+			keys = append(keys, n.Key)
+		}
+		err = b.store.Delete(keys...)
+		if err != nil {
+			return "", fmt.Errorf("delete notes error: %v", err)
+		}
+		err = b.trash(added)
+		if err != nil {
+			logs.Error("move %d notes into trash error: %s\n", len(added), err.Error())
+		}
+		b.checkAsync()
+	}
+	if len(deleted) > 0 {
+		_, err = b.untrash(deleted...)
+		if err != nil {
+			logs.Warn("note: take %d notes out of trash error: %s", len(deleted), err.Error())
+		}
+	}
+	if len(restored) > 0 {
+		err = b.putBack(restored)
+		if err != nil {
+			return "", err
+		}
+	}
+
+	err = b.saveUndo(steps[:len(steps)-1])
+	if err != nil {
+		return "", err
+	}
+	return last.Action, nil
+}
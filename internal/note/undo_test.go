@@ -0,0 +1,97 @@
+package note
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// currentValues is used to get values of all notes by their keys.
+func currentValues(t *testing.T, b *Notebook) map[string]string {
+	notes, err := b.store.Query(func(Note) bool { return true })
+	if err != nil {
+		t.Fatalf("query error: %v", err)
+	}
+	got := make(map[string]string, len(notes))
+	for _, n := range notes {
+		got[n.Key] = n.Val
+	}
+	return got
+}
+
+// trashedKeys is used to get keys of notes in trash, the earliest deleted first.
+func trashedKeys(t *testing.T, b *Notebook) []string {
+	trashed, err := b.Trash()
+	if err != nil {
+		t.Fatalf("trash error: %v", err)
+	}
+	keys := make([]string, 0, len(trashed))
+	for _, n := range trashed {
+		keys = append(keys, n.Key)
+	}
+	return keys
+}
+
+func TestUndo(t *testing.T) {
+	b := testNotebook(t)
+	steps := []struct {
+		name string
+		do   func() error
+	}{
+		{"add", func() error { return b.Write([]Note{mustNote(t, "a", "1"), mustNote(t, "b", "2")}) }},
+		{"mod", func() error { return b.Modify(mustNote(t, "a", "3"), SourceUser) }},
+		{"del", func() error { return b.Delete("b", false, true) }},
+	}
+	wants := []map[string]string{{}, {"a": "1", "b": "2"}, {"a": "3", "b": "2"}, {"a": "3"}}
+	for i, s := range steps {
+		if err := s.do(); err != nil {
+			t.Fatalf("%s error: %v", s.name, err)
+		}
+		if got := currentValues(t, b); !reflect.DeepEqual(got, wants[i+1]) {
+			t.Fatalf("notes after %s are %v, want %v", s.name, got, wants[i+1])
+		}
+	}
+	// Changes by reminder can't be undone, so they don't make a step.
+	if err := b.Modify(mustNote(t, "c", "4"), SourceReminder); err != nil {
+		t.Fatalf("modify by reminder error: %v", err)
+	}
+
+	for i := len(steps) - 1; i >= 0; i-- {
+		action, err := b.Undo()
+		if err != nil {
+			t.Fatalf("undo %s error: %v", steps[i].name, err)
+		}
+		if !strings.HasPrefix(action, steps[i].name+" ") {
+			t.Errorf("undo got %q, want %s undone", action, steps[i].name)
+		}
+		want := map[string]string{"c": "4"}
+		for k, v := range wants[i] {
+			want[k] = v
+		}
+		if got := currentValues(t, b); !reflect.DeepEqual(got, want) {
+			t.Errorf("notes after undoing %s are %v, want %v", steps[i].name, got, want)
+		}
+	}
+	if got, want := trashedKeys(t, b), []string{"a", "b"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("trash after undoing add got %q, want %q", got, want)
+	}
+	if _, err := b.Undo(); err == nil {
+		t.Errorf("undo with nothing left got no error")
+	}
+}
+
+func TestUndoRefusedAfterChangedAgain(t *testing.T) {
+	b := testNotebook(t)
+	if err := b.Write([]Note{mustNote(t, "a", "1")}); err != nil {
+		t.Fatalf("write error: %v", err)
+	}
+	if err := b.Modify(mustNote(t, "a", "2"), SourceReminder); err != nil {
+		t.Fatalf("modify error: %v", err)
+	}
+	if _, err := b.Undo(); err == nil {
+		t.Errorf("undo of a note changed again got no error")
+	}
+	if got, want := currentValues(t, b), map[string]string{"a": "2"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("notes after undo refused are %v, want %v", got, want)
+	}
+}
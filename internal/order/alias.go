@@ -0,0 +1,204 @@
+package order
+
+import (
+	"find/internal/config"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// maxExpansions is how deep an alias can expand into other aliases, beyond which it's taken as a cycle.
+const maxExpansions = 10
+
+// LookupAlias is used to get the alias of specified name from 'aliases' of FIND.yml,
+// returning its lines and whether it's found. An alias named like an order is never found, since orders win.
+func LookupAlias(name string) ([]string, bool) {
+	if _, ok := Lookup(name); ok {
+		return nil, false
+	}
+	alias, ok := config.Conf.Aliases[name]
+	if !ok || len(alias) == 0 {
+		return nil, false
+	}
+	return alias, true
+}
+
+// Aliases is used to get names of all aliases, sorted.
+func Aliases() []string {
+	var names []string
+	for _, name := range config.AliasNames() {
+		if _, ok := LookupAlias(name); ok {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// DefineAlias is used to define an alias of order lines at runtime, which is saved into FIND.yml.
+// The name must be a single word which is neither an order nor a flag.
+func DefineAlias(name string, lines []string) error {
+	if name == "" || strings.ContainsAny(name, " \t\"'\\$") || strings.HasPrefix(name, "-") {
+		return fmt.Errorf("invalid alias name %s", name)
+	}
+	if _, ok := Lookup(name); ok {
+		return fmt.Errorf("%s is an order", name)
+	}
+	if len(lines) == 0 {
+		return fmt.Errorf("missing orders of alias %s", name)
+	}
+	return config.SetAlias(name, lines)
+}
+
+// RemoveAlias is used to remove an alias, which is saved into FIND.yml.
+func RemoveAlias(name string) error {
+	return config.RemoveAlias(name)
+}
+
+// SplitMacro is used to split a definition like 'weather -a $1; find remind@' into order lines by ';',
+// where '\;' is kept as ';' in a line, returning non-blank lines.
+func SplitMacro(definition string) []string {
+	var lines []string
+	var b strings.Builder
+	for i := 0; i < len(definition); i++ {
+		switch {
+		case definition[i] == '\\' && i+1 < len(definition) && definition[i+1] == ';':
+			b.WriteByte(';')
+			i++
+		case definition[i] == ';':
+			lines = append(lines, b.String())
+			b.Reset()
+		default:
+			b.WriteByte(definition[i])
+		}
+	}
+	lines = append(lines, b.String())
+	picked := lines[:0]
+	for _, line := range lines {
+		if line = strings.TrimSpace(line); line != "" {
+			picked = append(picked, line)
+		}
+	}
+	return picked
+}
+
+// PrintAliases is used to show aliases of specified names to the user, each line of a macro on its own.
+func PrintAliases(names []string) {
+	for _, name := range names {
+		lines, _ := LookupAlias(name)
+		if len(lines) == 1 {
+			fmt.Printf("%s = %s\n", name, lines[0])
+			continue
+		}
+		fmt.Printf("%s =\n", name)
+		for _, line := range lines {
+			fmt.Printf("    %s\n", line)
+		}
+	}
+}
+
+// Expand is used to expand input if it starts with an alias, into order lines to run in sequence,
+// where $1, $2... of each line are replaced by words after the alias, $@ by all of them as typed, and $$ by '$'.
+// If no line has a placeholder, the words are appended to the last line. Lines starting with an alias expand too.
+// It returns the lines, which is input itself if it doesn't start with an alias, and error.
+func Expand(input string) ([]string, error) {
+	return expand(input, 0)
+}
+
+// ExpandArgs is used to expand arguments of the process like Expand, whose first one must be an alias,
+// and each of the others is a word as it is, since the shell has split them. $@ is those words joined by a space.
+// It returns the lines and error.
+func ExpandArgs(args []string) ([]string, error) {
+	if len(args) == 0 {
+		return nil, fmt.Errorf("missing alias")
+	}
+	lines, ok := LookupAlias(args[0])
+	if !ok {
+		return nil, fmt.Errorf("alias %s not found", args[0])
+	}
+	return expandAlias(args[0], lines, args[1:], strings.Join(args[1:], " "), 0)
+}
+
+// expand is used to expand input, which is an expansion of depth aliases.
+func expand(input string, depth int) ([]string, error) {
+	pos := skipSpaces(input, 0)
+	end := wordEnd(input, pos)
+	name := input[pos:end]
+	lines, ok := LookupAlias(name)
+	if !ok {
+		return []string{input}, nil
+	}
+	args, err := lexAll(input, end)
+	if err != nil {
+		return nil, err
+	}
+	return expandAlias(name, lines, args, strings.TrimSpace(input[end:]), depth)
+}
+
+// expandAlias is used to expand lines of the alias named name by words after it and the rest as typed,
+// which is an expansion of depth aliases.
+func expandAlias(name string, lines, args []string, rest string, depth int) ([]string, error) {
+	if depth == maxExpansions {
+		return nil, fmt.Errorf("alias %s expands more than %d times, which may be a cycle", name, maxExpansions)
+	}
+
+	expanded := make([]string, 0, len(lines))
+	placed := false
+	for _, line := range lines {
+		line, used, err := substitute(line, args, rest)
+		if err != nil {
+			return nil, fmt.Errorf("alias %s %v", name, err)
+		}
+		placed = placed || used
+		expanded = append(expanded, line)
+	}
+	if !placed && rest != "" {
+		expanded[len(expanded)-1] += " " + rest
+	}
+
+	var all []string
+	for _, line := range expanded {
+		more, err := expand(line, depth+1)
+		if err != nil {
+			return nil, err
+		}
+		all = append(all, more...)
+	}
+	return all, nil
+}
+
+// substitute is used to replace placeholders of line by words and rest,
+// returning the line, whether there's any placeholder, and error if a word is missing.
+func substitute(line string, words []string, rest string) (string, bool, error) {
+	var b strings.Builder
+	used := false
+	for i := 0; i < len(line); i++ {
+		if line[i] != '$' || i+1 == len(line) {
+			b.WriteByte(line[i])
+			continue
+		}
+		switch next := line[i+1]; {
+		case next == '$':
+			b.WriteByte('$')
+			i++
+		case next == '@':
+			b.WriteString(rest)
+			used = true
+			i++
+		case next >= '1' && next <= '9':
+			end := i + 1
+			for end < len(line) && line[end] >= '0' && line[end] <= '9' {
+				end++
+			}
+			n, _ := strconv.Atoi(line[i+1 : end])
+			if n > len(words) {
+				return "", false, fmt.Errorf("needs at least %d words", n)
+			}
+			b.WriteString(words[n-1])
+			used = true
+			i = end - 1
+		default:
+			b.WriteByte('$')
+		}
+	}
+	return b.String(), used, nil
+}
@@ -0,0 +1,120 @@
+package order
+
+import (
+	"find/internal/config"
+	"reflect"
+	"testing"
+)
+
+// withAliases is used to take aliases as those of FIND.yml until the test ends.
+func withAliases(t *testing.T, aliases map[string]config.Alias) {
+	old := config.Conf.Aliases
+	config.Conf.Aliases = aliases
+	t.Cleanup(func() { config.Conf.Aliases = old })
+}
+
+// testAliases are aliases of tests, along with one named like an order which is never found.
+var testAliases = map[string]config.Alias{
+	"wx":      {"weather -a $1"},
+	"prod":    {"find prod db $@"},
+	"plain":   {"find x"},
+	"morning": {"wx 北京市昌平区", "find remind@"},
+	"second":  {"del $2 $1 $$1"},
+	"loop":    {"loop again"},
+	"del":     {"find del"},
+}
+
+func TestExpand(t *testing.T) {
+	withAliases(t, testAliases)
+	tests := []struct {
+		input string
+		want  []string
+	}{
+		{"wx 兰州市", []string{"weather -a 兰州市"}},
+		{"wx 全 公", []string{"weather -a 全"}},
+		{`wx "兰州 市"`, []string{"weather -a 兰州 市"}},
+		{"  prod -l 'a b'", []string{"find prod db -l 'a b'"}},
+		{"prod", []string{"find prod db "}},
+		{"plain -l", []string{"find x -l"}},
+		{"morning", []string{"weather -a 北京市昌平区", "find remind@"}},
+		{"second a b", []string{"del b a $1"}},
+		{"del -f k", []string{"del -f k"}},
+		{"unknown 兰州", []string{"unknown 兰州"}},
+		{"", []string{""}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			got, err := Expand(tt.input)
+			if err != nil {
+				t.Fatalf("expand error: %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("expand got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestExpandArgs(t *testing.T) {
+	withAliases(t, testAliases)
+	tests := []struct {
+		args []string
+		want []string
+	}{
+		{[]string{"wx", "兰州市"}, []string{"weather -a 兰州市"}},
+		{[]string{"wx", "兰州 市"}, []string{"weather -a 兰州 市"}},
+		{[]string{"prod", "-l", "a b"}, []string{"find prod db -l a b"}},
+		{[]string{"morning"}, []string{"weather -a 北京市昌平区", "find remind@"}},
+	}
+	for _, tt := range tests {
+		got, err := ExpandArgs(tt.args)
+		if err != nil {
+			t.Fatalf("expand %q error: %v", tt.args, err)
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("expand %q got %q, want %q", tt.args, got, tt.want)
+		}
+	}
+}
+
+func TestExpandError(t *testing.T) {
+	withAliases(t, testAliases)
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{"loop", "alias loop expands more than 10 times, which may be a cycle"},
+		{"second a", "alias second needs at least 2 words"},
+		{"wx '兰州", "unterminated quote ' at column 4"},
+	}
+	for _, tt := range tests {
+		_, err := Expand(tt.input)
+		if err == nil || err.Error() != tt.want {
+			t.Errorf("expand %q got error %v, want %q", tt.input, err, tt.want)
+		}
+	}
+	if _, err := ExpandArgs([]string{"del", "k"}); err == nil {
+		t.Errorf("expand args of an order got no error")
+	}
+}
+
+func TestSplitMacro(t *testing.T) {
+	tests := []struct {
+		definition string
+		want       []string
+	}{
+		{"weather -a $1; find remind@", []string{"weather -a $1", "find remind@"}},
+		{`add k:a\;b ;; `, []string{"add k:a;b"}},
+		{"find 北京；昌平", []string{"find 北京；昌平"}},
+		{" ; ", nil},
+	}
+	for _, tt := range tests {
+		got := SplitMacro(tt.definition)
+		if len(got) == 0 && len(tt.want) == 0 {
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("split %q got %q, want %q", tt.definition, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,82 @@
+package order
+
+import (
+	"sort"
+	"strings"
+)
+
+// ArgCompleter is used to complete the argument of the order typed so far as prefix, returning candidates replacing it.
+type ArgCompleter func(o Order, prefix string) []string
+
+// Complete is used to complete line as typed before the cursor, which is the name of an order or alias at first,
+// then a flag of the order if the word starts with '-', or the argument completed by args once flags end.
+// It returns where the completed text starts in line and candidates replacing it, which is empty if nothing fits.
+func Complete(line string, args ArgCompleter) (int, []string) {
+	pos := skipSpaces(line, 0)
+	end := wordEnd(line, pos)
+	if end == len(line) {
+		names := append(Names(), Aliases()...)
+		sort.Strings(names)
+		return pos, withPrefix(names, line[pos:], " ")
+	}
+	o, ok := Lookup(line[pos:end])
+	if !ok {
+		return 0, nil
+	}
+
+	cmd := Command{Order: o.Name(), flags: make(map[string]string)}
+	in := &lineWords{input: line, at: end}
+	for {
+		word, ok, err := in.next(true)
+		if err != nil {
+			return 0, nil
+		}
+		if !ok {
+			break
+		}
+		if in.at == len(line) {
+			return in.pos(), flagForms(o.Flags(), word)
+		}
+		if word == endOfFlags {
+			break
+		}
+		if word == "-" {
+			in.back()
+			break
+		}
+		if strings.HasPrefix(word, endOfFlags) {
+			err = cmd.long(o.Flags(), in, word)
+		} else {
+			err = cmd.short(o.Flags(), in, word)
+		}
+		if err != nil {
+			return 0, nil
+		}
+	}
+	pos = skipSpaces(line, in.at)
+	return pos, args(o, line[pos:])
+}
+
+// flagForms is used to get short and long forms of flags starting with word, each followed by a space.
+func flagForms(flags []Flag, word string) []string {
+	forms := make([]string, 0, len(flags)*2)
+	for _, f := range flags {
+		if f.Short != "" {
+			forms = append(forms, "-"+f.Short)
+		}
+		forms = append(forms, endOfFlags+f.Name)
+	}
+	sort.Strings(forms)
+	return withPrefix(forms, word, " ")
+}
+
+// withPrefix is used to pick candidates starting with prefix, returning them each followed by suffix.
+func withPrefix(candidates []string, prefix, suffix string) []string {
+	var picked []string
+	for _, c := range candidates {
+		if strings.HasPrefix(c, prefix) {
+			picked = append(picked, c+suffix)
+		}
+	}
+	return picked
+}
@@ -1,7 +1,11 @@
 // Package order gathers all supported orders and implements concerned methods.
 package order
 
-import "strings"
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
 
 const (
 	Find    = "find"
@@ -10,50 +14,114 @@ const (
 	Modify  = "mod"
 	Exit    = "exit"
 	Weather = "weather"
+	History = "history"
+	Revert  = "revert"
+	Undo    = "undo"
+	Trash   = "trash"
+	Tags    = "tags"
+	Tag     = "tag"
+	Use     = "use"
+	Ls      = "ls"
+	Encrypt = "encrypt"
+	Show    = "show"
+	OTP     = "otp"
+	Gen     = "gen"
+	Get     = "get"
+	Help    = "help"
+	Alias   = "alias"
 )
 
-// orders is a string slice persist all of order.
-var orders = []string{
-	Find,
-	Add,
-	Delete,
-	Modify,
-	Exit,
-	Weather,
-}
+// Sub orders of trash.
+const (
+	TrashList    = "list"
+	TrashRestore = "restore"
+	TrashPurge   = "purge"
+)
 
-// Order is used to parse order from user's input,
-// returning the order which user want to execute.
-func Order(input string) string {
-	for _, order := range orders {
-		if strings.HasPrefix(input, order) {
-			return order
-		}
+// Sub orders of encrypt.
+const (
+	EncryptOn         = "on"
+	EncryptOff        = "off"
+	EncryptPassphrase = "passphrase"
+)
+
+// Names of flags, which are the long forms.
+const (
+	FlagForce       = "force"
+	FlagAll         = "all"
+	FlagLong        = "long"
+	FlagNotebooks   = "notebooks"
+	FlagValue       = "value"
+	FlagEverywhere  = "everywhere"
+	FlagRegex       = "regex"
+	FlagFuzzy       = "fuzzy"
+	FlagCopy        = "copy"
+	FlagPrint       = "print"
+	FlagUnambiguous = "unambiguous"
+	FlagLength      = "length"
+	FlagClasses     = "classes"
+	FlagWords       = "words"
+	FlagDelete      = "delete"
+)
+
+// Heredoc is used to check if the content of a note is a heredoc like '<<EOF',
+// which means the real content is on following lines until a line of 'EOF',
+// returning the delimiter and check result.
+func Heredoc(content string) (string, bool) {
+	if !strings.HasPrefix(content, "<<") {
+		return "", false
 	}
-	return ""
+	delim := strings.TrimSpace(strings.TrimPrefix(content, "<<"))
+	if delim == "" || strings.ContainsAny(delim, " \t") {
+		return "", false
+	}
+	return delim, true
 }
 
-// Param is used to parse param from user's input,
-// returning the param which order execution need.
-func Param(input string) string {
-	return strings.TrimSpace(strings.TrimPrefix(input, Order(input)))
+// Revisions is used to parse at most max revision numbers at the end of words like 'key 3 5',
+// returning the numbers in order and the key, which is the other words joined by a space.
+func Revisions(words []string, max int) ([]int, string) {
+	revs := make([]int, 0, max)
+	for len(words) > 1 && len(revs) < max {
+		rev, err := strconv.Atoi(words[len(words)-1])
+		if err != nil || rev <= 0 {
+			break
+		}
+		revs = append([]int{rev}, revs...)
+		words = words[:len(words)-1]
+	}
+	return revs, strings.Join(words, " ")
 }
 
-// Fast is used to check if user want to execute the order rapidly,
-// returning check result and handled param.
-func Fast(param string) (bool, string) {
-	if strings.HasPrefix(param, "-f ") || strings.Contains(param, " -f ") {
-		return true, strings.TrimSpace(strings.ReplaceAll(param, "-f", ""))
+// Sub is used to parse sub order from words like 'restore key',
+// returning the sub order and the other words joined by a space.
+func Sub(words []string) (string, string) {
+	if len(words) == 0 {
+		return "", ""
 	}
-	return false, param
+	return words[0], strings.Join(words[1:], " ")
 }
 
-// All is used to check if user want to influence all concerned notes,
-// sometimes it means a fuzzy match of the key (e.g. del -a),
-// returning check result and handled param.
-func All(param string) (bool, string) {
-	if strings.HasPrefix(param, "-a ") || strings.Contains(param, " -a ") {
-		return true, strings.TrimSpace(strings.ReplaceAll(param, "-a", ""))
+// Retag is used to parse param of tag like 'keyword #tag: +new -old',
+// returning the keyword to search, tags to add, tags to remove and error.
+func Retag(param string) (string, []string, []string, error) {
+	i := strings.LastIndex(param, ":")
+	if i == -1 {
+		return "", nil, nil, fmt.Errorf("missing ':' between keyword and tags")
+	}
+	var added, removed []string
+	for _, word := range strings.Fields(param[i+1:]) {
+		switch {
+		case strings.HasPrefix(word, "+") && len(word) > 1:
+			added = append(added, word[1:])
+		case strings.HasPrefix(word, "-") && len(word) > 1:
+			removed = append(removed, word[1:])
+		default:
+			return "", nil, nil, fmt.Errorf("%s should be like +tag or -tag", word)
+		}
+	}
+	if len(added) == 0 && len(removed) == 0 {
+		return "", nil, nil, fmt.Errorf("missing tags to add or remove")
 	}
-	return false, param
+	return strings.TrimSpace(param[:i]), added, removed, nil
 }
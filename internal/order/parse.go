@@ -0,0 +1,299 @@
+package order
+
+import (
+	"find/internal/suggest"
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// endOfFlags ends flags of an order, so that following text is taken as arguments even if it starts with '-'.
+const endOfFlags = "--"
+
+// Flag is an option of an order like '-n 24' or '--length 24'.
+type Flag struct {
+	// Name is the long form like 'length', and the key of the flag in Command.
+	Name string
+	// Short is the short form like 'n', which may be empty.
+	Short string
+	// Value is the name of the value like 'n' if the flag takes one, or empty if it's a switch.
+	Value string
+	Usage string
+}
+
+// Command is an order parsed from user's input along with its flags and arguments.
+type Command struct {
+	// Order is the name of the order, even if it's typed as an alias.
+	Order string
+	// Args is the words after flags without quotes or escapes, which is empty if the order takes text.
+	Args []string
+	// Text is the text after flags as it is typed, like content of a note or a query.
+	Text  string
+	flags map[string]string
+}
+
+// Arg is used to get the words of arguments as one joined by a space, like a key typed with or without quotes.
+func (c Command) Arg() string {
+	return strings.Join(c.Args, " ")
+}
+
+// Bool is used to check if the switch of specified name is given.
+func (c Command) Bool(name string) bool {
+	_, ok := c.flags[name]
+	return ok
+}
+
+// Value is used to get the value of the flag of specified name, returning the value and whether it's given.
+func (c Command) Value(name string) (string, bool) {
+	v, ok := c.flags[name]
+	return v, ok
+}
+
+// Error is an error of user's input along with where it happens.
+type Error struct {
+	// Pos is the byte offset in the input.
+	Pos int
+	Msg string
+}
+
+// Error is used to describe the error along with the column where it happens.
+func (e *Error) Error() string {
+	return fmt.Sprintf("%s at column %d", e.Msg, e.Pos+1)
+}
+
+// suggestions is the most orders suggested for an unknown one.
+const suggestions = 3
+
+// UnknownError is an order which isn't registered, along with orders which user may mean.
+type UnknownError struct {
+	Name        string
+	Suggestions []string
+}
+
+// Error is used to describe the unknown order.
+func (e *UnknownError) Error() string {
+	return fmt.Sprintf("unknown order %s", e.Name)
+}
+
+// Unknown is used to get the error of an unknown order along with orders or aliases which user may mean.
+func Unknown(name string) *UnknownError {
+	return &UnknownError{Name: name, Suggestions: suggest.Closest(name, append(Names(), Aliases()...), suggestions)}
+}
+
+// Parse is used to parse user's input like 'del -f key' into a command, whose order must be typed exactly as
+// the name or an alias of a registered one, or UnknownError is returned,
+// and whose flags come before arguments, in short form like '-f' and '-fa', or long form like '--force'.
+// A flag taking a value is like '-n 24', '-n24', '--length 24' or '--length=24', and values can be quoted.
+// Flags end at the first word not starting with '-', or at '--', and the rest are words lexed like values of flags,
+// unless the order takes text.
+// It returns the command, which has no order if input is blank, and error.
+func Parse(input string) (Command, error) {
+	pos := skipSpaces(input, 0)
+	end := wordEnd(input, pos)
+	return parse(input[pos:end], &lineWords{input: input, at: end})
+}
+
+// ParseArgs is used to parse arguments of the process like ['del', '-f', 'my key'] into a command like Parse,
+// where each argument is a word as it is, since the shell has taken its quotes and escapes,
+// and the text of an order taking text is the arguments after flags joined by a space.
+func ParseArgs(args []string) (Command, error) {
+	if len(args) == 0 {
+		return Command{}, nil
+	}
+	return parse(args[0], &argWords{args: args, at: 1})
+}
+
+// parse is used to parse the command of the order named name, whose flags and arguments are read from in.
+func parse(name string, in words) (Command, error) {
+	if name == "" {
+		return Command{}, nil
+	}
+	o, ok := Lookup(name)
+	if !ok {
+		return Command{}, Unknown(name)
+	}
+	spec := o.Flags()
+
+	cmd := Command{Order: o.Name(), flags: make(map[string]string)}
+	for {
+		word, ok, err := in.next(true)
+		if err != nil {
+			return Command{}, err
+		}
+		if !ok || word == endOfFlags {
+			break
+		}
+		if word == "-" {
+			in.back()
+			break
+		}
+		if strings.HasPrefix(word, endOfFlags) {
+			err = cmd.long(spec, in, word)
+		} else {
+			err = cmd.short(spec, in, word)
+		}
+		if err != nil {
+			return Command{}, err
+		}
+	}
+	text, args, err := in.rest(o.Text())
+	if err != nil {
+		return Command{}, err
+	}
+	cmd.Text, cmd.Args = text, args
+	return cmd, nil
+}
+
+// long is used to parse a flag in long form like '--length=24' just read as word, whose value may be the next word,
+// returning error.
+func (c *Command) long(spec []Flag, in words, word string) error {
+	pos := in.pos()
+	name := strings.TrimPrefix(word, endOfFlags)
+	value, hasValue := "", false
+	if i := strings.Index(name, "="); i != -1 {
+		name, value, hasValue = name[:i], name[i+1:], true
+	}
+	flag, ok := findFlag(spec, func(f Flag) bool { return f.Name == name })
+	if !ok {
+		return &Error{Pos: pos, Msg: fmt.Sprintf("unknown flag --%s of %s", name, c.Order)}
+	}
+	switch {
+	case flag.Value == "" && hasValue:
+		return &Error{Pos: pos, Msg: fmt.Sprintf("flag --%s of %s takes no value", name, c.Order)}
+	case flag.Value != "" && !hasValue:
+		var err error
+		value, err = c.value(in, "--"+name)
+		if err != nil {
+			return err
+		}
+	}
+	c.flags[flag.Name] = value
+	return nil
+}
+
+// short is used to parse flags in short form like '-fa' or '-n24' just read as word, where the value of the last one
+// may be the next word, returning error.
+func (c *Command) short(spec []Flag, in words, word string) error {
+	pos := in.pos()
+	shorts := []rune(strings.TrimPrefix(word, "-"))
+	for i, r := range shorts {
+		flag, ok := findFlag(spec, func(f Flag) bool { return f.Short == string(r) })
+		if !ok {
+			return &Error{Pos: pos, Msg: fmt.Sprintf("unknown flag -%c of %s", r, c.Order)}
+		}
+		if flag.Value == "" {
+			c.flags[flag.Name] = ""
+			continue
+		}
+		if rest := string(shorts[i+1:]); rest != "" {
+			c.flags[flag.Name] = rest
+			return nil
+		}
+		value, err := c.value(in, "-"+string(r))
+		if err != nil {
+			return err
+		}
+		c.flags[flag.Name] = value
+		return nil
+	}
+	return nil
+}
+
+// value is used to read the value of a flag from the next word, returning the value and error.
+func (c *Command) value(in words, flag string) (string, error) {
+	value, ok, err := in.next(false)
+	if err != nil {
+		return "", err
+	}
+	if !ok {
+		return "", &Error{Pos: in.pos(), Msg: fmt.Sprintf("flag %s of %s needs a value", flag, c.Order)}
+	}
+	return value, nil
+}
+
+// findFlag is used to pick the flag matching f from spec, returning the flag and whether it's found.
+func findFlag(spec []Flag, f func(Flag) bool) (Flag, bool) {
+	for _, flag := range spec {
+		if f(flag) {
+			return flag, true
+		}
+	}
+	return Flag{}, false
+}
+
+// skipSpaces is used to get the position of the first non-space character from pos.
+func skipSpaces(input string, pos int) int {
+	for pos < len(input) {
+		r, size := utf8.DecodeRuneInString(input[pos:])
+		if !unicode.IsSpace(r) {
+			break
+		}
+		pos += size
+	}
+	return pos
+}
+
+// wordEnd is used to get where the word from pos ends as it is typed, which is the first space after it.
+func wordEnd(input string, pos int) int {
+	for pos < len(input) {
+		r, size := utf8.DecodeRuneInString(input[pos:])
+		if unicode.IsSpace(r) {
+			break
+		}
+		pos += size
+	}
+	return pos
+}
+
+// lexAll is used to lex all words of input from pos, returning the words and error if a quote is unterminated.
+func lexAll(input string, pos int) ([]string, error) {
+	var all []string
+	for pos = skipSpaces(input, pos); pos < len(input); pos = skipSpaces(input, pos) {
+		word, next, err := lex(input, pos)
+		if err != nil {
+			return nil, err
+		}
+		all = append(all, word)
+		pos = next
+	}
+	return all, nil
+}
+
+// lex is used to read a word from pos until a space out of quotes, where text in single quotes is taken as it is,
+// and a backslash escapes the next character out of quotes or in double quotes,
+// returning the word without quotes or escapes, where it ends, and error if a quote is unterminated.
+func lex(input string, pos int) (string, int, error) {
+	var b strings.Builder
+	// characters are read as runes rather than bytes, since a byte of a multi-byte character like '全' may look like a space.
+	for pos < len(input) {
+		c, size := utf8.DecodeRuneInString(input[pos:])
+		switch {
+		case unicode.IsSpace(c):
+			return b.String(), pos, nil
+		case c == '\\' && pos+1 < len(input):
+			_, escaped := utf8.DecodeRuneInString(input[pos+1:])
+			b.WriteString(input[pos+1 : pos+1+escaped])
+			pos += 1 + escaped
+		case c == '\'' || c == '"':
+			start := pos
+			pos++
+			for pos < len(input) && rune(input[pos]) != c {
+				if c == '"' && input[pos] == '\\' && pos+1 < len(input) {
+					pos++
+				}
+				_, size := utf8.DecodeRuneInString(input[pos:])
+				b.WriteString(input[pos : pos+size])
+				pos += size
+			}
+			if pos == len(input) {
+				return "", 0, &Error{Pos: start, Msg: fmt.Sprintf("unterminated quote %c", c)}
+			}
+			pos++
+		default:
+			b.WriteString(input[pos : pos+size])
+			pos += size
+		}
+	}
+	return b.String(), pos, nil
+}
@@ -0,0 +1,222 @@
+package order
+
+import (
+	"errors"
+	"find/internal/note"
+	"reflect"
+	"testing"
+)
+
+// Orders of tests, which are defined like those of the program.
+func init() {
+	run := func(book *note.Notebook, cmd Command) int { return 0 }
+	Register(NewText(Find, "find [flags] keyword...", "find notes", []Flag{
+		{Name: FlagLong, Short: "l", Usage: "show metadata of notes"},
+		{Name: FlagNotebooks, Short: "n", Usage: "find in all notebooks"},
+	}, run))
+	Register(NewText(Add, "add key [#tag...]:content", "add a note", nil, run))
+	Register(New(Delete, "del [flags] key", "delete notes", []Flag{
+		{Name: FlagForce, Short: "f", Usage: "skip the confirmation"},
+		{Name: FlagAll, Short: "a", Usage: "delete all notes found"},
+	}, run, "rm"))
+	Register(New(Gen, "gen [flags] key", "generate a password", []Flag{
+		{Name: FlagPrint, Short: "p", Usage: "print the generated value"},
+		{Name: FlagLength, Short: "n", Value: "n", Usage: "length of the password"},
+	}, run))
+	Register(New(Weather, "weather [flags] address", "print the weather", []Flag{
+		{Name: FlagAll, Short: "a", Usage: "show the forecast too"},
+	}, run))
+}
+
+func TestLex(t *testing.T) {
+	tests := []struct {
+		input string
+		want  []string
+	}{
+		{"", nil},
+		{"a  b\t", []string{"a", "b"}},
+		// each of them has a byte 0x85, which is a space if it's taken as a rune.
+		{"全 公 兰 六 关", []string{"全", "公", "兰", "六", "关"}},
+		{"兰州市", []string{"兰州市"}},
+		{"北京　昌平", []string{"北京", "昌平"}},
+		{`"北京 昌平"`, []string{"北京 昌平"}},
+		{`my\ key`, []string{"my key"}},
+		{`\全公`, []string{"全公"}},
+		{`'a\b "c"'`, []string{`a\b "c"`}},
+		{`"a\"b\\c"`, []string{`a"b\c`}},
+		{`x"y z"'w'`, []string{"xy zw"}},
+		{`''`, []string{""}},
+		{`a\`, []string{`a\`}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			got, err := lexAll(tt.input, 0)
+			if err != nil {
+				t.Fatalf("lex error: %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("lex got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLexError(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{`"a`, "unterminated quote \" at column 1"},
+		{`a 'b`, "unterminated quote ' at column 3"},
+		{`"a\"`, "unterminated quote \" at column 1"},
+	}
+	for _, tt := range tests {
+		_, err := lexAll(tt.input, 0)
+		if err == nil || err.Error() != tt.want {
+			t.Errorf("lex %q got error %v, want %q", tt.input, err, tt.want)
+		}
+	}
+}
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		input string
+		want  Command
+	}{
+		{"", Command{}},
+		{"  ", Command{}},
+		{"del 兰州", Command{Order: Delete, Args: []string{"兰州"}, Text: "兰州", flags: map[string]string{}}},
+		{"del 全 公", Command{Order: Delete, Args: []string{"全", "公"}, Text: "全 公", flags: map[string]string{}}},
+		{`rm -fa "my key"`, Command{Order: Delete, Args: []string{"my key"}, Text: `"my key"`,
+			flags: map[string]string{FlagForce: "", FlagAll: ""}}},
+		{"del --force -- -x", Command{Order: Delete, Args: []string{"-x"}, Text: "-x",
+			flags: map[string]string{FlagForce: ""}}},
+		{"del - x", Command{Order: Delete, Args: []string{"-", "x"}, Text: "- x", flags: map[string]string{}}},
+		{"gen -pn24 k", Command{Order: Gen, Args: []string{"k"}, Text: "k",
+			flags: map[string]string{FlagPrint: "", FlagLength: "24"}}},
+		{"gen -n 24 k", Command{Order: Gen, Args: []string{"k"}, Text: "k", flags: map[string]string{FlagLength: "24"}}},
+		{"gen --length=24 k", Command{Order: Gen, Args: []string{"k"}, Text: "k",
+			flags: map[string]string{FlagLength: "24"}}},
+		{"gen --length '2 4' 全 公", Command{Order: Gen, Args: []string{"全", "公"}, Text: "全 公",
+			flags: map[string]string{FlagLength: "2 4"}}},
+		{"weather -a 兰州市", Command{Order: Weather, Args: []string{"兰州市"}, Text: "兰州市",
+			flags: map[string]string{FlagAll: ""}}},
+		{`find -l a OR "b c"`, Command{Order: Find, Text: `a OR "b c"`, flags: map[string]string{FlagLong: ""}}},
+		{"add cmd:ls -a", Command{Order: Add, Text: "cmd:ls -a", flags: map[string]string{}}},
+		{`add k:it's "v"`, Command{Order: Add, Text: `k:it's "v"`, flags: map[string]string{}}},
+		{"add 全公 #六:兰关", Command{Order: Add, Text: "全公 #六:兰关", flags: map[string]string{}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			got, err := Parse(tt.input)
+			if err != nil {
+				t.Fatalf("parse error: %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parse got %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseArgs(t *testing.T) {
+	tests := []struct {
+		name string
+		args []string
+		want Command
+	}{
+		{"nothing", nil, Command{}},
+		{"quoted by the shell", []string{"del", "-f", "my key"}, Command{Order: Delete, Args: []string{"my key"},
+			Text: "my key", flags: map[string]string{FlagForce: ""}}},
+		{"quotes kept", []string{"rm", `"my key"`}, Command{Order: Delete, Args: []string{`"my key"`},
+			Text: `"my key"`, flags: map[string]string{}}},
+		{"cjk", []string{"weather", "-a", "兰州市"}, Command{Order: Weather, Args: []string{"兰州市"}, Text: "兰州市",
+			flags: map[string]string{FlagAll: ""}}},
+		{"value of flag", []string{"gen", "-n", "24", "全 公"}, Command{Order: Gen, Args: []string{"全 公"},
+			Text: "全 公", flags: map[string]string{FlagLength: "24"}}},
+		{"text", []string{"add", `k:it's "v"`, "-a"}, Command{Order: Add, Text: `k:it's "v" -a`,
+			flags: map[string]string{}}},
+		{"end of flags", []string{"find", "-l", "--", "-x"}, Command{Order: Find, Text: "-x",
+			flags: map[string]string{FlagLong: ""}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseArgs(tt.args)
+			if err != nil {
+				t.Fatalf("parse error: %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parse got %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseError(t *testing.T) {
+	tests := []struct {
+		input string
+		args  []string
+		want  string
+	}{
+		{"finder x", []string{"finder", "x"}, "unknown order finder"},
+		{"全 x", []string{"全", "x"}, "unknown order 全"},
+		{"del -x k", []string{"del", "-x", "k"}, "unknown flag -x of del at column 5"},
+		{"del --fast k", []string{"del", "--fast", "k"}, "unknown flag --fast of del at column 5"},
+		{"gen -n", []string{"gen", "-n"}, "flag -n of gen needs a value at column 7"},
+		{"gen --length", []string{"gen", "--length"}, "flag --length of gen needs a value at column 13"},
+		{"gen --print=1 k", []string{"gen", "--print=1", "k"}, "flag --print of gen takes no value at column 5"},
+		{"del '兰州", nil, "unterminated quote ' at column 5"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			_, err := Parse(tt.input)
+			if err == nil || err.Error() != tt.want {
+				t.Errorf("parse got error %v, want %q", err, tt.want)
+			}
+			if tt.args == nil {
+				return
+			}
+			_, err = ParseArgs(tt.args)
+			if err == nil || err.Error() != tt.want {
+				t.Errorf("parse args got error %v, want %q", err, tt.want)
+			}
+		})
+	}
+
+	_, err := Parse("finde x")
+	var unknown *UnknownError
+	if !errors.As(err, &unknown) || !reflect.DeepEqual(unknown.Suggestions, []string{Find}) {
+		t.Errorf("parse got error %#v, want unknown order with suggestion %s", err, Find)
+	}
+}
+
+func TestComplete(t *testing.T) {
+	withAliases(t, nil)
+	keys := func(o Order, prefix string) []string {
+		return withPrefix([]string{"兰州", "全公", "my key"}, prefix, "")
+	}
+	tests := []struct {
+		line       string
+		start      int
+		candidates []string
+	}{
+		{"", 0, []string{"add ", "del ", "find ", "gen ", "rm ", "weather "}},
+		{"  de", 2, []string{"del "}},
+		{"全", 0, nil},
+		{"gen --le", 4, []string{"--length "}},
+		{"del -", 4, []string{"--all ", "--force ", "-a ", "-f "}},
+		{"del -f 兰", 7, []string{"兰州"}},
+		{"del 全", 4, []string{"全公"}},
+		{"gen -n 24 my", 10, []string{"my key"}},
+		{"del -- -", 7, nil},
+		{"unknown x", 0, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.line, func(t *testing.T) {
+			start, candidates := Complete(tt.line, keys)
+			if start != tt.start || !reflect.DeepEqual(candidates, tt.candidates) {
+				t.Errorf("complete got %d %q, want %d %q", start, candidates, tt.start, tt.candidates)
+			}
+		})
+	}
+}
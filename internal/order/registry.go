@@ -0,0 +1,162 @@
+package order
+
+import (
+	"find/internal/note"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Order is what user can run from the prompt or arguments of the process.
+type Order interface {
+	// Name is what user types to run the order, like 'find'.
+	Name() string
+	// Aliases are other names of the order, like 'rm' for 'del'.
+	Aliases() []string
+	// Usage is the synopsis of the order, like 'del [flags] keyword'.
+	Usage() string
+	// Summary tells what the order does in a line.
+	Summary() string
+	Flags() []Flag
+	// Text tells whether the arguments are text taken as it is, like content of a note or a query, rather than words.
+	Text() bool
+	// Run is used to run the command parsed for the order on the notebook, returning the exit code of one-shot mode.
+	Run(book *note.Notebook, cmd Command) int
+}
+
+// Runner runs a command on the notebook, returning the exit code of one-shot mode.
+type Runner func(book *note.Notebook, cmd Command) int
+
+// defined is an Order defined by New.
+type defined struct {
+	name    string
+	aliases []string
+	usage   string
+	summary string
+	flags   []Flag
+	text    bool
+	run     Runner
+}
+
+// New is used to define an order by its name, usage, summary, flags and how it runs, along with optional aliases.
+// Its arguments are words, which can be quoted like 'del "my key"'.
+func New(name, usage, summary string, flags []Flag, run Runner, aliases ...string) Order {
+	return &defined{name: name, aliases: aliases, usage: usage, summary: summary, flags: flags, run: run}
+}
+
+// NewText is used to define an order like New, whose arguments are text taken as it is, like 'add k:it's "v"'.
+func NewText(name, usage, summary string, flags []Flag, run Runner, aliases ...string) Order {
+	return &defined{name: name, aliases: aliases, usage: usage, summary: summary, flags: flags, text: true, run: run}
+}
+
+func (o *defined) Name() string {
+	return o.name
+}
+
+func (o *defined) Aliases() []string {
+	return o.aliases
+}
+
+func (o *defined) Usage() string {
+	return o.usage
+}
+
+func (o *defined) Summary() string {
+	return o.summary
+}
+
+func (o *defined) Flags() []Flag {
+	return o.flags
+}
+
+func (o *defined) Text() bool {
+	return o.text
+}
+
+func (o *defined) Run(book *note.Notebook, cmd Command) int {
+	return o.run(book, cmd)
+}
+
+// registry keeps all orders in the order they are registered, along with their names and aliases.
+var registry = struct {
+	orders []Order
+	names  map[string]Order
+}{names: make(map[string]Order)}
+
+// Register is used to make an order available to user, which panics if its name or an alias is taken,
+// since that's a mistake of the program rather than the user.
+func Register(o Order) {
+	for _, name := range append([]string{o.Name()}, o.Aliases()...) {
+		if _, ok := registry.names[name]; ok {
+			panic(fmt.Sprintf("order %s is registered twice", name))
+		}
+		registry.names[name] = o
+	}
+	registry.orders = append(registry.orders, o)
+}
+
+// Lookup is used to get the order of specified name or alias, returning the order and whether it's found.
+func Lookup(name string) (Order, bool) {
+	o, ok := registry.names[name]
+	return o, ok
+}
+
+// Orders is used to get all orders in the order they are registered.
+func Orders() []Order {
+	return registry.orders
+}
+
+// Names is used to get names and aliases of all orders, sorted.
+func Names() []string {
+	names := make([]string, 0, len(registry.names))
+	for name := range registry.names {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// PrintOrders is used to show all orders to the user, each with its usage and summary.
+func PrintOrders() {
+	width := 0
+	for _, o := range registry.orders {
+		if len(o.Usage()) > width {
+			width = len(o.Usage())
+		}
+	}
+	for _, o := range registry.orders {
+		fmt.Printf("  %-*s  %s\n", width, o.Usage(), o.Summary())
+	}
+}
+
+// PrintUsage is used to show how to use the order to the user, along with its aliases and flags.
+func PrintUsage(o Order) {
+	fmt.Printf("Usage: %s\n", o.Usage())
+	fmt.Printf("%s.\n", strings.ToUpper(o.Summary()[:1])+o.Summary()[1:])
+	if len(o.Aliases()) > 0 {
+		fmt.Printf("Aliases: %s\n", strings.Join(o.Aliases(), ", "))
+	}
+	if len(o.Flags()) == 0 {
+		return
+	}
+	fmt.Println("Flags:")
+	forms := make([]string, 0, len(o.Flags()))
+	width := 0
+	for _, f := range o.Flags() {
+		form := "    "
+		if f.Short != "" {
+			form = "-" + f.Short + ", "
+		}
+		form += "--" + f.Name
+		if f.Value != "" {
+			form += " " + f.Value
+		}
+		forms = append(forms, form)
+		if len(form) > width {
+			width = len(form)
+		}
+	}
+	for i, f := range o.Flags() {
+		fmt.Printf("  %-*s  %s\n", width, forms[i], f.Usage)
+	}
+}
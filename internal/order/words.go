@@ -0,0 +1,93 @@
+package order
+
+import (
+	"strings"
+)
+
+// words is where words of a command are read from, which is a line typed by user or arguments of the process.
+type words interface {
+	// next is used to read the next word, or only a word starting with '-' if flag is true,
+	// returning the word, whether there's one, and error.
+	next(flag bool) (string, bool, error)
+	// back is used to put the word just read back.
+	back()
+	// pos is used to get where the word just read starts, which is the byte offset in the input,
+	// taking arguments of the process as joined by a space.
+	pos() int
+	// rest is used to get the rest as text and words, where words are left empty if only text is wanted.
+	rest(text bool) (string, []string, error)
+}
+
+// lineWords are words of a line typed by user, which are lexed as they are read.
+type lineWords struct {
+	input     string
+	start, at int
+}
+
+func (l *lineWords) next(flag bool) (string, bool, error) {
+	l.start = skipSpaces(l.input, l.at)
+	if l.start == len(l.input) || flag && l.input[l.start] != '-' {
+		return "", false, nil
+	}
+	word, end, err := lex(l.input, l.start)
+	if err != nil {
+		return "", false, err
+	}
+	l.at = end
+	return word, true, nil
+}
+
+func (l *lineWords) back() {
+	l.at = l.start
+}
+
+func (l *lineWords) pos() int {
+	return l.start
+}
+
+func (l *lineWords) rest(text bool) (string, []string, error) {
+	rest := strings.TrimSpace(l.input[l.at:])
+	if text {
+		return rest, nil, nil
+	}
+	all, err := lexAll(l.input, l.at)
+	if err != nil {
+		return "", nil, err
+	}
+	return rest, all, nil
+}
+
+// argWords are arguments of the process, each of which is a word as it is, since the shell has split them.
+type argWords struct {
+	args      []string
+	start, at int
+}
+
+func (a *argWords) next(flag bool) (string, bool, error) {
+	a.start = a.at
+	if a.at == len(a.args) || flag && !strings.HasPrefix(a.args[a.at], "-") {
+		return "", false, nil
+	}
+	a.at++
+	return a.args[a.start], true, nil
+}
+
+func (a *argWords) back() {
+	a.at = a.start
+}
+
+func (a *argWords) pos() int {
+	pos := len(strings.Join(a.args[:a.start], " "))
+	if a.start > 0 && a.start < len(a.args) {
+		pos++
+	}
+	return pos
+}
+
+func (a *argWords) rest(text bool) (string, []string, error) {
+	rest := strings.Join(a.args[a.at:], " ")
+	if text {
+		return rest, nil, nil
+	}
+	return rest, a.args[a.at:], nil
+}
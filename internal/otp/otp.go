@@ -0,0 +1,159 @@
+// Package otp implements time-based one-time passwords (RFC 6238) for 2FA seeds kept in notes.
+package otp
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base32"
+	"encoding/binary"
+	"fmt"
+	"hash"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Scheme is the beginning of a URI like 'otpauth://totp/GitHub:alice?secret=...' which 2FA QR codes carry.
+const Scheme = "otpauth://"
+
+// Defaults of a seed, which are used by most services.
+const (
+	defaultDigits    = 6
+	defaultPeriod    = 30
+	defaultAlgorithm = "SHA1"
+)
+
+// minSecret is the fewest bytes of a secret, which is 80 bits like 'JBSWY3DPEHPK3PXP',
+// so that a value which is merely base32-like isn't taken as a seed.
+const minSecret = 10
+
+// algorithms are supported hash functions of HMAC.
+var algorithms = map[string]func() hash.Hash{
+	"SHA1":   sha1.New,
+	"SHA256": sha256.New,
+	"SHA512": sha512.New,
+}
+
+// Key is a 2FA seed along with how codes are generated from it.
+type Key struct {
+	Secret    []byte
+	Algorithm string
+	Digits    int
+	// Period is seconds each code lasts.
+	Period int
+	// Issuer and Account come from the label of an otpauth URI, which may be empty.
+	Issuer  string
+	Account string
+}
+
+// IsURI is used to check if value is an otpauth URI.
+func IsURI(value string) bool {
+	return strings.HasPrefix(strings.TrimSpace(value), Scheme)
+}
+
+// Parse is used to read a seed from a base32 secret like 'JBSWY3DPEHPK3PXP' or an otpauth URI,
+// returning the key and error.
+func Parse(value string) (Key, error) {
+	value = strings.TrimSpace(value)
+	if IsURI(value) {
+		return parseURI(value)
+	}
+	secret, err := decode(value)
+	if err != nil {
+		return Key{}, err
+	}
+	return Key{Secret: secret, Algorithm: defaultAlgorithm, Digits: defaultDigits, Period: defaultPeriod}, nil
+}
+
+// parseURI is used to read a seed from an otpauth URI, returning the key and error.
+func parseURI(uri string) (Key, error) {
+	u, err := url.Parse(uri)
+	if err != nil {
+		return Key{}, fmt.Errorf("parse uri error: %v", err)
+	}
+	if !strings.EqualFold(u.Host, "totp") {
+		return Key{}, fmt.Errorf("unsupported type %s, only totp is supported", u.Host)
+	}
+	q := u.Query()
+	secret, err := decode(q.Get("secret"))
+	if err != nil {
+		return Key{}, err
+	}
+	key := Key{Secret: secret, Algorithm: defaultAlgorithm, Digits: defaultDigits, Period: defaultPeriod}
+	if a := strings.ToUpper(q.Get("algorithm")); a != "" {
+		if _, ok := algorithms[a]; !ok {
+			return Key{}, fmt.Errorf("unsupported algorithm %s", a)
+		}
+		key.Algorithm = a
+	}
+	if d := q.Get("digits"); d != "" {
+		key.Digits, err = strconv.Atoi(d)
+		if err != nil || key.Digits < 6 || key.Digits > 8 {
+			return Key{}, fmt.Errorf("invalid digits %s, expecting 6 to 8", d)
+		}
+	}
+	if p := q.Get("period"); p != "" {
+		key.Period, err = strconv.Atoi(p)
+		if err != nil || key.Period <= 0 {
+			return Key{}, fmt.Errorf("invalid period %s", p)
+		}
+	}
+	label := strings.TrimPrefix(u.Path, "/")
+	if i := strings.Index(label, ":"); i != -1 {
+		key.Issuer = strings.TrimSpace(label[:i])
+		label = label[i+1:]
+	}
+	key.Account = strings.TrimSpace(label)
+	if issuer := q.Get("issuer"); issuer != "" {
+		key.Issuer = issuer
+	}
+	return key, nil
+}
+
+// decode is used to read a base32 secret ignoring spaces, padding and the case, returning bytes and error.
+func decode(secret string) ([]byte, error) {
+	secret = strings.ToUpper(strings.Join(strings.Fields(secret), ""))
+	secret = strings.TrimRight(secret, "=")
+	if secret == "" {
+		return nil, fmt.Errorf("missing secret")
+	}
+	b, err := base32.StdEncoding.WithPadding(base32.NoPadding).DecodeString(secret)
+	if err != nil {
+		return nil, fmt.Errorf("secret isn't base32: %v", err)
+	}
+	if len(b) < minSecret {
+		return nil, fmt.Errorf("secret is too short, expecting at least %d bits", minSecret*8)
+	}
+	return b, nil
+}
+
+// Name is used to get a readable name of the key like 'GitHub alice', which is empty if the label is.
+func (k Key) Name() string {
+	return strings.TrimSpace(k.Issuer + " " + k.Account)
+}
+
+// Code is used to generate the code at t, returning the code padded with zeros.
+func (k Key) Code(t time.Time) string {
+	counter := uint64(t.Unix() / int64(k.Period))
+	var msg [8]byte
+	binary.BigEndian.PutUint64(msg[:], counter)
+	mac := hmac.New(algorithms[k.Algorithm], k.Secret)
+	mac.Write(msg[:])
+	sum := mac.Sum(nil)
+	offset := sum[len(sum)-1] & 0x0f
+	value := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+	mod := uint32(1)
+	for i := 0; i < k.Digits; i++ {
+		mod *= 10
+	}
+	return fmt.Sprintf("%0*d", k.Digits, value%mod)
+}
+
+// Remaining is used to get how long the code at t still lasts.
+func (k Key) Remaining(t time.Time) time.Duration {
+	period := time.Duration(k.Period) * time.Second
+	return period - time.Duration(t.UnixNano())%period
+}
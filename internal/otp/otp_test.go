@@ -0,0 +1,121 @@
+package otp
+
+import (
+	"encoding/base32"
+	"testing"
+	"time"
+)
+
+// rfc6238Secrets are seeds of test vectors in RFC 6238 appendix B, by algorithm.
+var rfc6238Secrets = map[string]string{
+	"SHA1":   "12345678901234567890",
+	"SHA256": "12345678901234567890123456789012",
+	"SHA512": "1234567890123456789012345678901234567890123456789012345678901234",
+}
+
+func TestCodeRFC6238(t *testing.T) {
+	tests := []struct {
+		unix      int64
+		algorithm string
+		want      string
+	}{
+		{59, "SHA1", "94287082"},
+		{59, "SHA256", "46119246"},
+		{59, "SHA512", "90693936"},
+		{1111111109, "SHA1", "07081804"},
+		{1111111109, "SHA256", "68084774"},
+		{1111111109, "SHA512", "25091201"},
+		{1111111111, "SHA1", "14050471"},
+		{1111111111, "SHA256", "67062674"},
+		{1111111111, "SHA512", "99943326"},
+		{1234567890, "SHA1", "89005924"},
+		{1234567890, "SHA256", "91819424"},
+		{1234567890, "SHA512", "93441116"},
+		{2000000000, "SHA1", "69279037"},
+		{2000000000, "SHA256", "90698825"},
+		{2000000000, "SHA512", "38618901"},
+		{20000000000, "SHA1", "65353130"},
+		{20000000000, "SHA256", "77737706"},
+		{20000000000, "SHA512", "47863826"},
+	}
+	for _, tt := range tests {
+		key := Key{Secret: []byte(rfc6238Secrets[tt.algorithm]), Algorithm: tt.algorithm, Digits: 8, Period: 30}
+		if got := key.Code(time.Unix(tt.unix, 0)); got != tt.want {
+			t.Errorf("code of %s at %d = %s, want %s", tt.algorithm, tt.unix, got, tt.want)
+		}
+	}
+}
+
+func TestParse(t *testing.T) {
+	seed := base32.StdEncoding.EncodeToString([]byte(rfc6238Secrets["SHA1"]))
+	tests := []struct {
+		name  string
+		value string
+		// want is the code at 1111111109, along with issuer and account.
+		want    string
+		issuer  string
+		account string
+	}{
+		{"base32 secret", seed, "081804", "", ""},
+		{"lower case with spaces", "gezd gnbv gy3t qojq gezd gnbv gy3t qojq", "081804", "", ""},
+		{"uri", "otpauth://totp/ACME:alice?secret=" + seed + "&digits=8", "07081804", "ACME", "alice"},
+		{"uri with issuer", "otpauth://totp/alice@x.com?secret=" + seed + "&issuer=Git%20Hub", "081804", "Git Hub",
+			"alice@x.com"},
+		{"uri of sha256", "otpauth://totp/a?secret=" +
+			base32.StdEncoding.EncodeToString([]byte(rfc6238Secrets["SHA256"])) + "&algorithm=sha256&digits=8",
+			"68084774", "", "a"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			key, err := Parse(tt.value)
+			if err != nil {
+				t.Fatalf("parse error: %v", err)
+			}
+			if got := key.Code(time.Unix(1111111109, 0)); got != tt.want {
+				t.Errorf("code = %s, want %s", got, tt.want)
+			}
+			if key.Issuer != tt.issuer || key.Account != tt.account {
+				t.Errorf("label = %q %q, want %q %q", key.Issuer, key.Account, tt.issuer, tt.account)
+			}
+		})
+	}
+}
+
+func TestParseInvalid(t *testing.T) {
+	tests := []struct {
+		name  string
+		value string
+	}{
+		{"not base32", "hello world!"},
+		{"too short", "JBSWY3DP"},
+		{"empty", ""},
+		{"hotp", "otpauth://hotp/a?secret=JBSWY3DPEHPK3PXP&counter=1"},
+		{"unknown algorithm", "otpauth://totp/a?secret=JBSWY3DPEHPK3PXP&algorithm=MD5"},
+		{"too many digits", "otpauth://totp/a?secret=JBSWY3DPEHPK3PXP&digits=10"},
+		{"zero period", "otpauth://totp/a?secret=JBSWY3DPEHPK3PXP&period=0"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if key, err := Parse(tt.value); err == nil {
+				t.Errorf("parse got %+v, want error", key)
+			}
+		})
+	}
+}
+
+func TestRemaining(t *testing.T) {
+	key := Key{Period: 30}
+	tests := []struct {
+		at   time.Time
+		want time.Duration
+	}{
+		{time.Unix(60, 0), 30 * time.Second},
+		{time.Unix(89, 0), time.Second},
+		{time.Unix(75, 500*int64(time.Millisecond)), 14500 * time.Millisecond},
+	}
+	for _, tt := range tests {
+		if got := key.Remaining(tt.at); got != tt.want {
+			t.Errorf("remaining at %v = %v, want %v", tt.at, got, tt.want)
+		}
+	}
+}
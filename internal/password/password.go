@@ -0,0 +1,151 @@
+// Package password implements methods for generating random passwords and passphrases.
+package password
+
+import (
+	"crypto/rand"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/sethvargo/go-diceware/diceware"
+)
+
+// Character classes of a password, which are picked by their initials like 'luds'.
+const (
+	Lower   = 'l'
+	Upper   = 'u'
+	Digits  = 'd'
+	Symbols = 's'
+)
+
+// classes are characters of each class.
+var classes = map[rune]string{
+	Lower:   "abcdefghijklmnopqrstuvwxyz",
+	Upper:   "ABCDEFGHIJKLMNOPQRSTUVWXYZ",
+	Digits:  "0123456789",
+	Symbols: "!#$%&()*+,-./:;<=>?@[]^_{|}~",
+}
+
+// ambiguous are characters which are easily mistaken for others when read or typed.
+const ambiguous = "0O1lI|"
+
+// Defaults of options.
+const (
+	DefaultLength    = 20
+	DefaultClasses   = "luds"
+	DefaultSeparator = "-"
+)
+
+// Limits of options, beyond which a password is either too weak or too long to be useful.
+const (
+	minLength = 4
+	maxLength = 1024
+	minWords  = 3
+	maxWords  = 64
+)
+
+// Options describe what to generate, which is a passphrase of Words words if Words is positive,
+// or a password of Length characters from Classes otherwise.
+type Options struct {
+	Length int
+	// Classes are initials of character classes like 'luds', each of which appears at least once.
+	Classes string
+	// NoAmbiguous excludes characters like '0' and 'O', 'l' and '1'.
+	NoAmbiguous bool
+	Words       int
+	Separator   string
+}
+
+// Generate is used to generate a password or passphrase by options, returning it and error.
+func Generate(o Options) (string, error) {
+	if o.Words > 0 {
+		return passphrase(o)
+	}
+	return generatePassword(o)
+}
+
+// passphrase is used to pick random words from the EFF large word list, returning them joined by the separator.
+func passphrase(o Options) (string, error) {
+	if o.Words < minWords || o.Words > maxWords {
+		return "", fmt.Errorf("words should be %d to %d", minWords, maxWords)
+	}
+	words, err := diceware.Generate(o.Words)
+	if err != nil {
+		return "", fmt.Errorf("pick words error: %v", err)
+	}
+	return strings.Join(words, o.Separator), nil
+}
+
+// generatePassword is used to generate a password having every class, returning it and error.
+func generatePassword(o Options) (string, error) {
+	if o.Length < minLength || o.Length > maxLength {
+		return "", fmt.Errorf("length should be %d to %d", minLength, maxLength)
+	}
+	sets, err := charsets(o.Classes, o.NoAmbiguous)
+	if err != nil {
+		return "", err
+	}
+	if len(sets) > o.Length {
+		return "", fmt.Errorf("length %d is too short for %d classes", o.Length, len(sets))
+	}
+	all := strings.Join(sets, "")
+	chars := make([]byte, o.Length)
+	// one character of each class is put first, and then all are shuffled.
+	for i := range chars {
+		set := all
+		if i < len(sets) {
+			set = sets[i]
+		}
+		n, err := random(len(set))
+		if err != nil {
+			return "", err
+		}
+		chars[i] = set[n]
+	}
+	for i := len(chars) - 1; i > 0; i-- {
+		j, err := random(i + 1)
+		if err != nil {
+			return "", err
+		}
+		chars[i], chars[j] = chars[j], chars[i]
+	}
+	return string(chars), nil
+}
+
+// charsets is used to get characters of each class by initials, returning them and error if any initial is unknown.
+func charsets(initials string, noAmbiguous bool) ([]string, error) {
+	if initials == "" {
+		initials = DefaultClasses
+	}
+	seen := make(map[rune]bool)
+	sets := make([]string, 0, len(classes))
+	for _, c := range initials {
+		chars, ok := classes[c]
+		if !ok {
+			return nil, fmt.Errorf("unknown class %c, expecting %c, %c, %c or %c", c, Lower, Upper, Digits, Symbols)
+		}
+		if seen[c] {
+			continue
+		}
+		seen[c] = true
+		if noAmbiguous {
+			chars = strings.Map(func(r rune) rune {
+				if strings.ContainsRune(ambiguous, r) {
+					return -1
+				}
+				return r
+			}, chars)
+		}
+		sets = append(sets, chars)
+	}
+	return sets, nil
+}
+
+// random is used to get a uniform random number in [0, n) from crypto/rand, returning it and error.
+func random(n int) (int, error) {
+	i, err := rand.Int(rand.Reader, big.NewInt(int64(n)))
+	if err != nil {
+		return 0, fmt.Errorf("read random error: %v", err)
+	}
+	return int(i.Int64()), nil
+}
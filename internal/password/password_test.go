@@ -0,0 +1,88 @@
+package password
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestGeneratePassword(t *testing.T) {
+	tests := []struct {
+		name string
+		o    Options
+		sets []string
+	}{
+		{"default classes", Options{Length: DefaultLength}, []string{classes[Lower], classes[Upper], classes[Digits],
+			classes[Symbols]}},
+		{"digits only", Options{Length: 6, Classes: "d"}, []string{classes[Digits]}},
+		{"one of each class", Options{Length: 4, Classes: "luds"}, []string{classes[Lower], classes[Upper],
+			classes[Digits], classes[Symbols]}},
+		{"repeated class", Options{Length: 8, Classes: "ll"}, []string{classes[Lower]}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// passwords are random, so a few of them are checked.
+			for i := 0; i < 20; i++ {
+				p, err := Generate(tt.o)
+				if err != nil {
+					t.Fatalf("generate error: %v", err)
+				}
+				if len(p) != tt.o.Length {
+					t.Fatalf("generate got %q of length %d, want %d", p, len(p), tt.o.Length)
+				}
+				all := strings.Join(tt.sets, "")
+				for _, set := range tt.sets {
+					if !strings.ContainsAny(p, set) {
+						t.Fatalf("generate got %q without any of %q", p, set)
+					}
+				}
+				for _, c := range p {
+					if !strings.ContainsRune(all, c) {
+						t.Fatalf("generate got %q with %c out of classes", p, c)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestGenerateNoAmbiguous(t *testing.T) {
+	for i := 0; i < 20; i++ {
+		p, err := Generate(Options{Length: 64, Classes: "lud", NoAmbiguous: true})
+		if err != nil {
+			t.Fatalf("generate error: %v", err)
+		}
+		if strings.ContainsAny(p, ambiguous) {
+			t.Fatalf("generate got %q with ambiguous characters", p)
+		}
+	}
+}
+
+func TestPassphrase(t *testing.T) {
+	p, err := Generate(Options{Words: 5, Separator: "."})
+	if err != nil {
+		t.Fatalf("generate error: %v", err)
+	}
+	if words := strings.Split(p, "."); len(words) != 5 {
+		t.Errorf("generate got %q of %d words, want 5", p, len(words))
+	}
+}
+
+func TestGenerateError(t *testing.T) {
+	tests := []struct {
+		name string
+		o    Options
+	}{
+		{"too short", Options{Length: minLength - 1}},
+		{"too long", Options{Length: maxLength + 1}},
+		{"unknown class", Options{Length: 8, Classes: "lx"}},
+		{"too few words", Options{Words: minWords - 1}},
+		{"too many words", Options{Words: maxWords + 1}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if p, err := Generate(tt.o); err == nil {
+				t.Errorf("generate got %q, want error", p)
+			}
+		})
+	}
+}
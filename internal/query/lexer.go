@@ -0,0 +1,125 @@
+package query
+
+import "unicode"
+
+// tokenKind is the kind of a token of query.
+type tokenKind int
+
+const (
+	tokenWord tokenKind = iota
+	tokenOpen
+	tokenClose
+	// tokenNot is a '-' right before a term, like '-draft'.
+	tokenNot
+)
+
+// token is a piece of query, where a word keeps whether each rune of it was quoted or escaped,
+// so that operators in it can be told from text.
+type token struct {
+	kind    tokenKind
+	runes   []rune
+	literal []bool
+	// pos is where the token starts in the query, counted in runes from 0.
+	pos int
+}
+
+// text is used to get the text of token.
+func (t token) text() string {
+	return string(t.runes)
+}
+
+// is is used to check if the token is a bare word of specified text like 'OR', which isn't quoted or escaped.
+func (t token) is(text string) bool {
+	if t.kind != tokenWord || t.text() != text {
+		return false
+	}
+	for _, literal := range t.literal {
+		if literal {
+			return false
+		}
+	}
+	return true
+}
+
+// lex is used to split query into tokens, returning tokens and error.
+// Words are separated by spaces and parentheses. Text between double quotes is taken as it is,
+// where '\"' and '\\' mean a double quote and a backslash, and a backslash outside quotes
+// makes the next rune be taken as it is.
+func lex(query string) ([]token, error) {
+	runes := []rune(query)
+	tokens := make([]token, 0)
+	for i := 0; i < len(runes); {
+		r := runes[i]
+		switch {
+		case unicode.IsSpace(r):
+			i++
+		case r == '(':
+			tokens = append(tokens, token{kind: tokenOpen, runes: []rune{r}, pos: i})
+			i++
+		case r == ')':
+			tokens = append(tokens, token{kind: tokenClose, runes: []rune{r}, pos: i})
+			i++
+		case r == '-' && i+1 < len(runes) && !unicode.IsSpace(runes[i+1]) && runes[i+1] != ')':
+			tokens = append(tokens, token{kind: tokenNot, runes: []rune{r}, pos: i})
+			i++
+		default:
+			t, next, err := lexWord(runes, i)
+			if err != nil {
+				return nil, err
+			}
+			tokens = append(tokens, t)
+			i = next
+		}
+	}
+	return tokens, nil
+}
+
+// lexWord is used to read a word starting at specified position,
+// returning the word, the position after it, and error.
+func lexWord(runes []rune, start int) (token, int, error) {
+	t := token{kind: tokenWord, pos: start}
+	i := start
+	for i < len(runes) {
+		r := runes[i]
+		if unicode.IsSpace(r) || r == '(' || r == ')' {
+			break
+		}
+		switch r {
+		case '\\':
+			if i+1 < len(runes) {
+				i++
+			}
+			t.runes = append(t.runes, runes[i])
+			t.literal = append(t.literal, true)
+			i++
+		case '"':
+			quote := i
+			i++
+			closed := false
+			for i < len(runes) {
+				if runes[i] == '"' {
+					closed = true
+					i++
+					break
+				}
+				if runes[i] == '\\' && i+1 < len(runes) && (runes[i+1] == '"' || runes[i+1] == '\\') {
+					i++
+				}
+				t.runes = append(t.runes, runes[i])
+				t.literal = append(t.literal, true)
+				i++
+			}
+			if !closed {
+				return token{}, 0, errorf(quote, "missing closing quote")
+			}
+		default:
+			t.runes = append(t.runes, r)
+			t.literal = append(t.literal, false)
+			i++
+		}
+	}
+	if len(t.runes) == 0 {
+		return token{}, 0, errorf(start, "empty phrase")
+	}
+	return t, i, nil
+}
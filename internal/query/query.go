@@ -0,0 +1,384 @@
+// Package query implements a small language for finding notes, like 'mysql OR redis -test tag:prod'.
+package query
+
+import (
+	"fmt"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// Fields which a term can be qualified with like 'key:mysql', where FieldText is the default one.
+const (
+	FieldText    = ""
+	FieldKey     = "key"
+	FieldVal     = "val"
+	FieldTag     = "tag"
+	FieldHas     = "has"
+	FieldCreated = "created"
+	FieldUpdated = "updated"
+)
+
+// Things which notes can have like 'has:remind'.
+const (
+	// HasRemind means a reminder which hasn't been sent.
+	HasRemind = "remind"
+	// HasReminded means a reminder which has been sent.
+	HasReminded = "reminded"
+	// HasTags means any tag.
+	HasTags = "tags"
+)
+
+// dateLayouts are layouts of time in comparisons like 'created>2026-01-01',
+// along with the duration which a time of each layout spans.
+var dateLayouts = []struct {
+	layout string
+	span   time.Duration
+}{
+	{"2006-01-02", 24 * time.Hour},
+	{"2006-01-02 15:04", time.Minute},
+	{"2006-01-02T15:04", time.Minute},
+}
+
+// Matcher tells whether a note meets terms of query, which is implemented for each note being checked.
+type Matcher interface {
+	// Contains reports whether the field of note, which is one of FieldText, FieldKey and FieldVal, contains text.
+	Contains(field, text string) bool
+	// HasTag reports whether the note has tag.
+	HasTag(tag string) bool
+	// Has reports whether the note has the thing like HasRemind.
+	Has(thing string) bool
+	// Time returns the time of note of the field, which is FieldCreated or FieldUpdated.
+	Time(field string) time.Time
+}
+
+// Node is a part of parsed query, which can be matched against a note.
+type Node interface {
+	Match(m Matcher) bool
+}
+
+// And matches notes which match both sides.
+type And struct{ Left, Right Node }
+
+// Or matches notes which match either side.
+type Or struct{ Left, Right Node }
+
+// Not matches notes which don't match the node.
+type Not struct{ Node Node }
+
+// Term matches notes whose field contains the value, or have the tag or thing of value.
+type Term struct {
+	Field string
+	Value string
+}
+
+// Compare matches notes whose time of field is in the relation of Op to the span [From, To).
+type Compare struct {
+	Field    string
+	Op       string
+	From, To time.Time
+}
+
+func (n And) Match(m Matcher) bool { return n.Left.Match(m) && n.Right.Match(m) }
+
+func (n Or) Match(m Matcher) bool { return n.Left.Match(m) || n.Right.Match(m) }
+
+func (n Not) Match(m Matcher) bool { return !n.Node.Match(m) }
+
+func (n Term) Match(m Matcher) bool {
+	switch n.Field {
+	case FieldTag:
+		return m.HasTag(n.Value)
+	case FieldHas:
+		return m.Has(n.Value)
+	default:
+		return m.Contains(n.Field, n.Value)
+	}
+}
+
+func (n Compare) Match(m Matcher) bool {
+	t := m.Time(n.Field)
+	switch n.Op {
+	case ">":
+		return !t.Before(n.To)
+	case ">=":
+		return !t.Before(n.From)
+	case "<":
+		return t.Before(n.From)
+	case "<=":
+		return t.Before(n.To)
+	default:
+		return !t.Before(n.From) && t.Before(n.To)
+	}
+}
+
+// Error is a syntax error of query, along with where it is.
+type Error struct {
+	// Pos is where the error is in the query, counted in runes from 0.
+	Pos int
+	Msg string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("%s at column %d", e.Msg, e.Pos+1)
+}
+
+// errorf is used to make a syntax error at specified position.
+func errorf(pos int, format string, a ...interface{}) error {
+	return &Error{Pos: pos, Msg: fmt.Sprintf(format, a...)}
+}
+
+// Parse is used to parse query from user's input, returning the root node and error,
+// where the node is nil if the query is empty, which matches all notes.
+//
+// Terms next to each other must all match, and 'OR' between terms means either, which binds looser.
+// A term can be negated by '-' or 'NOT', grouped by parentheses, quoted as a phrase like '"a b"',
+// qualified by a field like 'key:a', 'val:a', 'tag:a' or 'has:remind',
+// or be a comparison of time like 'created>2026-01-01'. Words like '#a' are short for 'tag:a'.
+func Parse(query string) (Node, error) {
+	tokens, err := lex(query)
+	if err != nil {
+		return nil, err
+	}
+	if len(tokens) == 0 {
+		return nil, nil
+	}
+	p := &parser{tokens: tokens, end: len([]rune(query))}
+	node, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if t, ok := p.peek(); ok {
+		if t.kind == tokenClose {
+			return nil, errorf(t.pos, "unexpected ')'")
+		}
+		return nil, errorf(t.pos, "unexpected %s", t.text())
+	}
+	return node, nil
+}
+
+// Match is used to check if the note of matcher matches the node, which is true for a nil node.
+func Match(node Node, m Matcher) bool {
+	return node == nil || node.Match(m)
+}
+
+// Required is used to collect terms which every note matched by the node must match,
+// returning terms joined by AND at the top of the node.
+func Required(node Node) []Term {
+	switch n := node.(type) {
+	case And:
+		return append(Required(n.Left), Required(n.Right)...)
+	case Term:
+		return []Term{n}
+	default:
+		return nil
+	}
+}
+
+// Positive is used to collect terms which aren't negated, returning terms in order.
+func Positive(node Node) []Term {
+	switch n := node.(type) {
+	case And:
+		return append(Positive(n.Left), Positive(n.Right)...)
+	case Or:
+		return append(Positive(n.Left), Positive(n.Right)...)
+	case Term:
+		return []Term{n}
+	default:
+		return nil
+	}
+}
+
+// Terms is used to collect all terms including negated ones, returning terms in order.
+func Terms(node Node) []Term {
+	switch n := node.(type) {
+	case And:
+		return append(Terms(n.Left), Terms(n.Right)...)
+	case Or:
+		return append(Terms(n.Left), Terms(n.Right)...)
+	case Not:
+		return Terms(n.Node)
+	case Term:
+		return []Term{n}
+	default:
+		return nil
+	}
+}
+
+// parser parses tokens of query by recursive descent.
+type parser struct {
+	tokens []token
+	i      int
+	// end is the length of query, where errors about missing terms are.
+	end int
+}
+
+// peek is used to get the next token without consuming it, returning the token and if there is one.
+func (p *parser) peek() (token, bool) {
+	if p.i >= len(p.tokens) {
+		return token{}, false
+	}
+	return p.tokens[p.i], true
+}
+
+// endOfTerm is used to check if there is no term next, returning the check result and where it is.
+func (p *parser) endOfTerm() (bool, int) {
+	t, ok := p.peek()
+	if !ok {
+		return true, p.end
+	}
+	return t.kind == tokenClose || t.is("OR"), t.pos
+}
+
+// parseOr parses terms separated by 'OR'.
+func (p *parser) parseOr() (Node, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		t, ok := p.peek()
+		if !ok || !t.is("OR") {
+			return left, nil
+		}
+		p.i++
+		if end, pos := p.endOfTerm(); end {
+			return nil, errorf(pos, "missing term after OR")
+		}
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = Or{Left: left, Right: right}
+	}
+}
+
+// parseAnd parses terms next to each other, which may be separated by 'AND'.
+func (p *parser) parseAnd() (Node, error) {
+	if end, pos := p.endOfTerm(); end {
+		if t, ok := p.peek(); ok && t.is("OR") {
+			return nil, errorf(pos, "missing term before OR")
+		}
+		return nil, errorf(pos, "missing term")
+	}
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		if end, _ := p.endOfTerm(); end {
+			return left, nil
+		}
+		if t, _ := p.peek(); t.is("AND") {
+			p.i++
+			if end, pos := p.endOfTerm(); end {
+				return nil, errorf(pos, "missing term after AND")
+			}
+		}
+		right, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		left = And{Left: left, Right: right}
+	}
+}
+
+// parseUnary parses a term which may be negated by '-' or 'NOT'.
+func (p *parser) parseUnary() (Node, error) {
+	t, _ := p.peek()
+	if t.kind == tokenNot || t.is("NOT") {
+		p.i++
+		if end, pos := p.endOfTerm(); end {
+			return nil, errorf(pos, "missing term after %s", t.text())
+		}
+		node, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return Not{Node: node}, nil
+	}
+	return p.parsePrimary()
+}
+
+// parsePrimary parses a group in parentheses or a term.
+func (p *parser) parsePrimary() (Node, error) {
+	t, _ := p.peek()
+	p.i++
+	switch {
+	case t.kind == tokenOpen:
+		if next, ok := p.peek(); ok && next.kind == tokenClose {
+			return nil, errorf(t.pos, "empty parentheses")
+		}
+		node, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if next, ok := p.peek(); !ok || next.kind != tokenClose {
+			return nil, errorf(t.pos, "missing ')' for this '('")
+		}
+		p.i++
+		return node, nil
+	case t.is("AND"):
+		return nil, errorf(t.pos, "missing term before AND")
+	default:
+		return parseTerm(t)
+	}
+}
+
+// parseTerm is used to parse a word into a term or a comparison, returning the node and error.
+func parseTerm(t token) (Node, error) {
+	if len(t.runes) > 1 && t.runes[0] == '#' && !t.literal[0] {
+		return Term{Field: FieldTag, Value: string(t.runes[1:])}, nil
+	}
+
+	// A field is bare letters followed by an operator which isn't quoted or escaped.
+	i := 0
+	for i < len(t.runes) && !t.literal[i] && unicode.IsLetter(t.runes[i]) {
+		i++
+	}
+	if i == 0 || i == len(t.runes) || t.literal[i] || !strings.ContainsRune(":<>=", t.runes[i]) {
+		return Term{Field: FieldText, Value: t.text()}, nil
+	}
+	field := strings.ToLower(string(t.runes[:i]))
+	switch field {
+	case FieldKey, FieldVal, FieldTag, FieldHas, FieldCreated, FieldUpdated:
+	default:
+		return nil, errorf(t.pos, "unknown field %s, expecting key, val, tag, has, created or updated, "+
+			"or quote the word like \"%s\" to find it as text", field, t.text())
+	}
+	op := string(t.runes[i])
+	if i+1 < len(t.runes) && !t.literal[i+1] && t.runes[i+1] == '=' && (op == "<" || op == ">") {
+		op += "="
+	}
+	value := string(t.runes[i+len([]rune(op)):])
+	valuePos := t.pos + i + len([]rune(op))
+	if value == "" {
+		return nil, errorf(valuePos, "missing value after %s%s", field, op)
+	}
+
+	switch field {
+	case FieldKey, FieldVal, FieldTag, FieldHas:
+		if op != ":" {
+			return nil, errorf(t.pos+i, "%s only supports ':', like %s:%s", field, field, value)
+		}
+		if field == FieldHas && value != HasRemind && value != HasReminded && value != HasTags {
+			return nil, errorf(valuePos, "unknown has:%s, expecting has:%s, has:%s or has:%s",
+				value, HasRemind, HasReminded, HasTags)
+		}
+		if field == FieldTag {
+			value = strings.TrimPrefix(value, "#")
+		}
+		return Term{Field: field, Value: value}, nil
+	default:
+		if op == ":" {
+			op = "="
+		}
+		for _, d := range dateLayouts {
+			from, err := time.ParseInLocation(d.layout, value, time.Local)
+			if err == nil {
+				return Compare{Field: field, Op: op, From: from, To: from.Add(d.span)}, nil
+			}
+		}
+		return nil, errorf(valuePos, "invalid time %s, expecting like 2026-01-01 or \"2026-01-01 15:04\"", value)
+	}
+}
@@ -0,0 +1,161 @@
+package query
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// text is used to make a term of text.
+func text(value string) Term {
+	return Term{Field: FieldText, Value: value}
+}
+
+func TestParse(t *testing.T) {
+	day := time.Date(2026, 1, 1, 0, 0, 0, 0, time.Local)
+	minute := time.Date(2026, 1, 1, 15, 4, 0, 0, time.Local)
+	tests := []struct {
+		query string
+		want  Node
+	}{
+		{"", nil},
+		{"   ", nil},
+		{"mysql", text("mysql")},
+		{"北京 昌平", And{text("北京"), text("昌平")}},
+		{"a AND b", And{text("a"), text("b")}},
+		{"a OR b c", Or{text("a"), And{text("b"), text("c")}}},
+		{"a OR b OR c", Or{Or{text("a"), text("b")}, text("c")}},
+		{"(a OR b) c", And{Or{text("a"), text("b")}, text("c")}},
+		{"-test", Not{text("test")}},
+		{"NOT NOT a", Not{Not{text("a")}}},
+		{"a - b", And{And{text("a"), text("-")}, text("b")}},
+		{"a-b", text("a-b")},
+		{"or and not", And{And{text("or"), text("and")}, text("not")}},
+		{`"a OR b"`, text("a OR b")},
+		{`\OR`, text("OR")},
+		{`"say \"hi\" \\"`, text(`say "hi" \`)},
+		{`"foo:bar"`, text("foo:bar")},
+		{"#db", Term{Field: FieldTag, Value: "db"}},
+		{"#", text("#")},
+		{"tag:#db", Term{Field: FieldTag, Value: "db"}},
+		{"KEY:mysql", Term{Field: FieldKey, Value: "mysql"}},
+		{"val:北京", Term{Field: FieldVal, Value: "北京"}},
+		{`key:"a b"`, Term{Field: FieldKey, Value: "a b"}},
+		{"has:remind", Term{Field: FieldHas, Value: HasRemind}},
+		{"created>2026-01-01", Compare{FieldCreated, ">", day, day.Add(24 * time.Hour)}},
+		{"updated<=2026-01-01", Compare{FieldUpdated, "<=", day, day.Add(24 * time.Hour)}},
+		{"created:2026-01-01", Compare{FieldCreated, "=", day, day.Add(24 * time.Hour)}},
+		{`created>="2026-01-01 15:04"`, Compare{FieldCreated, ">=", minute, minute.Add(time.Minute)}},
+		{"created>2026-01-01T15:04", Compare{FieldCreated, ">", minute, minute.Add(time.Minute)}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			got, err := Parse(tt.query)
+			if err != nil {
+				t.Fatalf("parse error: %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parse got %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseError(t *testing.T) {
+	tests := []struct {
+		query string
+		want  string
+	}{
+		{"(a", "missing ')' for this '(' at column 1"},
+		{"a)", "unexpected ')' at column 2"},
+		{"()", "empty parentheses at column 1"},
+		{"a OR", "missing term after OR at column 5"},
+		{"OR a", "missing term before OR at column 1"},
+		{"a AND", "missing term after AND at column 6"},
+		{"NOT", "missing term after NOT at column 4"},
+		{"a -)", "unexpected ')' at column 4"},
+		{`"a`, "missing closing quote at column 1"},
+		{"北京 foo:bar", "unknown field foo, expecting key, val, tag, has, created or updated, " +
+			`or quote the word like "foo:bar" to find it as text at column 4`},
+		{"key:", "missing value after key: at column 5"},
+		{"key>a", "key only supports ':', like key:a at column 4"},
+		{"has:x", "unknown has:x, expecting has:remind, has:reminded or has:tags at column 5"},
+		{"created>yesterday", `invalid time yesterday, expecting like 2026-01-01 or "2026-01-01 15:04" at column 9`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			node, err := Parse(tt.query)
+			if err == nil {
+				t.Fatalf("parse got %#v, want error %q", node, tt.want)
+			}
+			if err.Error() != tt.want {
+				t.Errorf("parse got error %q, want %q", err.Error(), tt.want)
+			}
+		})
+	}
+}
+
+// fakeNote is a note which terms are matched against in tests.
+type fakeNote struct {
+	key, val string
+	tags     []string
+	created  time.Time
+}
+
+func (n fakeNote) Contains(field, text string) bool {
+	switch field {
+	case FieldKey:
+		return strings.Contains(n.key, text)
+	case FieldVal:
+		return strings.Contains(n.val, text)
+	default:
+		return strings.Contains(n.key, text) || strings.Contains(n.val, text)
+	}
+}
+
+func (n fakeNote) HasTag(tag string) bool {
+	for _, t := range n.tags {
+		if t == tag {
+			return true
+		}
+	}
+	return false
+}
+
+func (n fakeNote) Has(thing string) bool {
+	return thing == HasTags && len(n.tags) > 0
+}
+
+func (n fakeNote) Time(field string) time.Time {
+	return n.created
+}
+
+func TestMatch(t *testing.T) {
+	n := fakeNote{key: "mysql", val: "root", tags: []string{"db"}, created: time.Date(2026, 1, 1, 9, 0, 0, 0, time.Local)}
+	tests := []struct {
+		query string
+		want  bool
+	}{
+		{"my", true},
+		{"root", true},
+		{"key:root", false},
+		{"my -root", false},
+		{"redis OR val:ro", true},
+		{"#db has:tags", true},
+		{"#prod", false},
+		{"created:2026-01-01", true},
+		{"created>2026-01-01", false},
+		{"created<2026-01-02", true},
+		{`created>="2026-01-01 09:00"`, true},
+	}
+	for _, tt := range tests {
+		node, err := Parse(tt.query)
+		if err != nil {
+			t.Fatalf("parse %q error: %v", tt.query, err)
+		}
+		if got := Match(node, n); got != tt.want {
+			t.Errorf("match %q = %v, want %v", tt.query, got, tt.want)
+		}
+	}
+}
@@ -15,83 +15,113 @@ import (
 	"time"
 )
 
-const needRemind = "remind@"
-const reminded = "reminded@"
+const needRemind = note.NeedRemind
+const reminded = note.Reminded
 const reminderTypeWindows = "win"
 const reminderTypeEmail = "email"
 
 // mutex is used to ensure that the reminder is checking to-do notes serially.
 var mutex sync.Mutex
 
-// Start is used to start a reminder, checking to-do notes with specified interval seconds,
-// sending notifications for necessary. It'll modify 'remind@' to 'reminded@'
+// Start is used to start a reminder, checking to-do notes of notebooks whose reminder is enabled
+// with specified interval seconds, sending notifications for necessary. It'll modify 'remind@' to 'reminded@'
 // for notified notes to avoid duplicate notifications.
 func Start() error {
 	logs.Info("reminder: start start")
+	notebooks := make([]*note.Notebook, 0)
+	for _, b := range note.Notebooks() {
+		if enabled, _ := b.Reminds(); enabled {
+			notebooks = append(notebooks, b)
+		}
+	}
+	if len(notebooks) == 0 {
+		logs.Info("reminder: no notebook to remind")
+		return nil
+	}
+
 	c := cron.New()
 	spec := fmt.Sprintf("*/%d * * * * ?", config.Conf.Reminder.IntervalSeconds)
 	err := c.AddFunc(spec, func() {
 		mutex.Lock()
+		defer mutex.Unlock()
 		logs.Debug("reminder: check start")
-		notes, err := note.Find("todo", true, false, false)
-		if err != nil {
-			logs.Error("find todo error: %s\n", err.Error())
-			return
+		for _, b := range notebooks {
+			remind(b)
 		}
+		logs.Debug("reminder: check finished")
+	})
+	if err != nil {
+		return fmt.Errorf("add func to cron error: %v", err)
+	}
+	c.Start()
+	logs.Info("reminder: start finished")
+	return nil
+}
+
+// remind is used to send notifications for to-do notes of notebook whose remind-time has come.
+// Notes of notebooks other than the default one are titled with the notebook like '[work] todo'.
+// A locked notebook is skipped, since its notes can't be read until FIND is restarted.
+func remind(b *note.Notebook) {
+	if b.Locked() {
+		logs.Debug("reminder: skip locked notebook %s", b.Name())
+		return
+	}
+	_, types := b.Reminds()
+	notes, err := b.Find("todo", false)
+	if err != nil {
+		logs.Error("find todo in notebook %s error: %s\n", b.Name(), err.Error())
+		return
+	}
 
-		for _, _note := range notes {
-			key := note.GetKey(_note)
-			val := note.GetVal(_note)
+	for _, _note := range notes {
+		key := _note.Key
+		val := _note.Val
 
-			if !strings.Contains(val, needRemind) {
-				continue
-			}
+		if !strings.Contains(val, needRemind) {
+			continue
+		}
 
-			timeStr := strings.TrimSpace(strings.Split(val, needRemind)[1])
-			remindTime, err := parseRemindTime(timeStr)
-			if err != nil {
-				logs.Error("parse remind time of %s error: %s\n", timeStr, err.Error())
-				continue
+		timeStr := strings.TrimSpace(strings.Split(val, needRemind)[1])
+		remindTime, err := parseRemindTime(timeStr)
+		if err != nil {
+			logs.Error("parse remind time of %s error: %s\n", timeStr, err.Error())
+			continue
+		}
+
+		if time.Now().Unix() > remindTime {
+			title := key
+			if b.Name() != config.DefaultNotebook {
+				title = fmt.Sprintf("[%s] %s", b.Name(), key)
 			}
 
-			if time.Now().Unix() > remindTime {
-				remindSucceed := false
-				if strings.Contains(config.Conf.Reminder.Type, reminderTypeWindows) {
-					err = remindByWindows(key, val)
-					if err != nil {
-						logs.Error("remind %s by windows error: %s\n", key, err.Error())
-					} else {
-						remindSucceed = true
-					}
+			remindSucceed := false
+			if strings.Contains(types, reminderTypeWindows) {
+				err = remindByWindows(title, val)
+				if err != nil {
+					logs.Error("remind %s by windows error: %s\n", key, err.Error())
+				} else {
+					remindSucceed = true
 				}
+			}
 
-				if strings.Contains(config.Conf.Reminder.Type, reminderTypeEmail) {
-					err = remindByEmail(key, val)
-					if err != nil {
-						logs.Error("remind %s by email error: %s\n", key, err.Error())
-					} else {
-						remindSucceed = true
-					}
+			if strings.Contains(types, reminderTypeEmail) {
+				err = remindByEmail(title, val)
+				if err != nil {
+					logs.Error("remind %s by email error: %s\n", key, err.Error())
+				} else {
+					remindSucceed = true
 				}
+			}
 
-				if remindSucceed {
-					newNote := strings.ReplaceAll(_note, needRemind, reminded)
-					err = note.Modify(newNote)
-					if err != nil {
-						logs.Error("modify %s error: %s\n", newNote, err.Error())
-					}
+			if remindSucceed {
+				_note.Val = strings.ReplaceAll(val, needRemind, reminded)
+				err = b.Modify(_note, note.SourceReminder)
+				if err != nil {
+					logs.Error("modify %s error: %s\n", key, err.Error())
 				}
 			}
 		}
-		logs.Debug("reminder: check finished")
-		mutex.Unlock()
-	})
-	if err != nil {
-		return fmt.Errorf("add func to cron error: %v", err)
 	}
-	c.Start()
-	logs.Info("reminder: start finished")
-	return nil
 }
 
 // parseRemindTime is used to parse remindTime from string, returning remindTime(accurate to minutes) and error.
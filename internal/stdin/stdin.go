@@ -3,18 +3,164 @@ package stdin
 
 import (
 	"bufio"
+	"errors"
+	"fmt"
 	"os"
+	"os/signal"
 	"strings"
+	"syscall"
+
+	"github.com/peterh/liner"
+	"golang.org/x/term"
 )
 
+// reader is shared by all reads, so that lines pasted at once are not lost between reads.
+var reader = bufio.NewReader(os.Stdin)
+
+// editor edits lines typed in the terminal, which is nil unless Interact is called with stdin being a terminal.
+// Once it's set, all reads go through it, since it buffers stdin on its own.
+var editor *liner.State
+
+// historyPath is where lines remembered by the editor are kept across sessions.
+var historyPath string
+
+// Completer is used to complete the line before the cursor,
+// returning where the completed text starts in the line and candidates replacing it.
+type Completer func(line string) (int, []string)
+
+// Interact is used to edit lines of the prompt like a shell if stdin is a terminal,
+// with cursor movement, history loaded from and saved to path, reverse search by Ctrl-R and completion by Tab.
+// The terminal stays in raw mode until Close is called, or the program is interrupted.
+func Interact(path string, complete Completer) error {
+	if !term.IsTerminal(int(os.Stdin.Fd())) {
+		return nil
+	}
+	editor = liner.NewLiner()
+	interrupted := make(chan os.Signal, 1)
+	signal.Notify(interrupted, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-interrupted
+		Close()
+		os.Exit(1)
+	}()
+	editor.SetCtrlCAborts(true)
+	editor.SetTabCompletionStyle(liner.TabPrints)
+	editor.SetWordCompleter(func(line string, pos int) (string, []string, string) {
+		start, candidates := complete(line[:pos])
+		return line[:start], candidates, line[pos:]
+	})
+	historyPath = path
+	file, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("open history error: %v", err)
+	}
+	defer func() { _ = file.Close() }()
+	_, err = editor.ReadHistory(file)
+	if err != nil {
+		return fmt.Errorf("read history error: %v", err)
+	}
+	return nil
+}
+
+// Close is used to give the terminal back as it was before Interact, which must be done before the program exits.
+func Close() {
+	if editor != nil {
+		_ = editor.Close()
+	}
+}
+
+// Remember is used to add a line to the history, which is saved at once,
+// so that it's kept even if the program exits without a chance to clean up.
+func Remember(line string) error {
+	if editor == nil || strings.TrimSpace(line) == "" {
+		return nil
+	}
+	editor.AppendHistory(line)
+	file, err := os.Create(historyPath)
+	if err != nil {
+		return fmt.Errorf("create history error: %v", err)
+	}
+	defer func() { _ = file.Close() }()
+	_, err = editor.WriteHistory(file)
+	if err != nil {
+		return fmt.Errorf("write history error: %v", err)
+	}
+	return nil
+}
+
+// Prompt is used to get user's input after showing prompt, where Ctrl-C drops the line being typed,
+// returning space-trimmed string and error, which is io.EOF if user presses Ctrl-D on an empty line.
+func Prompt(prompt string) (string, error) {
+	if editor == nil {
+		fmt.Print(prompt)
+		return ReadString()
+	}
+	for {
+		input, err := editor.Prompt(prompt)
+		if errors.Is(err, liner.ErrPromptAborted) {
+			continue
+		}
+		return strings.TrimSpace(input), err
+	}
+}
+
 // ReadString is used to get user's input,
 // returning space-trimmed string and error.
 func ReadString() (string, error) {
-	reader := bufio.NewReader(os.Stdin)
-	input, err := reader.ReadString('\n')
+	input, err := readLine()
 	if err != nil {
 		return "", err
 	} else {
 		return strings.TrimSpace(input), nil
 	}
 }
+
+// readLine is used to read a line through the editor if there's one, returning the line without the line break, and error.
+func readLine() (string, error) {
+	if editor != nil {
+		return editor.Prompt("")
+	}
+	line, err := reader.ReadString('\n')
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
+
+// ReadUntil is used to get user's input of multiple lines until a line equals to delim,
+// returning the lines joined by '\n' without the delim line, and error.
+// Unlike ReadString, spaces of each line are kept.
+func ReadUntil(delim string) (string, error) {
+	var lines []string
+	for {
+		line, err := readLine()
+		if err != nil {
+			return "", err
+		}
+		if strings.TrimSpace(line) == delim {
+			return strings.Join(lines, "\n"), nil
+		}
+		lines = append(lines, line)
+	}
+}
+
+// ReadSecret is used to get user's input after showing prompt without echoing it if stdin is a terminal,
+// returning the input without the line break, and error.
+// The prompt is shown on stderr, so that it doesn't mix with output of orders.
+// Once lines are edited by Interact, the prompt is shown like other prompts.
+func ReadSecret(prompt string) (string, error) {
+	if editor != nil {
+		return editor.PasswordPrompt(prompt)
+	}
+	fmt.Fprint(os.Stderr, prompt)
+	fd := int(os.Stdin.Fd())
+	if term.IsTerminal(fd) {
+		secret, err := term.ReadPassword(fd)
+		fmt.Fprintln(os.Stderr)
+		return string(secret), err
+	}
+	return readLine()
+}
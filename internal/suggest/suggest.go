@@ -0,0 +1,123 @@
+// Package suggest implements methods for finding what user may mean when the input matches nothing.
+package suggest
+
+import (
+	"find/internal/fuzzy"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// threshold is the lowest similarity of a candidate to be suggested.
+const threshold = 0.5
+
+// Weights of similarities by edit distance, token overlap and common prefix, which sum to 1.
+const (
+	editWeight   = 0.5
+	tokenWeight  = 0.3
+	prefixWeight = 0.2
+)
+
+// Closest is used to pick at most max candidates which are similar to target,
+// by edit distance, overlap of words and common prefix ignoring the case,
+// returning candidates from the most similar one, or nil if none is similar enough.
+func Closest(target string, candidates []string, max int) []string {
+	target = strings.ToLower(strings.TrimSpace(target))
+	if target == "" || max <= 0 {
+		return nil
+	}
+	type scored struct {
+		candidate  string
+		similarity float64
+	}
+	results := make([]scored, 0)
+	seen := make(map[string]bool, len(candidates))
+	for _, c := range candidates {
+		if seen[c] {
+			continue
+		}
+		seen[c] = true
+		if s := Similarity(target, c); s >= threshold {
+			results = append(results, scored{candidate: c, similarity: s})
+		}
+	}
+	sort.SliceStable(results, func(i, j int) bool {
+		if results[i].similarity != results[j].similarity {
+			return results[i].similarity > results[j].similarity
+		}
+		return results[i].candidate < results[j].candidate
+	})
+	if len(results) > max {
+		results = results[:max]
+	}
+	if len(results) == 0 {
+		return nil
+	}
+	closest := make([]string, 0, len(results))
+	for _, r := range results {
+		closest = append(closest, r.candidate)
+	}
+	return closest
+}
+
+// Similarity is used to measure how similar a and b are ignoring the case, returning a number from 0 to 1.
+func Similarity(a, b string) float64 {
+	a = strings.ToLower(a)
+	b = strings.ToLower(b)
+	if a == b {
+		return 1
+	}
+	length := len([]rune(a))
+	if l := len([]rune(b)); l > length {
+		length = l
+	}
+	if length == 0 {
+		return 0
+	}
+	edit := 1 - float64(fuzzy.Distance(a, b))/float64(length)
+	prefix := float64(commonPrefix(a, b)) / float64(length)
+	return editWeight*edit + tokenWeight*overlap(a, b) + prefixWeight*prefix
+}
+
+// overlap is used to get the ratio of words shared by a and b to all words of them.
+func overlap(a, b string) float64 {
+	wordsA := words(a)
+	wordsB := words(b)
+	union := make(map[string]bool, len(wordsA)+len(wordsB))
+	shared := 0
+	for w := range wordsA {
+		union[w] = true
+		if wordsB[w] {
+			shared++
+		}
+	}
+	for w := range wordsB {
+		union[w] = true
+	}
+	if len(union) == 0 {
+		return 0
+	}
+	return float64(shared) / float64(len(union))
+}
+
+// words is used to split s into a set of words of letters and digits.
+func words(s string) map[string]bool {
+	set := make(map[string]bool)
+	for _, w := range strings.FieldsFunc(s, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	}) {
+		set[w] = true
+	}
+	return set
+}
+
+// commonPrefix is used to count runes at the beginning which a and b share.
+func commonPrefix(a, b string) int {
+	ra := []rune(a)
+	rb := []rune(b)
+	n := 0
+	for n < len(ra) && n < len(rb) && ra[n] == rb[n] {
+		n++
+	}
+	return n
+}
@@ -0,0 +1,47 @@
+package suggest
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestClosest(t *testing.T) {
+	keys := []string{"mysql", "mysql-prod", "redis", "postgres", "prod db", "MySQL"}
+	tests := []struct {
+		target string
+		max    int
+		want   []string
+	}{
+		{"myslq", 3, []string{"MySQL", "mysql"}},
+		{"mysql-prd", 3, []string{"mysql-prod", "MySQL", "mysql"}},
+		{"mysql-prd", 1, []string{"mysql-prod"}},
+		{"prod  db", 3, []string{"prod db"}},
+		{"reids", 3, nil},
+		{"mongodb", 3, nil},
+		{"  ", 3, nil},
+		{"mysql", 0, nil},
+	}
+	for _, tt := range tests {
+		if got := Closest(tt.target, keys, tt.max); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("closest to %q got %q, want %q", tt.target, got, tt.want)
+		}
+	}
+}
+
+func TestSimilarity(t *testing.T) {
+	tests := []struct {
+		a, b      string
+		low, high float64
+	}{
+		{"MySQL", "mysql", 1, 1},
+		{"a", "b", 0, 0},
+		{"abc", "xyz", 0, 0},
+		{"myslq", "mysql", 0.5, 0.9},
+		{"prod db", "db prod", 0.3, 0.6},
+	}
+	for _, tt := range tests {
+		if got := Similarity(tt.a, tt.b); got < tt.low || got > tt.high {
+			t.Errorf("similarity of %q and %q = %v, want in [%v, %v]", tt.a, tt.b, got, tt.low, tt.high)
+		}
+	}
+}